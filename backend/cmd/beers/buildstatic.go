@@ -0,0 +1,104 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// copyFrontend copies the built frontend into the site, over the files of
+// an earlier build.
+func copyFrontend(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+func runBuildStatic(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("build-static", flag.ExitOnError)
+	output := fs.String("o", "site", "directory to write the site to")
+	baseURL := fs.String("url", "", "absolute URL the site will be hosted at, e.g. https://beers.example.com (required)")
+	pageSize := fs.Int("page-size", 50, "check-ins per JSON page")
+	photos := fs.Bool("photos", false, "copy the photos into the site instead of linking to the bucket")
+	thumbnails := fs.Bool("thumbnails", false, "render the thumbnails into the site")
+	frontend := fs.String("frontend", distDir(), "built frontend to copy into the site, empty to skip it")
+	fs.Parse(args)
+
+	if *baseURL == "" {
+		fs.Usage()
+		return errors.New("-url is required")
+	}
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *frontend != "" {
+		if _, err := os.Stat(*frontend); err != nil {
+			return fmt.Errorf("frontend not found, build it or pass -frontend '': %w", err)
+		}
+		if err := copyFrontend(*frontend, *output); err != nil {
+			return fmt.Errorf("copy frontend: %w", err)
+		}
+	}
+
+	report, err := api.BuildStatic(ctx, client, cfg, *output, api.StaticOptions{
+		BaseURL:    *baseURL,
+		PageSize:   *pageSize,
+		Photos:     *photos,
+		Thumbnails: *thumbnails,
+		Progress: func(done int) {
+			if done%100 == 0 {
+				log.Printf("prepared %d check-ins", done)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d check-ins in %d pages to %s.\n", report.Checkins, report.Pages, *output)
+	if *photos {
+		fmt.Printf("Copied %d new photos.\n", report.Photos)
+	}
+	if *thumbnails {
+		fmt.Printf("Rendered %d new thumbnails.\n", report.Thumbnails)
+	}
+	if report.Removed > 0 {
+		fmt.Printf("Removed %d files of check-ins no longer in the journal.\n", report.Removed)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+type doctorReport struct {
+	Config    map[string]string `json:"config"`
+	Diagnoses []api.Diagnosis   `json:"diagnoses"`
+}
+
+func runDoctor(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each network check")
+	fs.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		if *asJSON {
+			writeDoctorReport(doctorReport{
+				Config:    map[string]string{},
+				Diagnoses: []api.Diagnosis{{Check: "config", Status: api.DiagnosisFail, Detail: err.Error()}},
+			})
+		} else {
+			fmt.Printf("fail  config: %v\n", err)
+			fmt.Println("      see the README for the environment variables to set")
+		}
+		return errors.New("invalid configuration")
+	}
+
+	report := doctorReport{Config: map[string]string{}}
+	for _, s := range cfg.Summary() {
+		report.Config[s.Name] = s.Value
+	}
+	if !*asJSON {
+		fmt.Println("Configuration:")
+		for _, s := range cfg.Summary() {
+			fmt.Printf("  %-22s %s\n", s.Name, s.Value)
+		}
+		fmt.Println()
+	}
+
+	client, err := s3client.NewS3Client(ctx, cfg)
+	if err != nil {
+		if *asJSON {
+			report.Diagnoses = []api.Diagnosis{{Check: "client", Status: api.DiagnosisFail, Detail: err.Error()}}
+			writeDoctorReport(report)
+		} else {
+			fmt.Printf("fail  client: %v\n", err)
+		}
+		return errors.New("cannot create the storage client")
+	}
+
+	// the listing, the metadata and the photo are checked one after the
+	// other, each within the timeout
+	deadline := 3 * *timeout
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+	report.Diagnoses = api.Diagnose(ctx, client, cfg, &http.Client{Timeout: *timeout})
+
+	if *asJSON {
+		if err := writeDoctorReport(report); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, d := range report.Diagnoses {
+		if d.Status == api.DiagnosisFail {
+			failed++
+		}
+		if !*asJSON {
+			fmt.Printf("%-4s  %s: %s\n", d.Status, d.Check, d.Detail)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+func writeDoctorReport(report doctorReport) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runDuplicates(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	threshold := fs.Int(
+		"threshold",
+		api.DefaultDuplicateThreshold,
+		"largest number of differing hash bits between duplicates",
+	)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	skipIndex := fs.Bool("skip-index", false, "only compare photos which are already indexed")
+	fs.Parse(args)
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	indexer, err := openIndexer(cfg, client)
+	if err != nil {
+		return err
+	}
+	if !*skipIndex {
+		if _, err := indexBucket(ctx, cfg, client, indexer); err != nil {
+			return err
+		}
+	}
+
+	groups := api.FindDuplicates(ctx, client, cfg, indexer, *threshold)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(api.DuplicatesResponse{Groups: groups})
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No duplicate photos found.")
+		return nil
+	}
+	for i, g := range groups {
+		fmt.Printf("Group %d (distance %d):\n", i+1, g.Distance)
+		for _, p := range g.Photos {
+			fmt.Printf("  %s\n", p.Key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "csv", "export format: "+strings.Join(api.ExportFormats(), ", "))
+	from := fs.String("from", "", "first day to export, as YYYY-MM-DD")
+	to := fs.String("to", "", "last day to export, as YYYY-MM-DD")
+	trip := fs.String("trip", "", "only export the check-ins of this trip")
+	output := fs.String("o", "-", "file to write, - for the standard output")
+	baseURL := fs.String("url", "", "absolute URL the site is hosted at, required to link photos when IMAGE_PROXY is set")
+	fs.Parse(args)
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	filter := url.Values{}
+	for name, v := range map[string]string{"from": *from, "to": *to, "trip": *trip} {
+		if v != "" {
+			filter.Set(name, v)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+
+	if err := api.WriteExport(ctx, client, cfg, bw, *format, filter, *baseURL); err != nil {
+		return fmt.Errorf("export %s: %w", *format, err)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"beers/backend/internal/untappd"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report the changes without writing them")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: beers import [flags] <export.csv|export.json>\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected the path of an Untappd export")
+	}
+
+	checkins, err := untappd.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("read export: %w", err)
+	}
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	report, err := api.ImportCheckins(ctx, client, cfg, checkins, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	verb := "Completed"
+	if *dryRun {
+		verb = "Would complete"
+	}
+	fmt.Printf("%s %d check-ins, %d already complete, %d failed.\n",
+		verb, len(report.Updated), report.Complete, len(report.Failed))
+	for _, u := range report.Updated {
+		fmt.Printf("  %s: %s\n", u.Key, strings.Join(u.Fields, ", "))
+	}
+	for _, f := range report.Failed {
+		fmt.Printf("  %s failed: %s\n", f.Key, f.Error)
+	}
+
+	if len(report.WithoutPhoto) > 0 {
+		fmt.Printf("\n%d check-ins have no photo:\n", len(report.WithoutPhoto))
+		for _, c := range report.WithoutPhoto {
+			fmt.Printf("  %s  %s  %s - %s\n", c.ID, c.CreatedAt, c.Brewery, c.Beer)
+		}
+	}
+	return nil
+}
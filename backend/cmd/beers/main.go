@@ -0,0 +1,127 @@
+package main
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{
+		name:    "serve",
+		summary: "Run the web server",
+		run:     runServe,
+	},
+	{
+		name:    "sync",
+		summary: "Bring the image index up to date with the bucket",
+		run:     runSync,
+	},
+	{
+		name:    "export",
+		summary: "Export the journal as CSV, JSONL, KML, GPX, iCalendar or ZIP",
+		run:     runExport,
+	},
+	{
+		name:    "build-static",
+		summary: "Render the journal as a site any static host can serve",
+		run:     runBuildStatic,
+	},
+	{
+		name:    "stats",
+		summary: "Summarise the journal",
+		run:     runStats,
+	},
+	{
+		name:    "duplicates",
+		summary: "Report near-duplicate photos",
+		run:     runDuplicates,
+	},
+	{
+		name:    "tui",
+		summary: "Browse the journal in the terminal",
+		run:     runTUI,
+	},
+	{
+		name:    "import",
+		summary: "Complete check-in metadata from an Untappd data export",
+		run:     runImport,
+	},
+	{
+		name:    "verify",
+		summary: "Report objects and metadata which break the bucket conventions",
+		run:     runVerify,
+	},
+	{
+		name:    "migrate",
+		summary: "Bring the metadata of every photo to the current schema",
+		run:     runMigrate,
+	},
+	{
+		name:    "doctor",
+		summary: "Check the configuration and the access to the bucket",
+		run:     runDoctor,
+	},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: beers <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'beers <command> -h' for the flags of a command.\n")
+}
+
+// setup loads the configuration and creates the storage client shared by
+// all commands.
+func setup(ctx context.Context) (*config.AppConfig, *s3client.Client, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config: %w (run 'beers doctor' to check the configuration)", err)
+	}
+
+	client, err := s3client.NewS3Client(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create S3 client: %w (run 'beers doctor' to check the configuration)", err)
+	}
+	return cfg, client, nil
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err := c.run(ctx, os.Args[2:])
+		stop()
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// migrateState is the checkpoint of an interrupted migration.
+type migrateState struct {
+	SchemaVersion int    `json:"schema_version"`
+	After         string `json:"after"`
+}
+
+// loadMigrateState returns where to resume, or an empty key when the last
+// run finished or targeted another schema version.
+func loadMigrateState(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var state migrateState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", fmt.Errorf("invalid state %s: %w", path, err)
+	}
+	if state.SchemaVersion != api.SchemaVersion() {
+		return "", nil
+	}
+	return state.After, nil
+}
+
+func saveMigrateState(path, after string) error {
+	data, err := json.Marshal(migrateState{SchemaVersion: api.SchemaVersion(), After: after})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func runMigrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report the migrations without writing them")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	restart := fs.Bool("restart", false, "ignore the checkpoint of an interrupted run")
+	statePath := fs.String("state", "", "checkpoint file (default migrate.json in the cache directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: beers migrate [flags]\n\nMigrations:\n")
+		for _, m := range api.Migrations {
+			fmt.Fprintf(fs.Output(), "  %d  %s\n", m.Version, m.Name)
+		}
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+	if *statePath == "" {
+		*statePath = filepath.Join(cfg.CacheDir, "migrate.json")
+	}
+
+	opts := api.MigrateOptions{
+		DryRun: *dryRun,
+		Progress: func(checked int) {
+			log.Printf("checked %d check-ins", checked)
+		},
+		Checkpoint: func(after string) error {
+			return saveMigrateState(*statePath, after)
+		},
+	}
+	if !*restart {
+		if opts.After, err = loadMigrateState(*statePath); err != nil {
+			return err
+		}
+		if opts.After != "" {
+			log.Printf("resuming after %s", opts.After)
+		}
+	}
+
+	report, err := api.MigrateMetadata(ctx, client, cfg, opts)
+	if err != nil {
+		return err
+	}
+	if !*dryRun {
+		// the next run starts over, retrying the failed check-ins
+		if err := os.Remove(*statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	verb := "Migrated"
+	if *dryRun {
+		verb = "Would migrate"
+	}
+	fmt.Printf("%s %d of %d check-ins to schema version %d, %d failed.\n",
+		verb, len(report.Migrated), report.Checked, api.SchemaVersion(), len(report.Failed))
+	for _, m := range report.Migrated {
+		if len(m.Migrations) > 0 {
+			fmt.Printf("  %s: %s\n", m.Key, strings.Join(m.Migrations, ", "))
+		}
+	}
+	for _, f := range report.Failed {
+		fmt.Printf("  %s failed: %s\n", f.Key, f.Error)
+	}
+	return nil
+}
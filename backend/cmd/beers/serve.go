@@ -0,0 +1,137 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"beers/backend/internal/diskcache"
+	"beers/backend/internal/imageindex"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// a page of the journal loads a thumbnail per check-in at once
+const (
+	thumbnailRate  = 10
+	thumbnailBurst = 100
+)
+
+func rateLimit(next http.Handler) http.Handler {
+	return limit(rate.NewLimiter(1, 3), next)
+}
+
+func limit(limiter *rate.Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limiter.Allow() {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := fs.String("port", "", "port to listen on (default $PORT or 8080)")
+	fs.Parse(args)
+	// timestamps matter in server logs
+	log.SetFlags(log.LstdFlags)
+
+	cfg, s3Client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+	if *port != "" {
+		cfg.Port = *port
+	}
+
+	cache, err := diskcache.New(filepath.Join(cfg.CacheDir, "images"), cfg.CacheMaxBytes)
+	if err != nil {
+		return fmt.Errorf("create cache: %w", err)
+	}
+
+	idx, err := imageindex.Open(filepath.Join(cfg.CacheDir, "index.json"))
+	if err != nil {
+		return fmt.Errorf("open image index: %w", err)
+	}
+	indexer := imageindex.NewIndexer(s3Client, cfg.BucketName, idx)
+	go indexer.Run(ctx)
+	trips := api.NewTripCache()
+
+	// requests in flight when shutting down are allowed to finish
+	reqCtx := context.WithoutCancel(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/images", rateLimit(api.GetImages(reqCtx, s3Client, cfg, indexer)))
+	mux.Handle("/api/trips", rateLimit(api.GetTrips(reqCtx, s3Client, cfg, trips)))
+	mux.Handle("/api/export", rateLimit(api.Export(reqCtx, s3Client, cfg, trips)))
+	mux.Handle("/feed.atom", rateLimit(api.GetAtomFeed(reqCtx, s3Client, cfg)))
+	mux.Handle("/feed.rss", rateLimit(api.GetRSSFeed(reqCtx, s3Client, cfg)))
+	mux.Handle("/feed.json", rateLimit(api.GetJSONFeed(reqCtx, s3Client, cfg)))
+	mux.Handle("/calendar.ics", rateLimit(api.GetCalendar(reqCtx, s3Client, cfg, trips)))
+	mux.Handle("/api/duplicates", rateLimit(api.GetDuplicates(reqCtx, s3Client, cfg, indexer)))
+	mux.Handle("POST /api/checkins", api.RequireAdmin(cfg, api.PostCheckin(reqCtx, s3Client, cfg)))
+	mux.Handle("PATCH /api/checkins/{key...}", api.RequireAdmin(cfg, api.PatchCheckin(reqCtx, s3Client, cfg)))
+	mux.Handle("GET /api/hidden", api.RequireAdmin(cfg, api.GetHiddenCheckins(reqCtx, s3Client, cfg)))
+	mux.Handle("PUT /api/hidden/{key...}", api.RequireAdmin(cfg, api.SetCheckinHidden(reqCtx, s3Client, cfg, true)))
+	mux.Handle("DELETE /api/hidden/{key...}", api.RequireAdmin(cfg, api.SetCheckinHidden(reqCtx, s3Client, cfg, false)))
+	mux.Handle("GET /img/{key...}", limit(
+		rate.NewLimiter(thumbnailRate, thumbnailBurst),
+		api.GetThumbnail(reqCtx, s3Client, cfg, cache),
+	))
+	if cfg.ImageProxy {
+		mux.Handle("GET /media/{key...}", api.GetMedia(reqCtx, s3Client, cfg, cache))
+	}
+	mux.Handle("/", staticHandler())
+
+	server := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: mux,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errc <- fmt.Errorf("listen on %s: %w", cfg.Port, err)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server forced to shutdown: %w", err)
+	}
+
+	log.Println("Server exiting")
+	return nil
+}
+
+// distDir returns the directory of the built frontend, next to the
+// executable.
+func distDir() string {
+	ex, err := os.Executable()
+	if err != nil {
+		log.Fatalf("error getting executable path: %v", err)
+	}
+	return filepath.Join(filepath.Dir(ex), "dist")
+}
+
+// staticHandler serves the built frontend.
+func staticHandler() http.Handler {
+	return http.FileServer(http.Dir(distDir()))
+}
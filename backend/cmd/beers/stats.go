@@ -0,0 +1,57 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runStats(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the statistics as JSON")
+	fs.Parse(args)
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats, err := api.ComputeStats(ctx, client, cfg)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	fmt.Printf("Check-ins:      %d\n", stats.Checkins)
+	fmt.Printf("Unique beers:   %d\n", stats.Beers)
+	fmt.Printf("Breweries:      %d\n", stats.Breweries)
+	fmt.Printf("Venues:         %d\n", stats.Venues)
+	fmt.Printf("Countries:      %d\n", stats.Countries)
+	fmt.Printf("Average rating: %.2f\n", stats.AverageRating)
+	if stats.First != "" {
+		fmt.Printf("From %s to %s\n", stats.First, stats.Last)
+	}
+
+	printCounts := func(title string, counts []api.StatsCount) {
+		if len(counts) == 0 {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		for _, c := range counts {
+			fmt.Printf("  %5d  %s\n", c.Count, c.Name)
+		}
+	}
+	printCounts("Per year", stats.PerYear)
+	printCounts("Top breweries", stats.TopBreweries)
+	printCounts("Top styles", stats.TopStyles)
+	printCounts("Top countries", stats.TopCountries)
+	return nil
+}
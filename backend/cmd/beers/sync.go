@@ -0,0 +1,65 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"beers/backend/internal/config"
+	"beers/backend/internal/imageindex"
+	"beers/backend/internal/s3client"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// openIndexer opens the image index shared with the server.
+func openIndexer(cfg *config.AppConfig, client *s3client.Client) (*imageindex.Indexer, error) {
+	idx, err := imageindex.Open(filepath.Join(cfg.CacheDir, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	return imageindex.NewIndexer(client, cfg.BucketName, idx), nil
+}
+
+// indexBucket indexes the photos of the bucket which are not indexed yet and
+// returns the keys of every photo.
+func indexBucket(ctx context.Context, cfg *config.AppConfig, client *s3client.Client, indexer *imageindex.Indexer) ([]string, error) {
+	keys, err := api.ListImageKeys(ctx, client, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = indexer.IndexMissing(ctx, keys, func(done, total int) {
+		if done%50 == 0 || done == total {
+			log.Printf("indexed %d/%d photos", done, total)
+		}
+	})
+	return keys, err
+}
+
+func runSync(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	noPrune := fs.Bool("no-prune", false, "keep the entries of photos deleted from the bucket")
+	fs.Parse(args)
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+	indexer, err := openIndexer(cfg, client)
+	if err != nil {
+		return err
+	}
+
+	keys, err := indexBucket(ctx, cfg, client, indexer)
+	if err != nil {
+		return err
+	}
+	removed := 0
+	if !*noPrune {
+		if removed, err = indexer.Prune(keys); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Index up to date with %d photos, %d removed.\n", len(keys), removed)
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"beers/backend/internal/tui"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// bucketSource browses the check-ins of the bucket.
+type bucketSource struct {
+	client *s3client.Client
+	cfg    *config.AppConfig
+}
+
+func (s bucketSource) Months(ctx context.Context) ([]time.Time, error) {
+	return api.ListMonths(ctx, s.client, s.cfg)
+}
+
+func (s bucketSource) Month(ctx context.Context, month time.Time) ([]api.Image, error) {
+	return api.ListMonthImages(ctx, s.client, s.cfg, month)
+}
+
+func (s bucketSource) All(ctx context.Context) ([]api.Image, error) {
+	return api.ListAllImages(ctx, s.client, s.cfg)
+}
+
+func runTUI(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	fs.Parse(args)
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+	// log lines would scroll the screen, errors show in the status line
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	return tui.Run(ctx, bucketSource{client: client, cfg: cfg}, os.Stdin, os.Stdout)
+}
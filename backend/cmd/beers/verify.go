@@ -0,0 +1,63 @@
+package main
+
+import (
+	"beers/backend/internal/api"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+)
+
+func runVerify(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	cfg, client, err := setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	report, err := api.VerifyBucket(ctx, client, cfg, func(checked int) {
+		log.Printf("checked %d check-ins", checked)
+	})
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Checked %d objects, %d check-ins.\n", report.Objects, report.Checkins)
+		byKind := map[string][]api.VerifyIssue{}
+		var kinds []string
+		for _, issue := range report.Issues {
+			if _, ok := byKind[issue.Kind]; !ok {
+				kinds = append(kinds, issue.Kind)
+			}
+			byKind[issue.Kind] = append(byKind[issue.Kind], issue)
+		}
+		slices.Sort(kinds)
+		for _, kind := range kinds {
+			fmt.Printf("\n%s (%d):\n", kind, len(byKind[kind]))
+			for _, issue := range byKind[kind] {
+				fmt.Printf("  %s: %s\n", issue.Key, issue.Detail)
+			}
+		}
+		if len(report.Issues) == 0 {
+			fmt.Println("No issues found.")
+		}
+	}
+
+	if len(report.Issues) > 0 {
+		return fmt.Errorf("%d issues found", len(report.Issues))
+	}
+	return nil
+}
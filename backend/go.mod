@@ -3,10 +3,15 @@ module beers/backend
 go 1.25.3
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/aws/aws-sdk-go-v2 v1.39.6
 	github.com/aws/aws-sdk-go-v2/config v1.31.17
 	github.com/aws/aws-sdk-go-v2/credentials v1.18.21
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.90.0
+	github.com/aws/smithy-go v1.23.2
+	golang.org/x/image v0.40.0
+	golang.org/x/term v0.44.0
+	golang.org/x/time v0.14.0
 )
 
 require (
@@ -23,6 +28,5 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/sso v1.30.1 // indirect
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.35.5 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sts v1.39.1 // indirect
-	github.com/aws/smithy-go v1.23.2 // indirect
-	golang.org/x/time v0.14.0 // indirect
+	golang.org/x/sys v0.46.0 // indirect
 )
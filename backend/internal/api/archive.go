@@ -0,0 +1,126 @@
+package api
+
+import (
+	"archive/zip"
+	"beers/backend/internal/keylayout"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+type archiveEntry struct {
+	Path     string          `json:"path"`
+	Key      string          `json:"key"`
+	URL      string          `json:"url"`
+	Metadata CheckinMetadata `json:"metadata"`
+	// paths of the extra photos, in the order of Metadata.Photos
+	Photos []string `json:"photos,omitempty"`
+}
+
+// archiveWriter builds a ZIP backup of the journal: every photo under a
+// YYYY/MM/DD/FORMAT/ directory, and a manifest.json with the metadata of all
+// of them.
+type archiveWriter struct {
+	zw       *zip.Writer
+	src      exportSource
+	manifest []archiveEntry
+	// paths already written
+	paths map[string]bool
+}
+
+func newArchiveWriter(w io.Writer, src exportSource) (exportWriter, error) {
+	return &archiveWriter{zw: zip.NewWriter(w), src: src, paths: map[string]bool{}}, nil
+}
+
+// archivePath returns the path of a photo inside the archive, laid out the
+// default way whatever the layout of the bucket.
+func archivePath(layout *keylayout.Layout, key string) string {
+	k, ok := layout.Match(key)
+	if !ok {
+		return path.Join("other", key)
+	}
+	return keylayout.Default.Build(k)
+}
+
+// uniquePath returns p, numbered if an earlier photo took it already. Keys
+// of layouts holding more than the default components may share a path.
+func (a *archiveWriter) uniquePath(p string) string {
+	ext := path.Ext(p)
+	unique := p
+	for i := 2; a.paths[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(p, ext), i, ext)
+	}
+	a.paths[unique] = true
+	return unique
+}
+
+func (a *archiveWriter) Write(img Image) error {
+	modified, _ := parseCheckinDate(img.Metadata)
+	entry := archiveEntry{
+		Path:     a.uniquePath(archivePath(a.src.layout, img.Key)),
+		Key:      img.Key,
+		URL:      img.URL,
+		Metadata: img.Metadata,
+	}
+	if err := a.store(img.Key, entry.Path, modified); err != nil {
+		return err
+	}
+	for _, photo := range img.Metadata.Photos {
+		p := a.uniquePath(archivePath(a.src.layout, photo))
+		if err := a.store(photo, p, modified); err != nil {
+			return err
+		}
+		entry.Photos = append(entry.Photos, p)
+	}
+
+	a.manifest = append(a.manifest, entry)
+	return nil
+}
+
+// store copies the object at key into the archive at path p.
+func (a *archiveWriter) store(key, p string, modified time.Time) error {
+	body, err := a.src.fetch(key)
+	if err != nil {
+		return fmt.Errorf("get %s: %w", key, err)
+	}
+	defer body.Close()
+
+	fw, err := a.zw.CreateHeader(&zip.FileHeader{
+		Name: p,
+		// photos are already compressed
+		Method:   zip.Store,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, body); err != nil {
+		return fmt.Errorf("copy %s: %w", key, err)
+	}
+	return nil
+}
+
+func (a *archiveWriter) Close() error {
+	fw, err := a.zw.CreateHeader(&zip.FileHeader{
+		Name:     "manifest.json",
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest := a.manifest
+	if manifest == nil {
+		manifest = []archiveEntry{}
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return a.zw.Close()
+}
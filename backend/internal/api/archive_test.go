@@ -0,0 +1,168 @@
+package api
+
+import (
+	"archive/zip"
+	"beers/backend/internal/config"
+	"beers/backend/internal/keylayout"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestArchivePath(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"2025/11/08/WEBP/image1.webp", "2025/11/08/WEBP/image1.webp"},
+		{"misc/image1.webp", "other/misc/image1.webp"},
+	}
+	for _, tt := range tests {
+		if got := archivePath(keylayout.Default, tt.key); got != tt.expected {
+			t.Errorf("archivePath(%q) = %q, want %q", tt.key, got, tt.expected)
+		}
+	}
+
+	// a layout without the day gets the first of the month
+	layout := keylayout.MustParse("{yyyy}/{mm}/{format}/{id}.{ext}")
+	if got := archivePath(layout, "2025/11/WEBP/image1.webp"); got != "2025/11/01/WEBP/image1.webp" {
+		t.Errorf("archivePath() = %q with a custom layout", got)
+	}
+}
+
+func TestArchiveUniquePath(t *testing.T) {
+	a := &archiveWriter{paths: map[string]bool{}}
+	for _, want := range []string{"2025/11/08/WEBP/1.webp", "2025/11/08/WEBP/1-2.webp", "2025/11/08/WEBP/1-3.webp"} {
+		if got := a.uniquePath("2025/11/08/WEBP/1.webp"); got != want {
+			t.Errorf("uniquePath() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestExportZIP(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+	client := newExportMockClient(map[string]map[string]string{
+		"2025/11/08/WEBP/image1.webp": {"id": "1", "beer": "Test Beer"},
+		"2025/11/09/WEBP/image2.webp": {"id": "2", "beer": "Hidden Beer", "hidden": "true"},
+	})
+	client.GetObjectFunc = func(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error) {
+		body := "photo:" + aws.ToString(params.Key)
+		return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	// backups fetch the whole bucket, they are not served
+	req := httptest.NewRequest(http.MethodGet, "/api/export?format=zip", nil)
+	rr := httptest.NewRecorder()
+	Export(context.Background(), client, cfg, NewTripCache()).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	var b bytes.Buffer
+	if err := WriteExport(context.Background(), client, cfg, &b, "zip", nil, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("could not open ZIP: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("could not open %s: %v", f.Name, err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+
+	if got := files["2025/11/08/WEBP/image1.webp"]; got != "photo:2025/11/08/WEBP/image1.webp" {
+		t.Errorf("unexpected photo content: %q", got)
+	}
+
+	var manifest []archiveEntry
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("could not decode manifest: %v", err)
+	}
+	// hidden check-ins are backed up too, flagged in their metadata
+	if got := files["2025/11/09/WEBP/image2.webp"]; got != "photo:2025/11/09/WEBP/image2.webp" {
+		t.Errorf("unexpected hidden photo content: %q", got)
+	}
+	hidden := map[string]bool{}
+	for _, entry := range manifest {
+		hidden[entry.Metadata.Beer] = entry.Metadata.Hidden
+	}
+	if len(manifest) != 2 || hidden["Test Beer"] || !hidden["Hidden Beer"] {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+}
+
+func TestArchiveExtraPhotos(t *testing.T) {
+	var buf bytes.Buffer
+	src := exportSource{
+		fetch: func(key string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("photo:" + key)), nil
+		},
+		layout: keylayout.Default,
+	}
+	w, err := newArchiveWriter(&buf, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img := Image{
+		Key: "2025/11/08/WEBP/image1.webp",
+		Metadata: CheckinMetadata{
+			Date:   "2025-11-08 18:00:00",
+			Photos: []string{"2025/11/08/EXTRA/image1-2.webp"},
+		},
+	}
+	if err := w.Write(img); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("could not open ZIP: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("could not open %s: %v", f.Name, err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+
+	if got := files["2025/11/08/EXTRA/image1-2.webp"]; got != "photo:2025/11/08/EXTRA/image1-2.webp" {
+		t.Errorf("unexpected extra photo content: %q", got)
+	}
+	var manifest []archiveEntry
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("could not decode manifest: %v", err)
+	}
+	if len(manifest) != 1 || len(manifest[0].Photos) != 1 || manifest[0].Photos[0] != "2025/11/08/EXTRA/image1-2.webp" {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+}
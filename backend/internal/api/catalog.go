@@ -0,0 +1,466 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/imageindex"
+	"beers/backend/internal/keylayout"
+	"beers/backend/internal/s3client"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const checkinDateLayout = "2006-01-02 15:04:05"
+
+func parseCheckinDate(md CheckinMetadata) (time.Time, error) {
+	return time.Parse(checkinDateLayout, md.Date)
+}
+
+// keyLayout returns the configured key layout, or the default one.
+func keyLayout(cfg *config.AppConfig) *keylayout.Layout {
+	if cfg.KeyLayout == nil {
+		return keylayout.Default
+	}
+	return cfg.KeyLayout
+}
+
+// photoURL returns the URL a photo is served from: through the backend
+// when the image proxy is enabled, under the public bucket URL when one is
+// configured, otherwise a short-lived presigned URL.
+func photoURL(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+) (string, error) {
+	if cfg.ImageProxy {
+		return url.JoinPath("/media", key)
+	}
+	if cfg.PublicURL != "" {
+		return url.JoinPath(cfg.PublicURL, key)
+	}
+
+	presigner, ok := client.(s3client.Presigner)
+	if !ok {
+		return "", errors.New("no public URL configured and client cannot presign")
+	}
+	return presigner.PresignGetObject(ctx, cfg.BucketName, key, cfg.PresignTTL)
+}
+
+var errNoLastingURL = errors.New("photos only have presigned URLs without R2_PUBLIC_URL or IMAGE_PROXY")
+
+// hasLastingURLs reports whether photo URLs stay valid, as feeds and files
+// kept by their readers need. Presigned URLs expire.
+func hasLastingURLs(cfg *config.AppConfig) bool {
+	return cfg.ImageProxy || cfg.PublicURL != ""
+}
+
+// maximum size of a metadata sidecar
+const maxSidecarSize = 1 << 20
+
+// fetchSidecar downloads the JSON metadata sidecar of a check-in.
+func fetchSidecar(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+) ([]byte, error) {
+	data, _, err := fetchSidecarVersion(ctx, client, cfg, key)
+	return data, err
+}
+
+// fetchSidecarVersion downloads a sidecar along its ETag, to update it.
+func fetchSidecarVersion(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+) ([]byte, *string, error) {
+	out, err := s3client.GetObject(ctx, client, cfg.BucketName, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(out.Body, maxSidecarSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > maxSidecarSize {
+		return nil, nil, fmt.Errorf("sidecar larger than %d bytes", maxSidecarSize)
+	}
+	return data, out.ETag, nil
+}
+
+// checkinRead is the metadata of a check-in, read from its primary rendition
+// and its sidecar.
+type checkinRead struct {
+	head    *s3.HeadObjectOutput
+	sidecar []byte
+	// error reading the primary, a sidecar failing to load is only logged
+	err error
+}
+
+// runWorkers calls job for every index up to n, from a few goroutines at a
+// time, and waits for them to finish.
+func runWorkers(n, workers int, job func(i int)) {
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				job(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// readCheckins reads the metadata of each check-in, using a pool of workers
+// to limit concurrent requests. Results are in the order of groups.
+func readCheckins(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	groups []renditionGroup,
+	workers int,
+) []checkinRead {
+	reads := make([]checkinRead, len(groups))
+	runWorkers(len(groups), workers, func(i int) {
+		group := groups[i]
+		head, err := s3client.GetObjectMetadata(ctx, client, cfg.BucketName, *group.primary.Key)
+		if err != nil {
+			reads[i].err = err
+			return
+		}
+		reads[i].head = head
+
+		if group.sidecar != "" {
+			if reads[i].sidecar, err = fetchSidecar(ctx, client, cfg, group.sidecar); err != nil {
+				// the header metadata is still usable
+				log.Printf("error getting sidecar %s: %v", group.sidecar, err)
+			}
+		}
+	})
+	return reads
+}
+
+// fetchImages resolves the metadata and URLs of each check-in. Metadata is
+// read from the primary rendition, which the image URL points at. Check-ins
+// that fail are logged and skipped.
+func fetchImages(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	groups []renditionGroup,
+) []Image {
+	return buildImages(ctx, client, cfg, groups, readCheckins(ctx, client, cfg, groups, 4))
+}
+
+// buildImages resolves the URLs of each check-in whose metadata was read.
+func buildImages(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	groups []renditionGroup,
+	reads []checkinRead,
+) []Image {
+	images := make([]Image, 0, len(groups))
+	for i, group := range groups {
+		key := *group.primary.Key
+		if reads[i].err != nil {
+			log.Printf("error getting metadata %s: %v", key, reads[i].err)
+			continue
+		}
+		md := newCheckinMetadata(reads[i].head.Metadata, reads[i].sidecar)
+
+		img := Image{Key: key, Metadata: md}
+		if isDecodableKey(keyLayout(cfg), key) {
+			img.Srcset = thumbnailSrcset(key)
+		}
+
+		ok := true
+		for _, obj := range group.variants {
+			v := Variant{Key: *obj.Key, ContentType: renditionType(*obj.Key)}
+			var err error
+			if v.URL, err = photoURL(ctx, client, cfg, v.Key); err != nil {
+				log.Printf("failed to build URL of %s: %v", v.Key, err)
+				ok = false
+				break
+			}
+			if v.Key == key {
+				img.URL, img.ContentType = v.URL, v.ContentType
+			}
+			img.Variants = append(img.Variants, v)
+		}
+		if !ok {
+			continue
+		}
+		img.Metadata.Photos = nil
+		for _, photo := range md.Photos {
+			if !isExtraPhotoKey(keyLayout(cfg), key, photo) {
+				log.Printf("ignoring extra photo %s of %s, it must be stored next to the check-in", photo, key)
+				continue
+			}
+			img.Metadata.Photos = append(img.Metadata.Photos, photo)
+			u, err := photoURL(ctx, client, cfg, photo)
+			if err != nil {
+				log.Printf("failed to build URL of %s: %v", photo, err)
+				continue
+			}
+			img.Photos = append(img.Photos, u)
+		}
+
+		images = append(images, img)
+	}
+	return dropExtraPhotos(images)
+}
+
+// isExtraPhotoKey reports whether an extra photo listed in the sidecar of a
+// check-in is stored where it is listed along the check-in: under the key
+// layout, with the same key up to the format.
+func isExtraPhotoKey(layout *keylayout.Layout, checkin, photo string) bool {
+	return isRenditionKey(layout, photo) && layout.GroupPrefix(photo) == layout.GroupPrefix(checkin)
+}
+
+// dropExtraPhotos removes the images which are extra photos of another
+// check-in rather than check-ins of their own. Both are listed together, as
+// they share the key up to the format.
+func dropExtraPhotos(images []Image) []Image {
+	extra := map[string]bool{}
+	for _, img := range images {
+		for _, photo := range img.Metadata.Photos {
+			extra[photo] = true
+		}
+	}
+	if len(extra) == 0 {
+		return images
+	}
+
+	kept := images[:0]
+	for _, img := range images {
+		isExtra := extra[img.Key]
+		for _, v := range img.Variants {
+			isExtra = isExtra || extra[v.Key]
+		}
+		if !isExtra {
+			kept = append(kept, img)
+		}
+	}
+	return kept
+}
+
+// sortImagesNewestFirst orders images by check-in date, falling back to the
+// object key when a date cannot be parsed.
+func sortImagesNewestFirst(images []Image) {
+	sort.SliceStable(images, func(i, j int) bool {
+		ti, errI := parseCheckinDate(images[i].Metadata)
+		tj, errJ := parseCheckinDate(images[j].Metadata)
+
+		if errI != nil || errJ != nil {
+			// fallback to key sort
+			return images[i].Key > images[j].Key
+		}
+
+		return ti.After(tj)
+	})
+}
+
+// annotateImages adds the indexed size and placeholder of each image, and
+// schedules the images which are not indexed yet.
+func annotateImages(images []Image, indexer *imageindex.Indexer) {
+	for i := range images {
+		e, ok := indexer.Lookup(images[i].Key)
+		if !ok {
+			indexer.Enqueue(images[i].Key)
+			continue
+		}
+		images[i].Width = e.Width
+		images[i].Height = e.Height
+		images[i].Blurhash = e.Blurhash
+		images[i].Colors = e.Colors
+	}
+}
+
+// filterImagesByColor keeps the images whose dominant colors belong to the
+// given color family. Images not indexed yet never match.
+func filterImagesByColor(images []Image, indexer *imageindex.Indexer, family string) []Image {
+	filtered := make([]Image, 0, len(images))
+	for _, img := range images {
+		if e, ok := indexer.Lookup(img.Key); ok && e.Matches(family) {
+			filtered = append(filtered, img)
+		}
+	}
+	return filtered
+}
+
+// forEachListPage walks the whole bucket in key order, calling fn with the
+// objects of each listing page. The objects sharing the key prefix of the
+// last check-ins of a page are held back until the next page, so the
+// renditions of a check-in are always listed together.
+func forEachListPage(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	fn func([]types.Object) error,
+) error {
+	var (
+		token   string
+		pending []types.Object
+	)
+	for {
+		out, err := s3client.ListObjects(ctx, client, cfg.BucketName, "", token)
+		if err != nil {
+			return fmt.Errorf("list objects: %w", err)
+		}
+
+		contents := append(pending, out.Contents...)
+		last := !aws.ToBool(out.IsTruncated) || aws.ToString(out.NextContinuationToken) == ""
+		if !last {
+			contents, pending = splitTrailingCheckins(keyLayout(cfg), contents)
+			// copy, the next append must not overwrite this page
+			pending = append([]types.Object(nil), pending...)
+		}
+
+		if len(contents) > 0 {
+			if err := fn(contents); err != nil {
+				return err
+			}
+		}
+
+		if last {
+			return nil
+		}
+		token = aws.ToString(out.NextContinuationToken)
+	}
+}
+
+// forEachObjectPage walks the whole bucket in key order, calling fn with the
+// check-ins found on each listing page. A check-in is never split.
+func forEachObjectPage(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	fn func([]renditionGroup) error,
+) error {
+	return forEachListPage(ctx, client, cfg, func(contents []types.Object) error {
+		if groups := groupRenditions(keyLayout(cfg), contents); len(groups) > 0 {
+			return fn(groups)
+		}
+		return nil
+	})
+}
+
+// forEachImagePage walks the whole bucket in key order, calling fn with the
+// visible check-ins found on each listing page. Only one page of metadata is
+// held in memory at a time.
+func forEachImagePage(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	fn func([]Image) error,
+) error {
+	return forEachObjectPage(ctx, client, cfg, func(groups []renditionGroup) error {
+		return fn(visibleImages(fetchImages(ctx, client, cfg, groups)))
+	})
+}
+
+// ListImageKeys returns the key of the primary rendition of every check-in
+// of the bucket, without fetching their metadata.
+func ListImageKeys(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) ([]string, error) {
+	var keys []string
+	err := forEachObjectPage(ctx, client, cfg, func(groups []renditionGroup) error {
+		for _, group := range groups {
+			keys = append(keys, *group.primary.Key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// ListMonths returns the months holding at least one check-in, newest
+// first. Only keys are listed, hidden check-ins count.
+func ListMonths(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) ([]time.Time, error) {
+	// a layout may not keep the check-ins of a month together
+	seen := map[time.Time]bool{}
+	err := forEachObjectPage(ctx, client, cfg, func(groups []renditionGroup) error {
+		for _, group := range groups {
+			if m, err := keyLayout(cfg).Month(*group.primary.Key); err == nil {
+				seen[m] = true
+			}
+		}
+		return nil
+	})
+	months := slices.SortedFunc(maps.Keys(seen), func(a, b time.Time) int { return b.Compare(a) })
+	return months, err
+}
+
+// ListMonthImages returns the visible check-ins of a month, newest first.
+func ListMonthImages(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	month time.Time,
+) ([]Image, error) {
+	prefix := keyLayout(cfg).MonthPrefix(month)
+
+	var (
+		contents []types.Object
+		token    string
+	)
+	for {
+		out, err := s3client.ListObjects(ctx, client, cfg.BucketName, prefix, token)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, err)
+		}
+		contents = append(contents, out.Contents...)
+		if !aws.ToBool(out.IsTruncated) || aws.ToString(out.NextContinuationToken) == "" {
+			break
+		}
+		token = aws.ToString(out.NextContinuationToken)
+	}
+
+	images := visibleImages(fetchImages(ctx, client, cfg, groupRenditions(keyLayout(cfg), contents)))
+	sortImagesNewestFirst(images)
+	return images, nil
+}
+
+// ListAllImages loads every visible check-in of the bucket in memory.
+func ListAllImages(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) ([]Image, error) {
+	var images []Image
+	err := forEachImagePage(ctx, client, cfg, func(page []Image) error {
+		images = append(images, page...)
+		return nil
+	})
+	return images, err
+}
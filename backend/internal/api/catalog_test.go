@@ -0,0 +1,280 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestListAllImages(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			if params.ContinuationToken == nil {
+				return &s3.ListObjectsV2Output{
+					Contents: []types.Object{
+						{Key: aws.String("2025/10/01/WEBP/image1.webp")},
+						{Key: aws.String("2025/10/01/JPEG/image1.jpg")},
+					},
+					IsTruncated:           aws.Bool(true),
+					NextContinuationToken: aws.String("next"),
+				}, nil
+			}
+			if got := aws.ToString(params.ContinuationToken); got != "next" {
+				t.Errorf("ContinuationToken = %q, want %q", got, "next")
+			}
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/WEBP/image2.webp")},
+				},
+				IsTruncated: aws.Bool(false),
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{
+				Metadata: map[string]string{"id": aws.ToString(params.Key)},
+			}, nil
+		},
+	}
+
+	images, err := ListAllImages(context.Background(), mockClient, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := len(images); got != 2 {
+		t.Fatalf("expected 2 images, got %d", got)
+	}
+	if images[1].Key != "2025/11/08/WEBP/image2.webp" {
+		t.Errorf("unexpected key on second page: %s", images[1].Key)
+	}
+}
+
+func TestListAllImagesSidecar(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/EXTRA/image1-2.webp")},
+					{Key: aws.String("2025/11/08/WEBP/image1.json")},
+					{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+					{Key: aws.String("2025/11/08/WEBP/image2.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{
+				Metadata: map[string]string{"id": aws.ToString(params.Key), "comment": "short"},
+			}, nil
+		},
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			if got := aws.ToString(params.Key); got != "2025/11/08/WEBP/image1.json" {
+				t.Errorf("unexpected sidecar fetched: %s", got)
+			}
+			sidecar := `{"comment": "a long comment", "price": "6.50", "photos": ["2025/11/08/EXTRA/image1-2.webp", "2025/11/09/EXTRA/image1-3.webp", "extra/image1-4.webp"]}`
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(sidecar))}, nil
+		},
+	}
+
+	images, err := ListAllImages(context.Background(), mockClient, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the extra photo is not a check-in of its own
+	if got := len(images); got != 2 {
+		t.Fatalf("expected 2 images, got %d", got)
+	}
+
+	for _, img := range images {
+		if img.Key != "2025/11/08/WEBP/image1.webp" {
+			if img.Metadata.Comment != "short" {
+				t.Errorf("unexpected comment without sidecar: %q", img.Metadata.Comment)
+			}
+			continue
+		}
+		if img.Metadata.Comment != "a long comment" || img.Metadata.Price != "6.50" {
+			t.Errorf("sidecar not merged: %+v", img.Metadata)
+		}
+		if len(img.Photos) != 1 || img.Photos[0] != "https://test.com/2025/11/08/EXTRA/image1-2.webp" {
+			t.Errorf("unexpected extra photos: %v", img.Photos)
+		}
+		if len(img.Variants) != 1 {
+			t.Errorf("the sidecar must not be a variant: %v", img.Variants)
+		}
+	}
+}
+
+type MockPresignClient struct {
+	MockS3Client
+}
+
+func (m *MockPresignClient) PresignGetObject(
+	ctx context.Context,
+	bucketName, objectKey string,
+	ttl time.Duration,
+) (string, error) {
+	return fmt.Sprintf("https://signed.test/%s/%s?expires=%d", bucketName, objectKey, int(ttl.Seconds())), nil
+}
+
+func TestPhotoURL(t *testing.T) {
+	key := "2025/11/08/WEBP/image1.webp"
+
+	public := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com"}
+	got, err := photoURL(context.Background(), &MockS3Client{}, public, key)
+	if err != nil || got != "https://test.com/"+key {
+		t.Errorf("photoURL() = %q, %v, want public URL", got, err)
+	}
+
+	private := &config.AppConfig{BucketName: "test-bucket", PresignTTL: 15 * time.Minute}
+	got, err = photoURL(context.Background(), &MockPresignClient{}, private, key)
+	if err != nil || got != "https://signed.test/test-bucket/"+key+"?expires=900" {
+		t.Errorf("photoURL() = %q, %v, want presigned URL", got, err)
+	}
+
+	proxied := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com", ImageProxy: true}
+	got, err = photoURL(context.Background(), &MockS3Client{}, proxied, key)
+	if err != nil || got != "/media/"+key {
+		t.Errorf("photoURL() = %q, %v, want proxied URL", got, err)
+	}
+
+	if _, err := photoURL(context.Background(), &MockS3Client{}, private, key); err == nil {
+		t.Errorf("expected an error when the client cannot presign")
+	}
+}
+
+// newMonthsMockClient serves check-ins over two months, filtering the
+// listing by prefix like S3 does.
+func newMonthsMockClient() *MockS3Client {
+	keys := []string{
+		"2025/10/01/JPEG/image1.jpg",
+		"2025/10/01/WEBP/image1.webp",
+		"2025/11/08/WEBP/image2.webp",
+		"2025/11/09/WEBP/image3.webp",
+		"notes.txt",
+	}
+	return &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			out := &s3.ListObjectsV2Output{}
+			for _, key := range keys {
+				if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+					out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+				}
+			}
+			return out, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			// the key date, at noon
+			date := strings.ReplaceAll(aws.ToString(params.Key)[:10], "/", "-") + " 12:00:00"
+			return &s3.HeadObjectOutput{Metadata: map[string]string{"date": date}}, nil
+		},
+	}
+}
+
+func TestListMonths(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com"}
+
+	months, err := ListMonths(context.Background(), newMonthsMockClient(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []time.Time{
+		time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, time.October, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if len(months) != len(want) || !months[0].Equal(want[0]) || !months[1].Equal(want[1]) {
+		t.Errorf("ListMonths() = %v, want %v", months, want)
+	}
+}
+
+func TestListMonthsInterleaved(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	client := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			// not every store lists keys in order
+			var contents []types.Object
+			for _, key := range []string{
+				"2025/10/12/WEBP/1.webp",
+				"2025/11/08/WEBP/2.webp",
+				"2025/10/20/WEBP/3.webp",
+				"2024/12/31/WEBP/4.webp",
+			} {
+				contents = append(contents, types.Object{Key: aws.String(key)})
+			}
+			return &s3.ListObjectsV2Output{Contents: contents}, nil
+		},
+	}
+
+	months, err := ListMonths(context.Background(), client, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []time.Time{
+		time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, time.October, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !slices.EqualFunc(months, want, time.Time.Equal) {
+		t.Errorf("ListMonths() = %v, want %v", months, want)
+	}
+}
+
+func TestListMonthImages(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com"}
+
+	images, err := ListMonthImages(context.Background(), newMonthsMockClient(), cfg, time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(images) != 2 || images[0].Key != "2025/11/09/WEBP/image3.webp" || images[1].Key != "2025/11/08/WEBP/image2.webp" {
+		t.Errorf("ListMonthImages() = %+v, want the November check-ins newest first", images)
+	}
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/geo"
+	"beers/backend/internal/s3client"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// S3 limits the user metadata of an object to 2 KB, keys included
+	maxMetadataSize = 2 << 10
+	maxPatchSize    = 64 << 10
+)
+
+// RequireAdmin only lets through requests bearing the admin token. The
+// endpoints it guards are disabled when no token is configured.
+func RequireAdmin(cfg *config.AppConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.AdminToken == "" {
+			writeJSONError(w, http.StatusForbidden, "Admin API is disabled")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="beers"`)
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validateText accepts free text, line breaks only where allowed.
+func validateText(multiline bool) func(string) error {
+	return func(s string) error {
+		if !utf8.ValidString(s) {
+			return errors.New("not valid UTF-8")
+		}
+		for _, r := range s {
+			if r == '\n' && multiline {
+				continue
+			}
+			if unicode.IsControl(r) {
+				return errors.New("contains control characters")
+			}
+		}
+		return nil
+	}
+}
+
+// validateNumber accepts a number between min and max.
+func validateNumber(min, max float64) func(string) error {
+	return func(s string) error {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || n < min || n > max {
+			return fmt.Errorf("must be a number between %g and %g", min, max)
+		}
+		return nil
+	}
+}
+
+// editableFields maps the metadata headers which can be edited to their
+// validation. The check-in ID is not editable.
+var editableFields = map[string]func(string) error{
+	"beer":            validateText(false),
+	"brewery":         validateText(false),
+	"brewery_country": validateText(false),
+	"comment":         validateText(true),
+	"rating":          validateNumber(0, 5),
+	"venue":           validateText(false),
+	"city":            validateText(false),
+	"state":           validateText(false),
+	"country":         validateText(false),
+	"latlng": func(s string) error {
+		_, err := geo.ParseLatLng(s)
+		return err
+	},
+	"date": func(s string) error {
+		_, err := time.Parse(checkinDateLayout, s)
+		return err
+	},
+	"style": validateText(false),
+	"abv":   validateNumber(0, 100),
+}
+
+// validateChanges checks every changed field. Empty values remove a field.
+func validateChanges(changes map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(changes)) {
+		validate, ok := editableFields[name]
+		if !ok {
+			return fmt.Errorf("field %s cannot be edited", name)
+		}
+		if changes[name] == "" {
+			continue
+		}
+		if err := validate(changes[name]); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// encodeMetadataValue makes a value safe for an S3 metadata header, which
+// only carries ASCII: other text is RFC 2047 encoded, the way the recorder
+// stores it.
+func encodeMetadataValue(s string) string {
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// applyChanges returns the metadata of an object once edited.
+func applyChanges(metadata, changes map[string]string) map[string]string {
+	updated := make(map[string]string, len(metadata)+len(changes))
+	for k, v := range metadata {
+		updated[strings.ToLower(k)] = v
+	}
+	for k, v := range changes {
+		if v == "" {
+			delete(updated, k)
+			continue
+		}
+		updated[k] = encodeMetadataValue(v)
+	}
+	return updated
+}
+
+func metadataSize(metadata map[string]string) int {
+	size := 0
+	for k, v := range metadata {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// isNotFound reports whether err means the object does not exist.
+func isNotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	return errors.As(err, &noSuchKey) || errors.As(err, &notFound)
+}
+
+// isPreconditionFailed reports whether a conditional request was rejected.
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed"
+}
+
+var (
+	errCheckinNotFound  = errors.New("check-in not found")
+	errMetadataTooLarge = errors.New("metadata too large")
+	errCheckinChanged   = errors.New("check-in changed during the update")
+)
+
+// checkinGroup returns the renditions of the check-in a rendition key
+// belongs to.
+func checkinGroup(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+) (renditionGroup, error) {
+	group, found, err := findRenditionGroup(ctx, client, cfg, key)
+	if err != nil {
+		return renditionGroup{}, err
+	}
+	if !found || !hasRendition(group, key) {
+		return renditionGroup{}, errCheckinNotFound
+	}
+	return group, nil
+}
+
+// sidecarFields returns the changed fields which are set in the sidecar,
+// where they take precedence over the metadata headers.
+func sidecarFields(sidecar []byte, changes map[string]string) ([]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(sidecar, &fields); err != nil {
+		return nil, err
+	}
+	var shadowed []string
+	for field := range changes {
+		if _, ok := fields[field]; ok {
+			shadowed = append(shadowed, field)
+		}
+	}
+	slices.Sort(shadowed)
+	return shadowed, nil
+}
+
+// readUnshadowedSidecar returns the sidecar of a check-in, if any, after
+// checking it sets none of the changed fields, which would take precedence
+// over the headers. Otherwise it writes the error response and returns false.
+func readUnshadowedSidecar(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	w http.ResponseWriter,
+	group renditionGroup,
+	changes map[string]string,
+) ([]byte, bool) {
+	if group.sidecar == "" {
+		return nil, true
+	}
+	sidecar, err := fetchSidecar(ctx, client, cfg, group.sidecar)
+	if err != nil {
+		writeUpdateError(w, fmt.Errorf("get %s: %w", group.sidecar, err))
+		return nil, false
+	}
+	shadowed, err := sidecarFields(sidecar, changes)
+	if err != nil {
+		log.Printf("invalid sidecar metadata %s: %v", group.sidecar, err)
+		writeJSONError(w, http.StatusConflict, "Invalid sidecar, fix it first")
+		return nil, false
+	}
+	if len(shadowed) > 0 {
+		writeJSONError(w, http.StatusConflict, fmt.Sprintf(
+			"Set in the sidecar, edit it there: %s", strings.Join(shadowed, ", ")))
+		return nil, false
+	}
+	return sidecar, true
+}
+
+// updateCheckinMetadata applies changes to the metadata of a photo and
+// returns its new metadata. Changes are not validated.
+func updateCheckinMetadata(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+	changes map[string]string,
+) (map[string]string, error) {
+	head, err := s3client.GetObjectMetadata(ctx, client, cfg.BucketName, key)
+	if isNotFound(err) {
+		return nil, errCheckinNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("head %s: %w", key, err)
+	}
+	return replaceCheckinMetadata(ctx, client, cfg, key, head, changes)
+}
+
+// replaceCheckinMetadata applies changes to the metadata read by head. The
+// update fails with errCheckinChanged if the photo changed since.
+func replaceCheckinMetadata(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+	head *s3.HeadObjectOutput,
+	changes map[string]string,
+) (map[string]string, error) {
+	metadata := applyChanges(head.Metadata, changes)
+	if metadataSize(metadata) > maxMetadataSize {
+		return nil, errMetadataTooLarge
+	}
+
+	err := s3client.ReplaceObjectMetadata(ctx, client, cfg.BucketName, key, head, metadata)
+	if isPreconditionFailed(err) {
+		return nil, errCheckinChanged
+	}
+	if err != nil {
+		return nil, fmt.Errorf("copy %s: %w", key, err)
+	}
+	log.Printf("updated %s: %s", key, strings.Join(slices.Sorted(maps.Keys(changes)), ", "))
+	return metadata, nil
+}
+
+// writeUpdateError reports a failed metadata update to the client.
+func writeUpdateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errCheckinNotFound):
+		writeJSONError(w, http.StatusNotFound, "Check-in not found")
+	case errors.Is(err, errMetadataTooLarge):
+		writeJSONError(w, http.StatusBadRequest, "Metadata too large, use a sidecar")
+	case errors.Is(err, errCheckinChanged):
+		writeJSONError(w, http.StatusConflict, "Check-in changed during the update, retry")
+	default:
+		log.Printf("error updating metadata: %v", err)
+		writeJSONError(w, http.StatusBadGateway, "Error updating check-in")
+	}
+}
+
+// PatchCheckin edits the metadata of a check-in photo. The body is a JSON
+// object of the fields to change, an empty value removes a field. Any
+// rendition of the check-in can be given, its primary is edited. Fields set
+// in the sidecar of the check-in are refused, they are edited there.
+func PatchCheckin(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		if !isRenditionKey(keyLayout(cfg), key) {
+			writeJSONError(w, http.StatusNotFound, "Check-in not found")
+			return
+		}
+
+		var changes map[string]string
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPatchSize)).Decode(&changes); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+		if len(changes) == 0 {
+			writeJSONError(w, http.StatusBadRequest, "No changes")
+			return
+		}
+		if err := validateChanges(changes); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		group, err := checkinGroup(ctx, client, cfg, key)
+		if err != nil {
+			writeUpdateError(w, err)
+			return
+		}
+		sidecar, ok := readUnshadowedSidecar(ctx, client, cfg, w, group, changes)
+		if !ok {
+			return
+		}
+
+		metadata, err := updateCheckinMetadata(ctx, client, cfg, aws.ToString(group.primary.Key), changes)
+		if err != nil {
+			writeUpdateError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(newCheckinMetadata(metadata, sidecar)); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{"disabled", "", "Bearer secret", http.StatusForbidden},
+		{"missing token", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer guess", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "Basic secret", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.AppConfig{AdminToken: tt.token}
+			req := httptest.NewRequest(http.MethodPatch, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			RequireAdmin(cfg, next).ServeHTTP(rr, req)
+			if rr.Code != tt.status {
+				t.Errorf("status = %v, want %v", rr.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestValidateChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		changes   map[string]string
+		expectErr bool
+	}{
+		{name: "text", changes: map[string]string{"venue": "Brasserie Cantillon", "comment": "two\nlines"}},
+		{name: "numbers", changes: map[string]string{"rating": "4.25", "abv": "5"}},
+		{name: "location and date", changes: map[string]string{"latlng": "50.8503,4.3517", "date": "2025-11-08 12:00:00"}},
+		{name: "removed field", changes: map[string]string{"state": ""}},
+		{name: "id", changes: map[string]string{"id": "1"}, expectErr: true},
+		{name: "unknown field", changes: map[string]string{"colour": "amber"}, expectErr: true},
+		{name: "rating out of range", changes: map[string]string{"rating": "6"}, expectErr: true},
+		{name: "invalid date", changes: map[string]string{"date": "yesterday"}, expectErr: true},
+		{name: "line break", changes: map[string]string{"venue": "two\nlines"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateChanges(tt.changes); (err != nil) != tt.expectErr {
+				t.Errorf("validateChanges() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func newPatchMockClient(t *testing.T, copied *s3.CopyObjectInput, copyErr error) *MockS3Client {
+	t.Helper()
+	return &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/AVIF/image1.avif")},
+					{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			if aws.ToString(params.Key) != "2025/11/08/WEBP/image1.webp" {
+				return nil, &types.NotFound{}
+			}
+			return &s3.HeadObjectOutput{
+				ETag:        aws.String(`"abc123"`),
+				ContentType: aws.String("image/webp"),
+				Metadata: map[string]string{
+					"id":    "123",
+					"venue": "Wrong Venue",
+					"state": "Brussels",
+				},
+			}, nil
+		},
+		CopyObjectFunc: func(
+			ctx context.Context,
+			params *s3.CopyObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.CopyObjectOutput, error) {
+			*copied = *params
+			if copyErr != nil {
+				return nil, copyErr
+			}
+			return &s3.CopyObjectOutput{}, nil
+		},
+	}
+}
+
+func TestPatchCheckin(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", AdminToken: "secret"}
+
+	var copied s3.CopyObjectInput
+	mux := http.NewServeMux()
+	mux.Handle("PATCH /api/checkins/{key...}", PatchCheckin(context.Background(), newPatchMockClient(t, &copied, nil), cfg))
+
+	body := `{"venue": "Brasserie Cantillon", "city": "Bruxelles–Anderlecht", "rating": "4.5", "state": ""}`
+	req := httptest.NewRequest(http.MethodPatch, "/api/checkins/2025/11/08/WEBP/image1.webp", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+
+	if copied.MetadataDirective != types.MetadataDirectiveReplace {
+		t.Errorf("MetadataDirective = %q, want REPLACE", copied.MetadataDirective)
+	}
+	if got := aws.ToString(copied.CopySource); got != "test-bucket/2025/11/08/WEBP/image1.webp" {
+		t.Errorf("CopySource = %q", got)
+	}
+	if got := aws.ToString(copied.CopySourceIfMatch); got != `"abc123"` {
+		t.Errorf("CopySourceIfMatch = %q, want the ETag read", got)
+	}
+	if got := aws.ToString(copied.ContentType); got != "image/webp" {
+		t.Errorf("ContentType = %q, want it preserved", got)
+	}
+
+	md := copied.Metadata
+	if md["id"] != "123" || md["venue"] != "Brasserie Cantillon" || md["rating"] != "4.5" {
+		t.Errorf("unexpected metadata: %v", md)
+	}
+	if _, ok := md["state"]; ok {
+		t.Errorf("expected state to be removed, got %q", md["state"])
+	}
+	if !strings.HasPrefix(md["city"], "=?utf-8?q?") {
+		t.Errorf("expected city to be RFC 2047 encoded, got %q", md["city"])
+	}
+
+	var resp CheckinMetadata
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if resp.City != "Bruxelles–Anderlecht" || resp.Venue != "Brasserie Cantillon" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestPatchCheckinRendition(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", AdminToken: "secret"}
+
+	var copied s3.CopyObjectInput
+	mux := http.NewServeMux()
+	mux.Handle("PATCH /api/checkins/{key...}", PatchCheckin(context.Background(), newPatchMockClient(t, &copied, nil), cfg))
+
+	// the metadata is read from the primary rendition, so it is edited there
+	req := httptest.NewRequest(http.MethodPatch, "/api/checkins/2025/11/08/AVIF/image1.avif", strings.NewReader(`{"rating": "4"}`))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if got := aws.ToString(copied.Key); got != "2025/11/08/WEBP/image1.webp" {
+		t.Errorf("edited %q, want the primary rendition", got)
+	}
+}
+
+func TestPatchCheckinErrors(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", AdminToken: "secret"}
+	conflict := &smithy.GenericAPIError{Code: "PreconditionFailed"}
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		copyErr error
+		status  int
+	}{
+		{"invalid JSON", "/api/checkins/2025/11/08/WEBP/image1.webp", `{`, nil, http.StatusBadRequest},
+		{"no changes", "/api/checkins/2025/11/08/WEBP/image1.webp", `{}`, nil, http.StatusBadRequest},
+		{"invalid rating", "/api/checkins/2025/11/08/WEBP/image1.webp", `{"rating": "great"}`, nil, http.StatusBadRequest},
+		{"too large", "/api/checkins/2025/11/08/WEBP/image1.webp", `{"comment": "` + strings.Repeat("a", 3000) + `"}`, nil, http.StatusBadRequest},
+		{"not a photo", "/api/checkins/notes.txt", `{"rating": "4"}`, nil, http.StatusNotFound},
+		{"missing", "/api/checkins/2025/11/08/WEBP/missing.webp", `{"rating": "4"}`, nil, http.StatusNotFound},
+		{"concurrent update", "/api/checkins/2025/11/08/WEBP/image1.webp", `{"rating": "4"}`, conflict, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var copied s3.CopyObjectInput
+			mux := http.NewServeMux()
+			mux.Handle("PATCH /api/checkins/{key...}", PatchCheckin(context.Background(), newPatchMockClient(t, &copied, tt.copyErr), cfg))
+
+			req := httptest.NewRequest(http.MethodPatch, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+			if rr.Code != tt.status {
+				t.Errorf("status = %v, want %v: %s", rr.Code, tt.status, rr.Body)
+			}
+		})
+	}
+}
+
+func TestPatchCheckinSidecar(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", AdminToken: "secret"}
+
+	var copied s3.CopyObjectInput
+	client := newPatchMockClient(t, &copied, nil)
+	client.ListObjectsV2Func = func(
+		ctx context.Context,
+		params *s3.ListObjectsV2Input,
+		optFns ...func(*s3.Options),
+	) (*s3.ListObjectsV2Output, error) {
+		return &s3.ListObjectsV2Output{
+			Contents: []types.Object{
+				{Key: aws.String("2025/11/08/WEBP/image1.json")},
+				{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+			},
+		}, nil
+	}
+	client.GetObjectFunc = func(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error) {
+		body := `{"venue": "Moeder Lambic", "price": "4.50"}`
+		return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("PATCH /api/checkins/{key...}", PatchCheckin(context.Background(), client, cfg))
+
+	// the sidecar would still win over the edited header
+	req := httptest.NewRequest(http.MethodPatch, "/api/checkins/2025/11/08/WEBP/image1.webp", strings.NewReader(`{"venue": "Cantillon"}`))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("status = %v, want %v: %s", rr.Code, http.StatusConflict, rr.Body)
+	}
+	if !strings.Contains(rr.Body.String(), "venue") {
+		t.Errorf("expected the shadowed field to be named: %s", rr.Body)
+	}
+	if copied.Key != nil {
+		t.Errorf("expected no update, edited %s", aws.ToString(copied.Key))
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/checkins/2025/11/08/WEBP/image1.webp", strings.NewReader(`{"rating": "4"}`))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	var resp CheckinMetadata
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if resp.Rating != "4" || resp.Venue != "Moeder Lambic" || resp.Price != "4.50" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
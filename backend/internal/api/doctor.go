@@ -0,0 +1,216 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/geo"
+	"beers/backend/internal/s3client"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+)
+
+// outcomes of a diagnostic check
+const (
+	DiagnosisOK   = "ok"
+	DiagnosisWarn = "warn"
+	DiagnosisFail = "fail"
+	// not run because an earlier check failed
+	DiagnosisSkip = "skip"
+)
+
+type Diagnosis struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// explainStorageError turns the errors of the first bucket request into
+// advice on the setting most likely wrong.
+func explainStorageError(cfg *config.AppConfig, err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchBucket":
+			return fmt.Sprintf("bucket %q does not exist in account %s, check BUCKET_NAME", cfg.BucketName, cfg.AccountID)
+		case "InvalidAccessKeyId", "Unauthorized":
+			return "the access key is unknown, check R2_ACCESS_KEY_ID"
+		case "SignatureDoesNotMatch":
+			return "the secret does not match the access key, check R2_SECRET_ACCESS_KEY"
+		case "AccessDenied", "Forbidden":
+			return fmt.Sprintf("the token is not allowed to read bucket %q, grant it Object Read permissions", cfg.BucketName)
+		}
+		return fmt.Sprintf("%s: %s", apiErr.ErrorCode(), apiErr.ErrorMessage())
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return fmt.Sprintf("cannot resolve %s, check R2_ACCOUNT_ID", dnsErr.Name)
+	}
+	return err.Error()
+}
+
+// checkSettings reports the optional settings which are set but unusable.
+func checkSettings(cfg *config.AppConfig) []Diagnosis {
+	var ds []Diagnosis
+	add := func(status, format string, args ...any) {
+		ds = append(ds, Diagnosis{Check: "settings", Status: status, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if cfg.PublicURL != "" {
+		if u, err := url.Parse(cfg.PublicURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			add(DiagnosisFail, "R2_PUBLIC_URL %q is not an absolute http(s) URL", cfg.PublicURL)
+		}
+	}
+	if cfg.HomeLatLng != "" {
+		if _, err := geo.ParseLatLng(cfg.HomeLatLng); err != nil {
+			add(DiagnosisWarn, "HOME_LATLNG %q is invalid, trips are disabled: %v", cfg.HomeLatLng, err)
+		}
+	}
+	if cfg.AdminToken != "" && len(cfg.AdminToken) < 16 {
+		add(DiagnosisWarn, "ADMIN_TOKEN is only %d characters long, use a long random string", len(cfg.AdminToken))
+	}
+
+	// only looked at, the server creates it
+	switch info, err := os.Stat(cfg.CacheDir); {
+	case errors.Is(err, fs.ErrNotExist):
+		add(DiagnosisWarn, "CACHE_DIR %s does not exist yet, the server will create it", cfg.CacheDir)
+	case err != nil:
+		add(DiagnosisFail, "CACHE_DIR %s cannot be read: %v", cfg.CacheDir, err)
+	case !info.IsDir():
+		add(DiagnosisFail, "CACHE_DIR %s is not a directory", cfg.CacheDir)
+	default:
+		if f, err := os.CreateTemp(cfg.CacheDir, ".doctor-*"); err != nil {
+			add(DiagnosisFail, "CACHE_DIR %s is not writable: %v", cfg.CacheDir, err)
+		} else {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+
+	if len(ds) == 0 {
+		add(DiagnosisOK, "settings are valid")
+	}
+	return ds
+}
+
+// checkPhotoURL fetches a photo the way browsers will, and checks an image
+// comes back.
+func checkPhotoURL(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	httpClient *http.Client,
+	key string,
+) Diagnosis {
+	d := Diagnosis{Check: "photo URL"}
+	fail := func(format string, args ...any) Diagnosis {
+		d.Status, d.Detail = DiagnosisFail, fmt.Sprintf(format, args...)
+		return d
+	}
+
+	if cfg.ImageProxy {
+		// served by the backend itself from the bucket
+		out, err := s3client.GetObject(ctx, client, cfg.BucketName, key)
+		if err != nil {
+			return fail("cannot download %s: %s", key, explainStorageError(cfg, err))
+		}
+		out.Body.Close()
+		d.Status, d.Detail = DiagnosisOK, "photos are proxied through /media/"
+		return d
+	}
+
+	u, err := photoURL(ctx, client, cfg, key)
+	if err != nil {
+		return fail("cannot build the URL of %s: %v", key, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fail("invalid photo URL %s: %v", u, err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fail("cannot fetch %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	via := "R2_PUBLIC_URL"
+	if cfg.PublicURL == "" {
+		via = "a presigned URL"
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fail("%s through %s returned %s, is public access enabled on the bucket domain?", key, via, resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/") {
+		return fail("%s through %s is served as %q, not as an image", key, via, ct)
+	}
+	d.Status, d.Detail = DiagnosisOK, fmt.Sprintf("%s is served through %s", key, via)
+	return d
+}
+
+// Diagnose checks the settings, then that the bucket can be listed, that
+// photo metadata can be read and that browsers can load the photos. Checks
+// depending on a failed one are skipped.
+func Diagnose(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	httpClient *http.Client,
+) []Diagnosis {
+	ds := checkSettings(cfg)
+	skip := func(checks ...string) {
+		for _, c := range checks {
+			ds = append(ds, Diagnosis{Check: c, Status: DiagnosisSkip, Detail: "needs the checks above to pass"})
+		}
+	}
+
+	out, err := s3client.ListObjects(ctx, client, cfg.BucketName, "", "")
+	if err != nil {
+		ds = append(ds, Diagnosis{Check: "list", Status: DiagnosisFail, Detail: explainStorageError(cfg, err)})
+		skip("head", "photo URL")
+		return ds
+	}
+
+	var key string
+	for _, obj := range out.Contents {
+		if k := aws.ToString(obj.Key); isDecodableKey(keyLayout(cfg), k) {
+			key = k
+			break
+		}
+	}
+	if key == "" {
+		detail := fmt.Sprintf("bucket %q is reachable but holds no photo named like %s", cfg.BucketName, keyLayout(cfg))
+		if len(out.Contents) > 0 {
+			detail += fmt.Sprintf(", e.g. %s; check KEY_LAYOUT", aws.ToString(out.Contents[0].Key))
+		}
+		ds = append(ds, Diagnosis{Check: "list", Status: DiagnosisWarn, Detail: detail})
+		skip("head", "photo URL")
+		return ds
+	}
+	ds = append(ds, Diagnosis{
+		Check:  "list",
+		Status: DiagnosisOK,
+		Detail: fmt.Sprintf("bucket %q lists %d objects on its first page", cfg.BucketName, len(out.Contents)),
+	})
+
+	head, err := s3client.GetObjectMetadata(ctx, client, cfg.BucketName, key)
+	switch {
+	case err != nil:
+		ds = append(ds, Diagnosis{Check: "head", Status: DiagnosisFail, Detail: explainStorageError(cfg, err)})
+	case len(head.Metadata) == 0:
+		ds = append(ds, Diagnosis{Check: "head", Status: DiagnosisWarn, Detail: key + " has no check-in metadata"})
+	default:
+		ds = append(ds, Diagnosis{Check: "head", Status: DiagnosisOK, Detail: fmt.Sprintf("%s has %d metadata fields", key, len(head.Metadata))})
+	}
+
+	return append(ds, checkPhotoURL(ctx, client, cfg, httpClient, key))
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestExplainStorageError(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "beers", AccountID: "abc"}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"missing bucket", &smithy.GenericAPIError{Code: "NoSuchBucket"}, "check BUCKET_NAME"},
+		{"unknown key", &smithy.GenericAPIError{Code: "InvalidAccessKeyId"}, "check R2_ACCESS_KEY_ID"},
+		{"wrong secret", &smithy.GenericAPIError{Code: "SignatureDoesNotMatch"}, "check R2_SECRET_ACCESS_KEY"},
+		{"no permission", &smithy.GenericAPIError{Code: "AccessDenied"}, "Object Read"},
+		{"unknown account", &net.DNSError{Name: "abc.r2.cloudflarestorage.com"}, "check R2_ACCOUNT_ID"},
+		{"other", errors.New("connection reset"), "connection reset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explainStorageError(cfg, tt.err); !strings.Contains(got, tt.want) {
+				t.Errorf("explainStorageError() = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func newDoctorMockClient(keys []string, listErr error) *MockS3Client {
+	return &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			if listErr != nil {
+				return nil, listErr
+			}
+			out := &s3.ListObjectsV2Output{}
+			for _, key := range keys {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+			}
+			return out, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{Metadata: map[string]string{"id": "1"}}, nil
+		},
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/private.webp") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "image/webp")
+		w.Write([]byte("RIFF"))
+	}))
+	defer public.Close()
+
+	tests := []struct {
+		name    string
+		keys    []string
+		listErr error
+		// status of the list, head and photo URL checks
+		want []string
+	}{
+		{"healthy", []string{"2025/11/08/WEBP/1.webp"}, nil, []string{DiagnosisOK, DiagnosisOK, DiagnosisOK}},
+		{"missing bucket", nil, &smithy.GenericAPIError{Code: "NoSuchBucket"}, []string{DiagnosisFail, DiagnosisSkip, DiagnosisSkip}},
+		{"other layout", []string{"photos/1.webp"}, nil, []string{DiagnosisWarn, DiagnosisSkip, DiagnosisSkip}},
+		{"private bucket", []string{"2025/11/08/WEBP/private.webp"}, nil, []string{DiagnosisOK, DiagnosisOK, DiagnosisFail}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.AppConfig{BucketName: "beers", PublicURL: public.URL, CacheDir: t.TempDir()}
+			ds := Diagnose(context.Background(), newDoctorMockClient(tt.keys, tt.listErr), cfg, public.Client())
+
+			if ds[0].Check != "settings" || ds[0].Status != DiagnosisOK {
+				t.Errorf("settings = %+v, want ok", ds[0])
+			}
+			var got []string
+			for _, d := range ds[1:] {
+				got = append(got, d.Status)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("statuses = %v, want %v: %+v", got, tt.want, ds)
+			}
+		})
+	}
+}
+
+func TestCheckSettings(t *testing.T) {
+	cfg := &config.AppConfig{
+		PublicURL:  "bucket.example.com",
+		HomeLatLng: "home",
+		AdminToken: "secret",
+		CacheDir:   t.TempDir(),
+	}
+	ds := checkSettings(cfg)
+
+	want := []string{DiagnosisFail, DiagnosisWarn, DiagnosisWarn}
+	if len(ds) != len(want) {
+		t.Fatalf("checkSettings() = %+v, want %d problems", ds, len(want))
+	}
+	for i, d := range ds {
+		if d.Status != want[i] {
+			t.Errorf("problem %d = %+v, want status %s", i, d, want[i])
+		}
+	}
+}
+
+func TestCheckSettingsCacheDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	ds := checkSettings(&config.AppConfig{CacheDir: dir})
+	if len(ds) != 1 || ds[0].Status != DiagnosisWarn {
+		t.Errorf("checkSettings() = %+v, want a warning", ds)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Errorf("expected the cache directory not to be created")
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0o644)
+	if ds := checkSettings(&config.AppConfig{CacheDir: file}); len(ds) != 1 || ds[0].Status != DiagnosisFail {
+		t.Errorf("checkSettings() = %+v, want a failure", ds)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/imageindex"
+	"beers/backend/internal/s3client"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// DefaultDuplicateThreshold is the largest number of differing hash bits for
+// two photos to be reported as duplicates.
+const DefaultDuplicateThreshold = 6
+
+type DuplicatePhoto struct {
+	Key string `json:"key"`
+	URL string `json:"url"`
+}
+
+type DuplicateGroup struct {
+	Distance int              `json:"distance"`
+	Photos   []DuplicatePhoto `json:"photos"`
+}
+
+type DuplicatesResponse struct {
+	Groups []DuplicateGroup `json:"groups"`
+}
+
+// FindDuplicates reports the groups of near-duplicate photos among the
+// indexed ones. Photos of hidden check-ins, or deleted since they were
+// indexed, are left out.
+func FindDuplicates(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	indexer *imageindex.Indexer,
+	threshold int,
+) []DuplicateGroup {
+	// a photo may belong to several groups
+	visible := map[string]bool{}
+	isVisible := func(key string) bool {
+		if v, ok := visible[key]; ok {
+			return v
+		}
+		head, err := s3client.GetObjectMetadata(ctx, client, cfg.BucketName, key)
+		if err != nil && !isNotFound(err) {
+			log.Printf("error getting metadata %s: %v", key, err)
+		}
+		visible[key] = err == nil && !newCheckinMetadata(head.Metadata, nil).Hidden
+		return visible[key]
+	}
+
+	groups := []DuplicateGroup{}
+	for _, g := range indexer.Duplicates(threshold) {
+		var keys []string
+		for _, key := range g.Keys {
+			if isVisible(key) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) < 2 {
+			continue
+		}
+
+		group := DuplicateGroup{Distance: g.Distance}
+		for _, key := range keys {
+			u, err := photoURL(ctx, client, cfg, key)
+			if err != nil {
+				log.Printf("failed to build URL of %s: %v", key, err)
+			}
+			group.Photos = append(group.Photos, DuplicatePhoto{Key: key, URL: u})
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// GetDuplicates lists near-duplicate photos. Only indexed photos are
+// compared: those the server indexed while serving the journal, and those
+// the sync and duplicates commands saved to the shared index file.
+func GetDuplicates(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	indexer *imageindex.Indexer,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		threshold := DefaultDuplicateThreshold
+		if s := r.URL.Query().Get("threshold"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 || n > 64 {
+				writeJSONError(w, http.StatusBadRequest, "Invalid threshold")
+				return
+			}
+			threshold = n
+		}
+
+		resp := DuplicatesResponse{Groups: FindDuplicates(ctx, client, cfg, indexer, threshold)}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/imageindex"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestGetDuplicates(t *testing.T) {
+	cfg := &config.AppConfig{PublicURL: "https://test.com"}
+
+	idx, err := imageindex.Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	idx.Put("2025/11/08/WEBP/a.webp", imageindex.Entry{DHash: "ff00ff00ff00ff00"})
+	idx.Put("2025/11/08/WEBP/b.webp", imageindex.Entry{DHash: "ff00ff00ff00ff01"})
+	idx.Put("2025/11/09/WEBP/c.webp", imageindex.Entry{DHash: "00ff00ff00ff00ff"})
+	// the duplicate of c is hidden
+	idx.Put("2025/11/09/WEBP/d.webp", imageindex.Entry{DHash: "00ff00ff00ff00fe"})
+	mockClient := &MockS3Client{
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			if aws.ToString(params.Key) == "2025/11/09/WEBP/d.webp" {
+				return &s3.HeadObjectOutput{Metadata: map[string]string{"hidden": "true"}}, nil
+			}
+			return &s3.HeadObjectOutput{}, nil
+		},
+	}
+	handler := GetDuplicates(
+		context.Background(),
+		mockClient,
+		cfg,
+		imageindex.NewIndexer(mockClient, "test-bucket", idx),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/duplicates", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("status = %v, want %v", status, http.StatusOK)
+	}
+
+	var resp DuplicatesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if got := len(resp.Groups); got != 1 {
+		t.Fatalf("expected 1 group, got %d", got)
+	}
+	g := resp.Groups[0]
+	if g.Distance != 1 || len(g.Photos) != 2 {
+		t.Errorf("unexpected group: %+v", g)
+	}
+	if g.Photos[0].URL != "https://test.com/2025/11/08/WEBP/a.webp" {
+		t.Errorf("unexpected photo URL: %s", g.Photos[0].URL)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/duplicates?threshold=-1", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("status = %v, want %v", status, http.StatusBadRequest)
+	}
+}
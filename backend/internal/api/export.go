@@ -0,0 +1,647 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/geo"
+	"beers/backend/internal/keylayout"
+	"beers/backend/internal/s3client"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// exportWriter serializes check-ins one at a time, so exports can be streamed
+// without holding the whole journal in memory.
+type exportWriter interface {
+	Write(img Image) error
+	Close() error
+}
+
+// objectFetcher opens the content of a bucket object.
+type objectFetcher func(key string) (io.ReadCloser, error)
+
+// exportSource describes the bucket being exported, for formats which embed
+// the photos themselves.
+type exportSource struct {
+	fetch  objectFetcher
+	layout *keylayout.Layout
+}
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer, src exportSource) (exportWriter, error)
+	// files kept or subscribed to, whose photo links must not expire
+	lasting bool
+	// backups holding every photo, only made by the export command as each
+	// one fetches the whole bucket; hidden check-ins are kept, flagged in
+	// their metadata
+	backup bool
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv": {
+		contentType: "text/csv; charset=UTF-8",
+		extension:   "csv",
+		newWriter:   newCSVWriter,
+	},
+	"jsonl": {
+		contentType: "application/x-ndjson; charset=UTF-8",
+		extension:   "jsonl",
+		newWriter:   newJSONLWriter,
+	},
+	"kml": {
+		contentType: "application/vnd.google-earth.kml+xml",
+		extension:   "kml",
+		newWriter:   newKMLWriter,
+		lasting:     true,
+	},
+	"gpx": {
+		contentType: "application/gpx+xml",
+		extension:   "gpx",
+		newWriter:   newGPXWriter,
+		lasting:     true,
+	},
+	"ics": {
+		contentType: "text/calendar; charset=UTF-8",
+		extension:   "ics",
+		newWriter:   newICalWriter,
+		lasting:     true,
+	},
+	"zip": {
+		contentType: "application/zip",
+		extension:   "zip",
+		newWriter:   newArchiveWriter,
+		backup:      true,
+	},
+}
+
+type exportFilter struct {
+	from   time.Time
+	to     time.Time
+	tripID string
+}
+
+var errInvalidDate = errors.New("invalid date")
+
+// parseExportFilter reads the optional from/to (YYYY-MM-DD, inclusive) and
+// trip query parameters.
+func parseExportFilter(q url.Values) (exportFilter, error) {
+	var f exportFilter
+	if s := q.Get("from"); s != "" {
+		t, err := time.Parse(tripDateLayout, s)
+		if err != nil {
+			return f, fmt.Errorf("%w: from %v", errInvalidDate, err)
+		}
+		f.from = t
+	}
+	if s := q.Get("to"); s != "" {
+		t, err := time.Parse(tripDateLayout, s)
+		if err != nil {
+			return f, fmt.Errorf("%w: to %v", errInvalidDate, err)
+		}
+		f.to = t.AddDate(0, 0, 1)
+	}
+	f.tripID = q.Get("trip")
+	return f, nil
+}
+
+func (f exportFilter) hasDateRange() bool {
+	return !f.from.IsZero() || !f.to.IsZero()
+}
+
+func (f exportFilter) match(img Image) bool {
+	if !f.hasDateRange() {
+		return true
+	}
+	at, err := parseCheckinDate(img.Metadata)
+	if err != nil {
+		return false
+	}
+	if !f.from.IsZero() && at.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && !at.Before(f.to) {
+		return false
+	}
+	return true
+}
+
+// checkinTitle returns a short "beer by brewery" description of a check-in.
+func checkinTitle(md CheckinMetadata) string {
+	switch {
+	case md.Beer != "" && md.Brewery != "":
+		return md.Beer + " by " + md.Brewery
+	case md.Beer != "":
+		return md.Beer
+	default:
+		return md.ID
+	}
+}
+
+// checkinPlace joins the venue, city and country of a check-in.
+func checkinPlace(md CheckinMetadata) string {
+	parts := make([]string, 0, 3)
+	for _, s := range []string{md.Venue, md.City, md.Country} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkinDescription renders the main check-in fields as plain text lines.
+func checkinDescription(md CheckinMetadata) string {
+	var lines []string
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, label+": "+value)
+		}
+	}
+	add("Style", md.Style)
+	add("ABV", md.ABV)
+	add("Rating", md.Rating)
+	add("Venue", checkinPlace(md))
+	add("Comment", md.Comment)
+	return strings.Join(lines, "\n")
+}
+
+// csvColumns lists the CSV header, in the order of csvRecord.
+var csvColumns = []string{
+	"key",
+	"url",
+	"id",
+	"beer",
+	"brewery",
+	"brewery_country",
+	"comment",
+	"rating",
+	"venue",
+	"city",
+	"state",
+	"country",
+	"latlng",
+	"date",
+	"style",
+	"abv",
+	"serving_type",
+	"price",
+	"tagged_friends",
+	"photos",
+}
+
+func csvRecord(img Image) []string {
+	md := img.Metadata
+	return []string{
+		img.Key,
+		img.URL,
+		md.ID,
+		md.Beer,
+		md.Brewery,
+		md.BreweryCountry,
+		md.Comment,
+		md.Rating,
+		md.Venue,
+		md.City,
+		md.State,
+		md.Country,
+		md.LatLng,
+		md.Date,
+		md.Style,
+		md.ABV,
+		md.ServingType,
+		md.Price,
+		// lists are joined the way Untappd exports them
+		strings.Join(md.TaggedFriends, ", "),
+		strings.Join(img.Photos, ", "),
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, _ exportSource) (exportWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw}, nil
+}
+
+func (c *csvWriter) Write(img Image) error {
+	if err := c.w.Write(csvRecord(img)); err != nil {
+		return err
+	}
+	// flush every row so the response streams
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer, _ exportSource) (exportWriter, error) {
+	return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+}
+
+func (j *jsonlWriter) Write(img Image) error { return j.enc.Encode(img) }
+
+func (j *jsonlWriter) Close() error { return nil }
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPlacemark struct {
+	XMLName     xml.Name  `xml:"Placemark"`
+	Name        string    `xml:"name"`
+	Description string    `xml:"description"`
+	When        string    `xml:"TimeStamp>when,omitempty"`
+	Data        []kmlData `xml:"ExtendedData>Data"`
+	Coordinates string    `xml:"Point>coordinates"`
+}
+
+type kmlWriter struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+func newKMLWriter(w io.Writer, _ exportSource) (exportWriter, error) {
+	_, err := io.WriteString(w, xml.Header+
+		`<kml xmlns="http://www.opengis.net/kml/2.2"><Document><name>Beers</name>`)
+	if err != nil {
+		return nil, err
+	}
+	return &kmlWriter{w: w, enc: xml.NewEncoder(w)}, nil
+}
+
+func (k *kmlWriter) Write(img Image) error {
+	loc, err := geo.ParseLatLng(img.Metadata.LatLng)
+	if err != nil {
+		// nothing to place on a map
+		return nil
+	}
+
+	md := img.Metadata
+	p := kmlPlacemark{
+		Name: checkinTitle(md),
+		Description: fmt.Sprintf(
+			`<img src="%s" width="300"/><br/>%s`,
+			img.URL,
+			strings.ReplaceAll(xmlEscape(checkinDescription(md)), "\n", "<br/>"),
+		),
+		Data: []kmlData{
+			{Name: "photo", Value: img.URL},
+			{Name: "rating", Value: md.Rating},
+			{Name: "key", Value: img.Key},
+		},
+		Coordinates: fmt.Sprintf("%g,%g,0", loc.Lng, loc.Lat),
+	}
+	if at, err := parseCheckinDate(md); err == nil {
+		p.When = at.Format(time.RFC3339)
+	}
+	return k.enc.Encode(p)
+}
+
+func (k *kmlWriter) Close() error {
+	if err := k.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(k.w, "</Document></kml>\n")
+	return err
+}
+
+type gpxLink struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:"text"`
+}
+
+type gpxWaypoint struct {
+	XMLName xml.Name `xml:"wpt"`
+	Lat     float64  `xml:"lat,attr"`
+	Lon     float64  `xml:"lon,attr"`
+	Time    string   `xml:"time,omitempty"`
+	Name    string   `xml:"name"`
+	Desc    string   `xml:"desc,omitempty"`
+	Link    gpxLink  `xml:"link"`
+	Type    string   `xml:"type,omitempty"`
+}
+
+type gpxWriter struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+func newGPXWriter(w io.Writer, _ exportSource) (exportWriter, error) {
+	_, err := io.WriteString(w, xml.Header+
+		`<gpx version="1.1" creator="beers" xmlns="http://www.topografix.com/GPX/1/1">`)
+	if err != nil {
+		return nil, err
+	}
+	return &gpxWriter{w: w, enc: xml.NewEncoder(w)}, nil
+}
+
+func (g *gpxWriter) Write(img Image) error {
+	loc, err := geo.ParseLatLng(img.Metadata.LatLng)
+	if err != nil {
+		return nil
+	}
+
+	md := img.Metadata
+	wpt := gpxWaypoint{
+		Lat:  loc.Lat,
+		Lon:  loc.Lng,
+		Name: checkinTitle(md),
+		Desc: checkinDescription(md),
+		Link: gpxLink{Href: img.URL, Text: "Photo"},
+		Type: md.Style,
+	}
+	if at, err := parseCheckinDate(md); err == nil {
+		wpt.Time = at.UTC().Format(time.RFC3339)
+	}
+	return g.enc.Encode(wpt)
+}
+
+func (g *gpxWriter) Close() error {
+	if err := g.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(g.w, "</gpx>\n")
+	return err
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// findTrip returns the trip with the given ID. Without a cache, every
+// check-in is read.
+func findTrip(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	cache *TripCache,
+	id string,
+) (*Trip, error) {
+	if cache == nil {
+		cache = NewTripCache()
+	}
+	trips, err := cache.Trips(ctx, client, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i := range trips {
+		if trips[i].ID == id {
+			return &trips[i], nil
+		}
+	}
+	return nil, nil
+}
+
+var (
+	errInvalidFormat = errors.New("invalid export format")
+	errNoHome        = errors.New("home location is not configured")
+	errTripNotFound  = errors.New("trip not found")
+	errNoBaseURL     = errors.New("photos served through IMAGE_PROXY need the URL of the site")
+)
+
+// exportJob is a validated export request.
+type exportJob struct {
+	name   string
+	format exportFormat
+	filter exportFilter
+	// only the check-ins of this trip are exported when set
+	trip *Trip
+	// URL of the site photos served by the backend are linked from
+	base string
+}
+
+// newExportJob validates the format and the from, to and trip filters of
+// an export.
+func newExportJob(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	trips *TripCache,
+	name string,
+	q url.Values,
+) (exportJob, error) {
+	job := exportJob{name: name}
+	var ok bool
+	if job.format, ok = exportFormats[name]; !ok {
+		return job, errInvalidFormat
+	}
+	if job.format.lasting && !hasLastingURLs(cfg) {
+		return job, errNoLastingURL
+	}
+
+	var err error
+	if job.filter, err = parseExportFilter(q); err != nil {
+		return job, err
+	}
+	if job.filter.tripID != "" {
+		if _, err := geo.ParseLatLng(cfg.HomeLatLng); err != nil {
+			return job, errNoHome
+		}
+		job.trip, err = findTrip(ctx, client, cfg, trips, job.filter.tripID)
+		if err != nil {
+			return job, fmt.Errorf("load trips: %w", err)
+		}
+		if job.trip == nil {
+			return job, errTripNotFound
+		}
+	}
+	return job, nil
+}
+
+// write streams the check-ins of the export to w, calling flush after each
+// listing page when set.
+func (job exportJob) write(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	w io.Writer,
+	flush func(),
+) error {
+	fetch := func(key string) (io.ReadCloser, error) {
+		out, err := s3client.GetObject(ctx, client, cfg.BucketName, key)
+		if err != nil {
+			return nil, err
+		}
+		return out.Body, nil
+	}
+
+	ew, err := job.format.newWriter(w, exportSource{fetch: fetch, layout: keyLayout(cfg)})
+	if err != nil {
+		return err
+	}
+
+	writePage := func(page []Image) error {
+		for _, img := range absoluteImages(page, job.base) {
+			if !job.filter.match(img) {
+				continue
+			}
+			if err := ew.Write(img); err != nil {
+				return err
+			}
+		}
+		if flush != nil {
+			flush()
+		}
+		return nil
+	}
+
+	switch {
+	case job.trip != nil:
+		err = writePage(job.trip.Checkins)
+	case job.format.backup:
+		err = forEachObjectPage(ctx, client, cfg, func(groups []renditionGroup) error {
+			return writePage(fetchImages(ctx, client, cfg, groups))
+		})
+	default:
+		err = forEachImagePage(ctx, client, cfg, writePage)
+	}
+	if err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// ExportFormats lists the names of the export formats.
+func ExportFormats() []string {
+	return slices.Sorted(maps.Keys(exportFormats))
+}
+
+// WriteExport writes the check-ins to w in the named format. The filter
+// holds the from, to and trip parameters of the export endpoint. Photos
+// proxied by the backend are linked from base, the URL the site is hosted
+// at, which is then required.
+func WriteExport(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	w io.Writer,
+	format string,
+	filter url.Values,
+	base string,
+) error {
+	if cfg.ImageProxy {
+		if base == "" {
+			return errNoBaseURL
+		}
+		if err := checkBaseURL(base); err != nil {
+			return err
+		}
+	}
+	job, err := newExportJob(ctx, client, cfg, nil, format, filter)
+	if err != nil {
+		return err
+	}
+	job.base = base
+	return job.write(ctx, client, cfg, w, nil)
+}
+
+// exportHandler streams the check-ins in the given format, or in the one
+// requested by the format query parameter when empty.
+func exportHandler(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	trips *TripCache,
+	fixedFormat string,
+	attachment bool,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := fixedFormat
+		if name == "" {
+			name = r.URL.Query().Get("format")
+		}
+		if exportFormats[name].backup {
+			writeJSONError(w, http.StatusBadRequest, "Backups are only made by the export command")
+			return
+		}
+		job, err := newExportJob(ctx, client, cfg, trips, name, r.URL.Query())
+		switch {
+		case errors.Is(err, errInvalidFormat):
+			writeJSONError(w, http.StatusBadRequest, "Invalid export format")
+			return
+		case errors.Is(err, errNoLastingURL):
+			writeJSONError(w, http.StatusNotFound, "Photos have no lasting URL, set R2_PUBLIC_URL or IMAGE_PROXY")
+			return
+		case errors.Is(err, errInvalidDate):
+			writeJSONError(w, http.StatusBadRequest, "Invalid date format")
+			return
+		case errors.Is(err, errNoHome):
+			writeJSONError(w, http.StatusNotFound, "Home location is not configured")
+			return
+		case errors.Is(err, errTripNotFound):
+			writeJSONError(w, http.StatusNotFound, "Trip not found")
+			return
+		case err != nil:
+			log.Printf("export %s error: %v", name, err)
+			writeJSONError(w, http.StatusInternalServerError, "Error listing objects")
+			return
+		}
+
+		job.base = baseURL(r)
+
+		w.Header().Set("Content-Type", job.format.contentType)
+		if attachment {
+			w.Header().Set(
+				"Content-Disposition",
+				fmt.Sprintf(`attachment; filename="beers.%s"`, job.format.extension),
+			)
+		}
+
+		flush := func() {
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		if err := job.write(ctx, client, cfg, w, flush); err != nil {
+			// headers are already sent, the client gets a truncated file
+			log.Printf("export %s error: %v", name, err)
+		}
+	}
+}
+
+func Export(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	trips *TripCache,
+) http.HandlerFunc {
+	return exportHandler(ctx, client, cfg, trips, "", true)
+}
+
+// GetCalendar serves the iCalendar feed inline, so calendar apps can
+// subscribe to it.
+func GetCalendar(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	trips *TripCache,
+) http.HandlerFunc {
+	return exportHandler(ctx, client, cfg, trips, "ics", false)
+}
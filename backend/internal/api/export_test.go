@@ -0,0 +1,309 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func newExportMockClient(metadata map[string]map[string]string) *MockS3Client {
+	return &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			contents := make([]types.Object, 0, len(metadata))
+			for key := range metadata {
+				contents = append(contents, types.Object{Key: aws.String(key)})
+			}
+			return &s3.ListObjectsV2Output{Contents: contents}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{Metadata: metadata[aws.ToString(params.Key)]}, nil
+		},
+	}
+}
+
+func TestParseExportFilter(t *testing.T) {
+	f, err := parseExportFilter(url.Values{"from": {"2025-05-01"}, "to": {"2025-05-31"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		date     string
+		expected bool
+	}{
+		{"2025-04-30 23:59:59", false},
+		{"2025-05-01 00:00:00", true},
+		{"2025-05-31 23:00:00", true},
+		{"2025-06-01 00:00:00", false},
+		{"not a date", false},
+	}
+	for _, tt := range tests {
+		img := Image{Metadata: CheckinMetadata{Date: tt.date}}
+		if got := f.match(img); got != tt.expected {
+			t.Errorf("match(%q) = %v, want %v", tt.date, got, tt.expected)
+		}
+	}
+
+	if _, err := parseExportFilter(url.Values{"from": {"05/01/2025"}}); err == nil {
+		t.Errorf("expected an error for an invalid date")
+	}
+}
+
+func TestExportKML(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+	client := newExportMockClient(map[string]map[string]string{
+		"2025/11/08/WEBP/image1.webp": {
+			"id":     "1",
+			"beer":   "Test Beer",
+			"rating": "4.25",
+			"latlng": "48.8566,2.3522",
+			"date":   "2025-11-08 12:00:00",
+		},
+		"2025/11/09/WEBP/image2.webp": {
+			"id":   "2",
+			"beer": "No Location",
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export?format=kml", nil)
+	rr := httptest.NewRecorder()
+	Export(context.Background(), client, cfg, NewTripCache()).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("status = %v, want %v", status, http.StatusOK)
+	}
+
+	var doc struct {
+		Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+	}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not decode KML: %v", err)
+	}
+	if got := len(doc.Placemarks); got != 1 {
+		t.Fatalf("expected 1 placemark, got %d", got)
+	}
+	p := doc.Placemarks[0]
+	if p.Coordinates != "2.3522,48.8566,0" {
+		t.Errorf("unexpected coordinates: %s", p.Coordinates)
+	}
+	if !strings.Contains(p.Description, "https://test.com/2025/11/08/WEBP/image1.webp") {
+		t.Errorf("expected photo URL in description, got %q", p.Description)
+	}
+}
+
+func TestExportGPX(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+	client := newExportMockClient(map[string]map[string]string{
+		"2025/11/08/WEBP/image1.webp": {
+			"id":     "1",
+			"beer":   "Test Beer",
+			"latlng": "48.8566,2.3522",
+			"date":   "2025-11-08 12:00:00",
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export?format=gpx", nil)
+	rr := httptest.NewRecorder()
+	Export(context.Background(), client, cfg, NewTripCache()).ServeHTTP(rr, req)
+
+	var doc struct {
+		Waypoints []gpxWaypoint `xml:"wpt"`
+	}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not decode GPX: %v", err)
+	}
+	if got := len(doc.Waypoints); got != 1 {
+		t.Fatalf("expected 1 waypoint, got %d", got)
+	}
+	if wpt := doc.Waypoints[0]; wpt.Time != "2025-11-08T12:00:00Z" || wpt.Name != "Test Beer" {
+		t.Errorf("unexpected waypoint: %+v", wpt)
+	}
+}
+
+func TestExportPresignedPhotos(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	client := newExportMockClient(map[string]map[string]string{
+		"2025/11/08/WEBP/image1.webp": {"id": "1", "latlng": "48.8566,2.3522"},
+	})
+
+	tests := []struct {
+		format string
+		status int
+	}{
+		{"kml", http.StatusNotFound},
+		{"gpx", http.StatusNotFound},
+		{"ics", http.StatusNotFound},
+		// a one-off download, its links only need to work for a while
+		{"csv", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/export?format="+tt.format, nil)
+			rr := httptest.NewRecorder()
+			Export(context.Background(), client, cfg, NewTripCache()).ServeHTTP(rr, req)
+			if rr.Code != tt.status {
+				t.Errorf("status = %v, want %v", rr.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestExportInvalidFormat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/export?format=doc", nil)
+	rr := httptest.NewRecorder()
+	Export(context.Background(), &MockS3Client{}, &config.AppConfig{}, NewTripCache()).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("status = %v, want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+	client := newExportMockClient(map[string]map[string]string{
+		"2025/11/08/WEBP/image1.webp": {
+			"id":      "1",
+			"beer":    "Test Beer",
+			"comment": "=?UTF-8?Q?Tr=C3=A8s_bien,_\"really\"?=",
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export?format=csv", nil)
+	rr := httptest.NewRecorder()
+	Export(context.Background(), client, cfg, NewTripCache()).ServeHTTP(rr, req)
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("could not decode CSV: %v", err)
+	}
+	if got := len(records); got != 2 {
+		t.Fatalf("expected header and 1 row, got %d records", got)
+	}
+	if got := len(records[1]); got != len(csvColumns) {
+		t.Fatalf("expected %d columns, got %d", len(csvColumns), got)
+	}
+
+	row := map[string]string{}
+	for i, col := range records[0] {
+		row[col] = records[1][i]
+	}
+	if row["url"] != "https://test.com/2025/11/08/WEBP/image1.webp" {
+		t.Errorf("unexpected url: %s", row["url"])
+	}
+	if row["comment"] != `Très bien, "really"` {
+		t.Errorf("unexpected comment: %s", row["comment"])
+	}
+}
+
+func TestCSVRecord(t *testing.T) {
+	img := Image{
+		Key:    "2025/11/08/WEBP/image1.webp",
+		Photos: []string{"https://test.com/2025/11/08/EXTRA/image1-2.webp", "https://test.com/2025/11/08/EXTRA/image1-3.webp"},
+		Metadata: CheckinMetadata{
+			ServingType:   "Draft",
+			Price:         "6.50",
+			TaggedFriends: []string{"alice", "bob"},
+		},
+	}
+	record := csvRecord(img)
+	if len(record) != len(csvColumns) {
+		t.Fatalf("expected %d columns, got %d", len(csvColumns), len(record))
+	}
+
+	row := map[string]string{}
+	for i, col := range csvColumns {
+		row[col] = record[i]
+	}
+	expected := map[string]string{
+		"serving_type":   "Draft",
+		"price":          "6.50",
+		"tagged_friends": "alice, bob",
+		"photos":         "https://test.com/2025/11/08/EXTRA/image1-2.webp, https://test.com/2025/11/08/EXTRA/image1-3.webp",
+	}
+	for col, want := range expected {
+		if row[col] != want {
+			t.Errorf("%s = %q, want %q", col, row[col], want)
+		}
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+	client := newExportMockClient(map[string]map[string]string{
+		"2025/11/08/WEBP/image1.webp": {"id": "1"},
+		"2025/11/09/WEBP/image2.webp": {"id": "2"},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export?format=jsonl", nil)
+	rr := httptest.NewRecorder()
+	Export(context.Background(), client, cfg, NewTripCache()).ServeHTTP(rr, req)
+
+	dec := json.NewDecoder(rr.Body)
+	count := 0
+	for dec.More() {
+		var img Image
+		if err := dec.Decode(&img); err != nil {
+			t.Fatalf("could not decode line: %v", err)
+		}
+		if img.Key == "" || img.Metadata.ID == "" {
+			t.Errorf("unexpected line: %+v", img)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 lines, got %d", count)
+	}
+}
+
+func TestWriteExportProxiedPhotos(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", ImageProxy: true}
+	client := newExportMockClient(map[string]map[string]string{
+		"2025/11/08/WEBP/image1.webp": {"id": "1", "beer": "Test Beer"},
+	})
+
+	var b strings.Builder
+	if err := WriteExport(context.Background(), client, cfg, &b, "csv", nil, ""); err != errNoBaseURL {
+		t.Errorf("error = %v, want %v", err, errNoBaseURL)
+	}
+	if err := WriteExport(context.Background(), client, cfg, &b, "csv", nil, "beers.example.com"); err == nil {
+		t.Errorf("expected an error for a relative base URL")
+	}
+
+	if err := WriteExport(context.Background(), client, cfg, &b, "csv", nil, "https://beers.example.com/"); err != nil {
+		t.Fatalf("WriteExport() error = %v", err)
+	}
+	if !strings.Contains(b.String(), "https://beers.example.com/media/2025/11/08/WEBP/image1.webp") {
+		t.Errorf("expected an absolute proxy URL:\n%s", b.String())
+	}
+}
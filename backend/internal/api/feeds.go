@@ -0,0 +1,310 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// number of check-ins listed in the feeds
+	feedSize  = 30
+	feedTitle = "Beers"
+)
+
+// recentImages returns up to limit of the latest check-ins, walking back
+// month by month like GetImages does.
+func recentImages(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	limit int,
+) ([]Image, error) {
+	images := make([]Image, 0, limit)
+	cur := time.Now()
+	for len(images) < limit {
+		out, monthFound, err := findFirstNonEmptyMonth(ctx, client, cfg, cur, 12)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			break
+		}
+		groups := groupRenditions(keyLayout(cfg), out.Contents)
+		images = append(images, visibleImages(fetchImages(ctx, client, cfg, groups))...)
+		cur = monthFound.AddDate(0, -1, 0)
+	}
+
+	sortImagesNewestFirst(images)
+	if len(images) > limit {
+		images = images[:limit]
+	}
+	return images, nil
+}
+
+// baseURL returns the scheme and host the request was made to, honouring
+// the X-Forwarded-Proto header set by reverse proxies.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// checkinID returns a stable identifier for a check-in.
+func checkinID(img Image) string {
+	if img.Metadata.ID != "" {
+		return img.Metadata.ID
+	}
+	return img.Key
+}
+
+// checkinHTML renders a check-in photo and description as an HTML fragment.
+func checkinHTML(img Image) string {
+	desc := html.EscapeString(checkinDescription(img.Metadata))
+	return fmt.Sprintf(
+		`<p><img src="%s" alt="%s"/></p><p>%s</p>`,
+		html.EscapeString(img.URL),
+		html.EscapeString(checkinTitle(img.Metadata)),
+		strings.ReplaceAll(desc, "\n", "<br/>"),
+	)
+}
+
+// feedTime returns the check-in date, or the zero time if it is unparsable.
+func feedTime(img Image) time.Time {
+	at, err := parseCheckinDate(img.Metadata)
+	if err != nil {
+		return time.Time{}
+	}
+	return at
+}
+
+type feed struct {
+	siteURL string
+	feedURL string
+	updated time.Time
+	images  []Image
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func renderAtom(w io.Writer, f feed) error {
+	doc := atomFeed{
+		ID:      f.siteURL + "/",
+		Title:   feedTitle,
+		Updated: f.updated.Format(time.RFC3339),
+		Author:  feedTitle,
+		Links: []atomLink{
+			{Rel: "alternate", Href: f.siteURL + "/"},
+			{Rel: "self", Href: f.feedURL},
+		},
+	}
+	for _, img := range f.images {
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:      "urn:beers:checkin:" + checkinID(img),
+			Title:   checkinTitle(img.Metadata),
+			Updated: feedTime(img).Format(time.RFC3339),
+			Links: []atomLink{
+				{Rel: "alternate", Href: img.URL},
+				{Rel: "enclosure", Type: img.ContentType, Href: img.URL},
+			},
+			Content: atomContent{Type: "html", Value: checkinHTML(img)},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link"`
+	Description string       `xml:"description"`
+	GUID        rssGUID      `xml:"guid"`
+	PubDate     string       `xml:"pubDate"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+}
+
+type rssFeed struct {
+	XMLName       xml.Name  `xml:"rss"`
+	Version       string    `xml:"version,attr"`
+	Title         string    `xml:"channel>title"`
+	Link          string    `xml:"channel>link"`
+	Description   string    `xml:"channel>description"`
+	LastBuildDate string    `xml:"channel>lastBuildDate"`
+	Items         []rssItem `xml:"channel>item"`
+}
+
+func renderRSS(w io.Writer, f feed) error {
+	doc := rssFeed{
+		Version:       "2.0",
+		Title:         feedTitle,
+		Link:          f.siteURL + "/",
+		Description:   "Latest beer check-ins",
+		LastBuildDate: f.updated.Format(time.RFC1123Z),
+	}
+	for _, img := range f.images {
+		doc.Items = append(doc.Items, rssItem{
+			Title:       checkinTitle(img.Metadata),
+			Link:        img.URL,
+			Description: checkinHTML(img),
+			GUID:        rssGUID{Value: "urn:beers:checkin:" + checkinID(img)},
+			PubDate:     feedTime(img).Format(time.RFC1123Z),
+			// the size is unknown without fetching the photo
+			Enclosure: rssEnclosure{URL: img.URL, Length: "0", Type: img.ContentType},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Image         string               `json:"image"`
+	DatePublished string               `json:"date_published"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+func renderJSONFeed(w io.Writer, f feed) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feedTitle,
+		HomePageURL: f.siteURL + "/",
+		FeedURL:     f.feedURL,
+		Items:       []jsonFeedItem{},
+	}
+	for _, img := range f.images {
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            checkinID(img),
+			URL:           img.URL,
+			Title:         checkinTitle(img.Metadata),
+			ContentHTML:   checkinHTML(img),
+			ContentText:   checkinDescription(img.Metadata),
+			Image:         img.URL,
+			DatePublished: feedTime(img).Format(time.RFC3339),
+			Attachments:   []jsonFeedAttachment{{URL: img.URL, MimeType: img.ContentType}},
+		})
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
+
+func feedHandler(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	contentType string,
+	render func(w io.Writer, f feed) error,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasLastingURLs(cfg) {
+			writeJSONError(w, http.StatusNotFound, "Photos have no lasting URL, set R2_PUBLIC_URL or IMAGE_PROXY")
+			return
+		}
+
+		images, err := recentImages(ctx, client, cfg, feedSize)
+		if err != nil {
+			log.Printf("feed error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Error listing objects")
+			return
+		}
+
+		f := feed{
+			siteURL: baseURL(r),
+			feedURL: baseURL(r) + r.URL.Path,
+			images:  absoluteImages(images, baseURL(r)),
+		}
+		if len(images) > 0 {
+			f.updated = feedTime(images[0])
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if err := render(w, f); err != nil {
+			log.Printf("feed encode error: %v", err)
+		}
+	}
+}
+
+func GetAtomFeed(ctx context.Context, client s3client.S3Client, cfg *config.AppConfig) http.HandlerFunc {
+	return feedHandler(ctx, client, cfg, "application/atom+xml; charset=UTF-8", renderAtom)
+}
+
+func GetRSSFeed(ctx context.Context, client s3client.S3Client, cfg *config.AppConfig) http.HandlerFunc {
+	return feedHandler(ctx, client, cfg, "application/rss+xml; charset=UTF-8", renderRSS)
+}
+
+func GetJSONFeed(ctx context.Context, client s3client.S3Client, cfg *config.AppConfig) http.HandlerFunc {
+	return feedHandler(ctx, client, cfg, "application/feed+json; charset=UTF-8", renderJSONFeed)
+}
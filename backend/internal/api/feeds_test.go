@@ -0,0 +1,161 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/keylayout"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func newFeedMockClient(t *testing.T) *MockS3Client {
+	t.Helper()
+	month := keylayout.Default.MonthPrefix(time.Now())
+	return &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			// only the current month has check-ins
+			if aws.ToString(params.Prefix) != month {
+				return &s3.ListObjectsV2Output{}, nil
+			}
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String(month + "01/WEBP/image1.webp")},
+					{Key: aws.String(month + "02/WEBP/image2.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			key := aws.ToString(params.Key)
+			date := "2025-11-01 12:00:00"
+			if strings.Contains(key, "image2") {
+				date = "2025-11-02 12:00:00"
+			}
+			return &s3.HeadObjectOutput{
+				Metadata: map[string]string{
+					"id":      strings.TrimSuffix(path.Base(key), ".webp"),
+					"beer":    "Test Beer",
+					"brewery": "Test Brewery",
+					"rating":  "4",
+					"date":    date,
+				},
+			}, nil
+		},
+	}
+}
+
+func serveFeed(t *testing.T, handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Host = "beers.example.com"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("status = %v, want %v", status, http.StatusOK)
+	}
+	return rr
+}
+
+func TestGetAtomFeed(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com"}
+	handler := GetAtomFeed(context.Background(), newFeedMockClient(t), cfg)
+	rr := serveFeed(t, handler, "/feed.atom")
+
+	var doc atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not decode feed: %v", err)
+	}
+	if got := len(doc.Entries); got != 2 {
+		t.Fatalf("expected 2 entries, got %d", got)
+	}
+	if doc.Entries[0].ID != "urn:beers:checkin:image2" {
+		t.Errorf("expected newest entry first, got %q", doc.Entries[0].ID)
+	}
+	if doc.Entries[0].Title != "Test Beer by Test Brewery" {
+		t.Errorf("unexpected title: %q", doc.Entries[0].Title)
+	}
+	if doc.Updated != "2025-11-02T12:00:00Z" {
+		t.Errorf("unexpected updated date: %q", doc.Updated)
+	}
+}
+
+func TestGetRSSFeed(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com"}
+	handler := GetRSSFeed(context.Background(), newFeedMockClient(t), cfg)
+	rr := serveFeed(t, handler, "/feed.rss")
+
+	var doc rssFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not decode feed: %v", err)
+	}
+	if got := len(doc.Items); got != 2 {
+		t.Fatalf("expected 2 items, got %d", got)
+	}
+	if doc.Link != "http://beers.example.com/" {
+		t.Errorf("unexpected channel link: %q", doc.Link)
+	}
+	if !strings.HasPrefix(doc.Items[0].Enclosure.URL, "https://test.com/") {
+		t.Errorf("unexpected enclosure: %+v", doc.Items[0].Enclosure)
+	}
+}
+
+func TestGetJSONFeed(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com"}
+	handler := GetJSONFeed(context.Background(), newFeedMockClient(t), cfg)
+	rr := serveFeed(t, handler, "/feed.json")
+
+	var doc jsonFeed
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatalf("could not decode feed: %v", err)
+	}
+	if doc.FeedURL != "http://beers.example.com/feed.json" {
+		t.Errorf("unexpected feed URL: %q", doc.FeedURL)
+	}
+	if got := len(doc.Items); got != 2 {
+		t.Fatalf("expected 2 items, got %d", got)
+	}
+	if !strings.Contains(doc.Items[0].ContentText, "Rating: 4") {
+		t.Errorf("expected rating in content, got %q", doc.Items[0].ContentText)
+	}
+}
+
+func TestFeedProxiedPhotos(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", ImageProxy: true}
+	handler := GetRSSFeed(context.Background(), newFeedMockClient(t), cfg)
+	rr := serveFeed(t, handler, "/feed.rss")
+
+	var doc rssFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not decode feed: %v", err)
+	}
+	if got := doc.Items[0].Enclosure.URL; !strings.HasPrefix(got, "http://beers.example.com/media/") {
+		t.Errorf("expected an absolute proxy URL, got %q", got)
+	}
+}
+
+func TestFeedPresignedPhotos(t *testing.T) {
+	// presigned URLs would expire in feed readers
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	rr := httptest.NewRecorder()
+	GetAtomFeed(context.Background(), newFeedMockClient(t), cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/feed.atom", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
@@ -2,24 +2,27 @@ package api
 
 import (
 	"beers/backend/internal/config"
+	"beers/backend/internal/imageindex"
 	"beers/backend/internal/s3client"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"mime"
 	"net/http"
-	"net/url"
-	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// writeJSONError replies with status and a JSON body describing the error.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
 type CheckinMetadata struct {
 	ID             string `json:"id"`
 	Beer           string `json:"beer"`
@@ -35,17 +38,33 @@ type CheckinMetadata struct {
 	Date           string `json:"date"`
 	Style          string `json:"style"`
 	ABV            string `json:"abv"`
+	// only found in sidecar objects
+	ServingType   string   `json:"serving_type,omitempty"`
+	Price         string   `json:"price,omitempty"`
+	TaggedFriends []string `json:"tagged_friends,omitempty"`
+	Photos        []string `json:"photos,omitempty"`
+	Hidden        bool     `json:"hidden,omitempty"`
 }
 
 type Image struct {
-	URL      string          `json:"url"`
-	Key      string          `json:"key"`
-	Metadata CheckinMetadata `json:"metadata"`
+	URL         string          `json:"url"`
+	ContentType string          `json:"content_type"`
+	Key         string          `json:"key"`
+	Srcset      string          `json:"srcset"`
+	Variants    []Variant       `json:"variants"`
+	Photos      []string        `json:"photos,omitempty"`
+	Width       int             `json:"width,omitempty"`
+	Height      int             `json:"height,omitempty"`
+	Blurhash    string          `json:"blurhash,omitempty"`
+	Colors      []string        `json:"colors,omitempty"`
+	Metadata    CheckinMetadata `json:"metadata"`
 }
 
 type ImageResponse struct {
 	Images  []Image `json:"images"`
 	HasMore bool    `json:"has_more"`
+	// URL of the next page, set by static builds which cannot be queried
+	Next string `json:"next,omitempty"`
 }
 
 var rfc2047Decoder = new(mime.WordDecoder)
@@ -62,28 +81,17 @@ func decodeRFC2047Maybe(s string) string {
 	return decoded
 }
 
-func parseMonthFromLastKey(lastKey string) (time.Time, error) {
-	// expected format: YYYY/MM/...
-	parts := strings.Split(lastKey, "/")
-	if len(parts) < 2 {
-		return time.Time{}, errors.New("invalid lastKey format")
-	}
-	return time.Parse("2006/01", parts[0]+"/"+parts[1])
-}
-
-func monthPrefix(t time.Time) string { return t.Format("2006/01/") }
-
 func findFirstNonEmptyMonth(
 	ctx context.Context,
 	client s3client.S3Client,
-	bucket string,
+	cfg *config.AppConfig,
 	start time.Time,
 	maxBack int,
 ) (*s3.ListObjectsV2Output, time.Time, error) {
 	cur := start
 	for i := 0; i < maxBack; i++ {
-		prefix := monthPrefix(cur)
-		out, err := s3client.ListObjects(ctx, client, bucket, prefix, "")
+		prefix := keyLayout(cfg).MonthPrefix(cur)
+		out, err := s3client.ListObjects(ctx, client, cfg.BucketName, prefix, "")
 		if err != nil {
 			return nil, time.Time{}, fmt.Errorf("list %s: %w", prefix, err)
 		}
@@ -95,11 +103,14 @@ func findFirstNonEmptyMonth(
 	return nil, time.Time{}, nil
 }
 
-func newCheckinMetadata(m map[string]string) CheckinMetadata {
+// newCheckinMetadata builds the metadata of a check-in from the object
+// headers, merging the JSON sidecar over them when there is one. Fields
+// present in the sidecar win, an invalid sidecar is ignored.
+func newCheckinMetadata(m map[string]string, sidecar []byte) CheckinMetadata {
 	if m == nil {
 		m = map[string]string{}
 	}
-	return CheckinMetadata{
+	md := CheckinMetadata{
 		ID:             m["id"],
 		Beer:           decodeRFC2047Maybe(m["beer"]),
 		Brewery:        decodeRFC2047Maybe(m["brewery"]),
@@ -114,10 +125,26 @@ func newCheckinMetadata(m map[string]string) CheckinMetadata {
 		Date:           m["date"],
 		Style:          decodeRFC2047Maybe(m["style"]),
 		ABV:            m["abv"],
+		Hidden:         m[hiddenField] == "true",
+	}
+	if len(sidecar) == 0 {
+		return md
 	}
+
+	merged := md
+	if err := json.Unmarshal(sidecar, &merged); err != nil {
+		log.Printf("invalid sidecar metadata for %s: %v", md.ID, err)
+		return md
+	}
+	return merged
 }
 
-func GetImages(ctx context.Context, client s3client.S3Client, cfg *config.AppConfig) http.HandlerFunc {
+func GetImages(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	indexer *imageindex.Indexer,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		lastKey := r.URL.Query().Get("lastKey")
 
@@ -125,135 +152,63 @@ func GetImages(ctx context.Context, client s3client.S3Client, cfg *config.AppCon
 		if lastKey == "" {
 			startFrom = time.Now()
 		} else {
-			t, err := parseMonthFromLastKey(lastKey)
+			t, err := keyLayout(cfg).Month(lastKey)
 			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid lastKey format"})
+				writeJSONError(w, http.StatusBadRequest, "Invalid lastKey format")
 				return
 			}
 			// start from the previous month so we don't repeat the current one
 			startFrom = t.AddDate(0, -1, 0)
 		}
 
-		// find most recent month with content
-		out, monthFound, err := findFirstNonEmptyMonth(
-			ctx,
-			client,
-			cfg.BucketName,
-			startFrom,
-			12, // check up to 12 months back
-		)
-		if err != nil {
-			log.Printf("find month error: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Error listing objects"})
-			return
-		}
-		if out == nil {
-			// no images at all in the backward window
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(ImageResponse{Images: []Image{}, HasMore: false})
+		color := r.URL.Query().Get("color")
+		if color != "" && !imageindex.IsColorFamily(color) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid color")
 			return
 		}
 
-		// collect keys to process
-		keys := make([]types.Object, 0, len(out.Contents))
-		for _, obj := range out.Contents {
-			// ensure to only include images in webp format
-			if obj.Key == nil || !strings.Contains(*obj.Key, "/WEBP/") {
-				continue
+		var (
+			images     []Image
+			monthFound time.Time
+		)
+		for {
+			// find most recent month with content
+			out, month, err := findFirstNonEmptyMonth(
+				ctx,
+				client,
+				cfg,
+				startFrom,
+				12, // check up to 12 months back
+			)
+			if err != nil {
+				log.Printf("find month error: %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "Error listing objects")
+				return
 			}
-			keys = append(keys, obj)
-		}
-
-		// worker pool to limit concurrent HeadObject calls
-		const workers = 4
-		type item struct {
-			img Image
-			ok  bool
-		}
-		results := make(chan item, len(keys))
-		wg := sync.WaitGroup{}
-		jobs := make(chan types.Object)
-
-		worker := func() {
-			defer wg.Done()
-
-			for obj := range jobs {
-				key := *obj.Key
-
-				meta, err := s3client.GetObjectMetadata(
-					ctx,
-					client,
-					cfg.BucketName,
-					key,
-				)
-				if err != nil {
-					log.Printf("error getting metadata %s: %v", key, err)
-					results <- item{ok: false}
-					continue
-				}
-
-				m := meta.Metadata
-				if m == nil {
-					m = map[string]string{}
-				}
-
-				md := newCheckinMetadata(meta.Metadata)
-
-				imageURL, err := url.JoinPath(cfg.PublicURL, key)
-				if err != nil {
-					log.Printf("failed to join URL %q and %q: %v", cfg.PublicURL, key, err)
-					results <- item{ok: false}
-					continue
-				}
-
-				results <- item{
-					img: Image{
-						URL:      imageURL,
-						Key:      key,
-						Metadata: md,
-					},
-					ok: true,
-				}
+			if out == nil {
+				// no images at all in the backward window
+				w.WriteHeader(http.StatusOK)
+				json.NewEncoder(w).Encode(ImageResponse{Images: []Image{}, HasMore: false})
+				return
 			}
-		}
+			monthFound = month
 
-		wg.Add(workers)
-		for i := 0; i < workers; i++ {
-			go worker()
-		}
-		for _, obj := range keys {
-			jobs <- obj
-		}
-		close(jobs)
-		wg.Wait()
-		close(results)
-
-		images := make([]Image, 0, len(keys))
-		for res := range results {
-			if res.ok {
-				images = append(images, res.img)
+			images = visibleImages(fetchImages(ctx, client, cfg, groupRenditions(keyLayout(cfg), out.Contents)))
+			sortImagesNewestFirst(images)
+			annotateImages(images, indexer)
+			if color != "" {
+				images = filterImagesByColor(images, indexer, color)
 			}
-		}
-
-		// sorting
-		dateLayout := "2006-01-02 15:04:05"
-		sort.SliceStable(images, func(i, j int) bool {
-			ti, errI := time.Parse(dateLayout, images[i].Metadata.Date)
-			tj, errJ := time.Parse(dateLayout, images[j].Metadata.Date)
-
-			if errI != nil || errJ != nil {
-				// fallback to key sort
-				return images[i].Key > images[j].Key
+			if len(images) > 0 {
+				break
 			}
-
-			return ti.After(tj)
-		})
+			// nothing to show this month, an empty page would stall pagination
+			startFrom = month.AddDate(0, -1, 0)
+		}
 
 		// probe one earlier month than the one we used
 		prevMonth := monthFound.AddDate(0, -1, 0)
-		out2, _, err := findFirstNonEmptyMonth(ctx, client, cfg.BucketName, prevMonth, 1)
+		out2, _, err := findFirstNonEmptyMonth(ctx, client, cfg, prevMonth, 1)
 		hasMore := err == nil && out2 != nil && len(out2.Contents) > 0
 
 		resp := ImageResponse{
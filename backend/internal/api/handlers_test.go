@@ -2,10 +2,14 @@ package api
 
 import (
 	"beers/backend/internal/config"
+	"beers/backend/internal/imageindex"
+	"beers/backend/internal/keylayout"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -25,6 +29,21 @@ type MockS3Client struct {
 		params *s3.HeadObjectInput,
 		optFns ...func(*s3.Options),
 	) (*s3.HeadObjectOutput, error)
+	GetObjectFunc func(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error)
+	CopyObjectFunc func(
+		ctx context.Context,
+		params *s3.CopyObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.CopyObjectOutput, error)
+	PutObjectFunc func(
+		ctx context.Context,
+		params *s3.PutObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.PutObjectOutput, error)
 }
 
 func (m *MockS3Client) ListObjectsV2(
@@ -43,6 +62,30 @@ func (m *MockS3Client) HeadObject(
 	return m.HeadObjectFunc(ctx, params, optFns...)
 }
 
+func (m *MockS3Client) GetObject(
+	ctx context.Context,
+	params *s3.GetObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.GetObjectOutput, error) {
+	return m.GetObjectFunc(ctx, params, optFns...)
+}
+
+func (m *MockS3Client) CopyObject(
+	ctx context.Context,
+	params *s3.CopyObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.CopyObjectOutput, error) {
+	return m.CopyObjectFunc(ctx, params, optFns...)
+}
+
+func (m *MockS3Client) PutObject(
+	ctx context.Context,
+	params *s3.PutObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.PutObjectOutput, error) {
+	return m.PutObjectFunc(ctx, params, optFns...)
+}
+
 func TestGetImages(t *testing.T) {
 	cfg := &config.AppConfig{
 		BucketName: "test-bucket",
@@ -77,7 +120,18 @@ func TestGetImages(t *testing.T) {
 		},
 	}
 
-	handler := GetImages(context.Background(), mockClient, cfg)
+	idx, err := imageindex.Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	idx.Put("2025/11/08/WEBP/image1.webp", imageindex.Entry{
+		Width:    800,
+		Height:   600,
+		Blurhash: "LxH27b2kwzX5mAWYjuf7gKfkfQfj",
+	})
+	indexer := imageindex.NewIndexer(mockClient, cfg.BucketName, idx)
+
+	handler := GetImages(context.Background(), mockClient, cfg, indexer)
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rr := httptest.NewRecorder()
@@ -107,6 +161,16 @@ func TestGetImages(t *testing.T) {
 	if img.Metadata.Beer != "Test Beer" {
 		t.Errorf("expected Beer %q, got %q", "Test Beer", img.Metadata.Beer)
 	}
+
+	for _, img := range resp.Images {
+		indexed := img.Key == "2025/11/08/WEBP/image1.webp"
+		if indexed && (img.Width != 800 || img.Height != 600 || img.Blurhash == "") {
+			t.Errorf("expected indexed size and blurhash, got %dx%d %q", img.Width, img.Height, img.Blurhash)
+		}
+		if !indexed && img.Blurhash != "" {
+			t.Errorf("expected no blurhash for an image not indexed yet, got %q", img.Blurhash)
+		}
+	}
 }
 
 func TestDecodeRFC2047Maybe(t *testing.T) {
@@ -145,39 +209,209 @@ func TestDecodeRFC2047Maybe(t *testing.T) {
 	}
 }
 
-func TestParseMonthFromLastKey(t *testing.T) {
+func TestGetImagesColorFilter(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+	month := keylayout.Default.MonthPrefix(time.Now())
+
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			if aws.ToString(params.Prefix) != month {
+				return &s3.ListObjectsV2Output{}, nil
+			}
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String(month + "01/WEBP/amber.webp")},
+					{Key: aws.String(month + "01/WEBP/stout.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{}, nil
+		},
+	}
+
+	idx, err := imageindex.Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	idx.Put(month+"01/WEBP/amber.webp", imageindex.Entry{Colors: []string{"#c87814", "#fafafa"}})
+	idx.Put(month+"01/WEBP/stout.webp", imageindex.Entry{Colors: []string{"#101010"}})
+	handler := GetImages(context.Background(), mockClient, cfg, imageindex.NewIndexer(mockClient, cfg.BucketName, idx))
+
 	tests := []struct {
-		name      string
-		lastKey   string
-		expected  time.Time
-		expectErr bool
+		color  string
+		status int
+		keys   []string
+	}{
+		{"orange", http.StatusOK, []string{month + "01/WEBP/amber.webp"}},
+		{"black", http.StatusOK, []string{month + "01/WEBP/stout.webp"}},
+		{"blue", http.StatusOK, []string{}},
+		{"teal", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.color, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/images?color="+tt.color, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("status = %v, want %v", rr.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp ImageResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+			if len(resp.Images) != len(tt.keys) {
+				t.Fatalf("expected %d images, got %d", len(tt.keys), len(resp.Images))
+			}
+			for i, key := range tt.keys {
+				if resp.Images[i].Key != key {
+					t.Errorf("image %d key = %q, want %q", i, resp.Images[i].Key, key)
+				}
+			}
+		})
+	}
+}
+
+func TestGetImagesVariants(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/AVIF/image1.avif")},
+					{Key: aws.String("2025/11/08/JPEG/image1.jpg")},
+					{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			if got := aws.ToString(params.Key); got != "2025/11/08/WEBP/image1.webp" {
+				t.Errorf("metadata read from %q, want the webp rendition", got)
+			}
+			return &s3.HeadObjectOutput{
+				Metadata: map[string]string{"id": "123", "date": "2025-11-08 12:00:00"},
+			}, nil
+		},
+	}
+
+	idx, err := imageindex.Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	indexer := imageindex.NewIndexer(mockClient, cfg.BucketName, idx)
+	handler := GetImages(context.Background(), mockClient, cfg, indexer)
+
+	// the Accept header of the API call says nothing of the images, the URL
+	// stays on the primary rendition and clients pick among the variants
+	tests := []struct {
+		accept      string
+		url         string
+		contentType string
+	}{
+		{"*/*", "https://test.com/2025/11/08/WEBP/image1.webp", "image/webp"},
+		{"image/avif,image/webp,*/*", "https://test.com/2025/11/08/WEBP/image1.webp", "image/webp"},
+		{"application/json", "https://test.com/2025/11/08/WEBP/image1.webp", "image/webp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var resp ImageResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+			if got := len(resp.Images); got != 1 {
+				t.Fatalf("expected 1 check-in, got %d", got)
+			}
+
+			img := resp.Images[0]
+			if img.URL != tt.url || img.ContentType != tt.contentType {
+				t.Errorf("image = %s (%s), want %s (%s)", img.URL, img.ContentType, tt.url, tt.contentType)
+			}
+			if img.Key != "2025/11/08/WEBP/image1.webp" {
+				t.Errorf("unexpected key: %s", img.Key)
+			}
+			if got := len(img.Variants); got != 3 {
+				t.Errorf("expected 3 variants, got %d", got)
+			}
+		})
+	}
+}
+
+func TestNewCheckinMetadata(t *testing.T) {
+	headers := map[string]string{
+		"id":      "123",
+		"beer":    "Test Beer",
+		"comment": "short",
+		"rating":  "4",
+	}
+
+	tests := []struct {
+		name     string
+		sidecar  string
+		expected CheckinMetadata
 	}{
 		{
-			name:      "valid lastKey",
-			lastKey:   "2025/11/08/WEBP/image.webp",
-			expected:  time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC),
-			expectErr: false,
+			name:     "headers only",
+			expected: CheckinMetadata{ID: "123", Beer: "Test Beer", Comment: "short", Rating: "4"},
 		},
 		{
-			name:      "invalid lastKey",
-			lastKey:   "2025-11-08-webp-image.webp",
-			expectErr: true,
+			name:    "sidecar merged over headers",
+			sidecar: `{"comment": "a much longer comment", "serving_type": "Draft", "tagged_friends": ["alice", "bob"]}`,
+			expected: CheckinMetadata{
+				ID:            "123",
+				Beer:          "Test Beer",
+				Comment:       "a much longer comment",
+				Rating:        "4",
+				ServingType:   "Draft",
+				TaggedFriends: []string{"alice", "bob"},
+			},
 		},
 		{
-			name:      "empty lastKey",
-			lastKey:   "",
-			expectErr: true,
+			name:     "invalid sidecar",
+			sidecar:  `{"comment": 42}`,
+			expected: CheckinMetadata{ID: "123", Beer: "Test Beer", Comment: "short", Rating: "4"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseMonthFromLastKey(tt.lastKey)
-			if (err != nil) != tt.expectErr {
-				t.Fatalf("parseMonthFromLastKey() error = %v, expectErr %v", err, tt.expectErr)
-			}
-			if !tt.expectErr && !got.Equal(tt.expected) {
-				t.Errorf("parseMonthFromLastKey() = %v, want %v", got, tt.expected)
+			got := newCheckinMetadata(headers, []byte(tt.sidecar))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("newCheckinMetadata() = %+v, want %+v", got, tt.expected)
 			}
 		})
 	}
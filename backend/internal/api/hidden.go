@@ -0,0 +1,154 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// metadata header flagging the check-ins hidden from the journal
+const hiddenField = "hidden"
+
+// visibleImages drops the hidden check-ins.
+func visibleImages(images []Image) []Image {
+	visible := images[:0]
+	for _, img := range images {
+		if !img.Metadata.Hidden {
+			visible = append(visible, img)
+		}
+	}
+	return visible
+}
+
+// isCheckinHidden reads whether a check-in is hidden from the metadata of
+// its primary rendition and its sidecar.
+func isCheckinHidden(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	group renditionGroup,
+) (bool, error) {
+	key := aws.ToString(group.primary.Key)
+	head, err := s3client.GetObjectMetadata(ctx, client, cfg.BucketName, key)
+	if err != nil {
+		return false, fmt.Errorf("head %s: %w", key, err)
+	}
+	var sidecar []byte
+	if group.sidecar != "" {
+		if sidecar, err = fetchSidecar(ctx, client, cfg, group.sidecar); err != nil {
+			log.Printf("error getting sidecar %s: %v", group.sidecar, err)
+		}
+	}
+	return newCheckinMetadata(head.Metadata, sidecar).Hidden, nil
+}
+
+// isPhotoHidden reports whether the check-in a photo belongs to is hidden.
+// A photo listed among the extra photos of a neighbouring check-in belongs
+// to that check-in rather than to its own group.
+func isPhotoHidden(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	neighbours []renditionGroup,
+	group renditionGroup,
+) (bool, error) {
+	for _, g := range neighbours {
+		if g.sidecar == "" || aws.ToString(g.primary.Key) == aws.ToString(group.primary.Key) {
+			continue
+		}
+		sidecar, err := fetchSidecar(ctx, client, cfg, g.sidecar)
+		if err != nil {
+			log.Printf("error getting sidecar %s: %v", g.sidecar, err)
+			continue
+		}
+		photos := newCheckinMetadata(nil, sidecar).Photos
+		for _, v := range group.variants {
+			if slices.Contains(photos, aws.ToString(v.Key)) {
+				return isCheckinHidden(ctx, client, cfg, g)
+			}
+		}
+	}
+	return isCheckinHidden(ctx, client, cfg, group)
+}
+
+// SetCheckinHidden hides a check-in from the journal, feeds, exports and
+// photo routes, or restores it. The photo itself is kept in the bucket. Any
+// rendition of the check-in can be given, the flag is set on the primary.
+func SetCheckinHidden(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	hidden bool,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		if !isRenditionKey(keyLayout(cfg), key) {
+			writeJSONError(w, http.StatusNotFound, "Check-in not found")
+			return
+		}
+		group, err := checkinGroup(ctx, client, cfg, key)
+		if err != nil {
+			writeUpdateError(w, err)
+			return
+		}
+
+		// an empty value removes the flag
+		value := ""
+		if hidden {
+			value = "true"
+		}
+		changes := map[string]string{hiddenField: value}
+		// a flag in the sidecar would win over the header
+		sidecar, ok := readUnshadowedSidecar(ctx, client, cfg, w, group, changes)
+		if !ok {
+			return
+		}
+		metadata, err := updateCheckinMetadata(ctx, client, cfg, aws.ToString(group.primary.Key), changes)
+		if err != nil {
+			writeUpdateError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(newCheckinMetadata(metadata, sidecar)); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+	}
+}
+
+// GetHiddenCheckins lists every hidden check-in, so they can be restored.
+func GetHiddenCheckins(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hidden := []Image{}
+		err := forEachObjectPage(ctx, client, cfg, func(groups []renditionGroup) error {
+			for _, img := range fetchImages(ctx, client, cfg, groups) {
+				if img.Metadata.Hidden {
+					hidden = append(hidden, img)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("list hidden error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Error listing objects")
+			return
+		}
+		sortImagesNewestFirst(hidden)
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(ImageResponse{Images: hidden}); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/imageindex"
+	"beers/backend/internal/keylayout"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestSetCheckinHidden(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+
+	var copied s3.CopyObjectInput
+	mockClient := newPatchMockClient(t, &copied, nil)
+	mux := http.NewServeMux()
+	mux.Handle("PUT /api/hidden/{key...}", SetCheckinHidden(context.Background(), mockClient, cfg, true))
+	mux.Handle("DELETE /api/hidden/{key...}", SetCheckinHidden(context.Background(), mockClient, cfg, false))
+
+	req := httptest.NewRequest(http.MethodPut, "/api/hidden/2025/11/08/WEBP/image1.webp", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if got := copied.Metadata["hidden"]; got != "true" {
+		t.Errorf("expected the hidden flag to be set, got %q", got)
+	}
+	if got := copied.Metadata["venue"]; got != "Wrong Venue" {
+		t.Errorf("expected other metadata to be kept, got venue %q", got)
+	}
+	var md CheckinMetadata
+	if err := json.NewDecoder(rr.Body).Decode(&md); err != nil || !md.Hidden {
+		t.Errorf("expected a hidden check-in in the response, got %+v, %v", md, err)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/hidden/2025/11/08/WEBP/image1.webp", nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if _, ok := copied.Metadata["hidden"]; ok {
+		t.Errorf("expected the hidden flag to be removed")
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/hidden/2025/11/08/WEBP/missing.webp", nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestSetCheckinHiddenSidecar(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+
+	var copied s3.CopyObjectInput
+	client := newPatchMockClient(t, &copied, nil)
+	client.ListObjectsV2Func = func(
+		ctx context.Context,
+		params *s3.ListObjectsV2Input,
+		optFns ...func(*s3.Options),
+	) (*s3.ListObjectsV2Output, error) {
+		return &s3.ListObjectsV2Output{
+			Contents: []types.Object{
+				{Key: aws.String("2025/11/08/WEBP/image1.json")},
+				{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+			},
+		}, nil
+	}
+	client.GetObjectFunc = func(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error) {
+		return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(`{"hidden": true}`))}, nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("DELETE /api/hidden/{key...}", SetCheckinHidden(context.Background(), client, cfg, false))
+
+	// the check-in would stay hidden by its sidecar
+	req := httptest.NewRequest(http.MethodDelete, "/api/hidden/2025/11/08/WEBP/image1.webp", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("status = %v, want %v: %s", rr.Code, http.StatusConflict, rr.Body)
+	}
+	if copied.Key != nil {
+		t.Errorf("expected no update, edited %s", aws.ToString(copied.Key))
+	}
+}
+
+// newHiddenMockClient serves one hidden check-in this month and a visible
+// one the month before.
+func newHiddenMockClient(t *testing.T) *MockS3Client {
+	t.Helper()
+	now := time.Now()
+	current := keylayout.Default.MonthPrefix(now)
+	previous := keylayout.Default.MonthPrefix(now.AddDate(0, -1, 0))
+
+	return &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			hidden := types.Object{Key: aws.String(current + "01/WEBP/hidden.webp")}
+			visible := types.Object{Key: aws.String(previous + "01/WEBP/visible.webp")}
+			switch aws.ToString(params.Prefix) {
+			case "":
+				return &s3.ListObjectsV2Output{Contents: []types.Object{visible, hidden}}, nil
+			case current:
+				return &s3.ListObjectsV2Output{Contents: []types.Object{hidden}}, nil
+			case previous:
+				return &s3.ListObjectsV2Output{Contents: []types.Object{visible}}, nil
+			}
+			return &s3.ListObjectsV2Output{}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			md := map[string]string{"id": aws.ToString(params.Key)}
+			if strings.HasSuffix(aws.ToString(params.Key), "hidden.webp") {
+				md["hidden"] = "true"
+			}
+			return &s3.HeadObjectOutput{Metadata: md}, nil
+		},
+	}
+}
+
+func TestGetImagesSkipsHidden(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com"}
+	mockClient := newHiddenMockClient(t)
+
+	idx, err := imageindex.Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	indexer := imageindex.NewIndexer(mockClient, cfg.BucketName, idx)
+
+	rr := httptest.NewRecorder()
+	GetImages(context.Background(), mockClient, cfg, indexer).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var resp ImageResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(resp.Images) != 1 || !strings.HasSuffix(resp.Images[0].Key, "visible.webp") {
+		t.Errorf("expected only the visible check-in, got %+v", resp.Images)
+	}
+}
+
+func TestGetHiddenCheckins(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://test.com"}
+
+	rr := httptest.NewRecorder()
+	GetHiddenCheckins(context.Background(), newHiddenMockClient(t), cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var resp ImageResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(resp.Images) != 1 || !strings.HasSuffix(resp.Images[0].Key, "hidden.webp") {
+		t.Errorf("expected only the hidden check-in, got %+v", resp.Images)
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"beers/backend/internal/geo"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icalDateLayout = "20060102T150405"
+	// RFC 5545 content lines should not be longer than this, in octets
+	icalLineLength = 75
+)
+
+var icalEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// icalFold splits a content line into chunks of at most 75 octets, without
+// breaking multi-byte characters. Continuation lines start with a space.
+func icalFold(line string) string {
+	if len(line) <= icalLineLength {
+		return line
+	}
+
+	var b strings.Builder
+	limit := icalLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// account for the leading space of the continuation line
+		limit = icalLineLength - 1
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// icalWriter renders every check-in as a one hour event at its date. Dates
+// are written as floating times since the metadata carries no time zone.
+type icalWriter struct {
+	w     io.Writer
+	stamp string
+}
+
+func newICalWriter(w io.Writer, _ exportSource) (exportWriter, error) {
+	iw := &icalWriter{w: w, stamp: time.Now().UTC().Format(icalDateLayout) + "Z"}
+	err := iw.lines(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//beers//journal//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:Beers",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return iw, nil
+}
+
+func (iw *icalWriter) lines(lines ...string) error {
+	for _, line := range lines {
+		if _, err := io.WriteString(iw.w, icalFold(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (iw *icalWriter) Write(img Image) error {
+	md := img.Metadata
+	at, err := parseCheckinDate(md)
+	if err != nil {
+		// an event needs a date
+		return nil
+	}
+
+	lines := []string{
+		"BEGIN:VEVENT",
+		"UID:" + icalEscaper.Replace(checkinID(img)) + "@beers",
+		"DTSTAMP:" + iw.stamp,
+		"DTSTART:" + at.Format(icalDateLayout),
+		"DURATION:PT1H",
+		"SUMMARY:" + icalEscaper.Replace(checkinTitle(md)),
+		"DESCRIPTION:" + icalEscaper.Replace(checkinDescription(md)),
+		"URL:" + img.URL,
+	}
+	if place := checkinPlace(md); place != "" {
+		lines = append(lines, "LOCATION:"+icalEscaper.Replace(place))
+	}
+	if loc, err := geo.ParseLatLng(md.LatLng); err == nil {
+		lines = append(lines, fmt.Sprintf("GEO:%g;%g", loc.Lat, loc.Lng))
+	}
+	lines = append(lines, "END:VEVENT")
+
+	return iw.lines(lines...)
+}
+
+func (iw *icalWriter) Close() error {
+	return iw.lines("END:VCALENDAR")
+}
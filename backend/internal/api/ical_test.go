@@ -0,0 +1,86 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestICalFold(t *testing.T) {
+	short := "SUMMARY:Test Beer"
+	if got := icalFold(short); got != short {
+		t.Errorf("icalFold(%q) = %q, want unchanged", short, got)
+	}
+
+	long := "DESCRIPTION:" + strings.Repeat("é", 60)
+	folded := icalFold(long)
+	for i, line := range strings.Split(folded, "\r\n") {
+		if len(line) > icalLineLength {
+			t.Errorf("line %d is %d octets long", i, len(line))
+		}
+		if i > 0 && !strings.HasPrefix(line, " ") {
+			t.Errorf("continuation line %d does not start with a space", i)
+		}
+	}
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != long {
+		t.Errorf("unfolded line differs from the original: %q", unfolded)
+	}
+}
+
+func TestGetCalendar(t *testing.T) {
+	cfg := &config.AppConfig{
+		BucketName: "test-bucket",
+		PublicURL:  "https://test.com",
+	}
+	client := newExportMockClient(map[string]map[string]string{
+		"2025/11/08/WEBP/image1.webp": {
+			"id":      "1",
+			"beer":    "Test Beer",
+			"brewery": "Test Brewery",
+			"style":   "IPA",
+			"rating":  "4.5",
+			"venue":   "The Pub",
+			"city":    "Paris",
+			"latlng":  "48.8566,2.3522",
+			"comment": "Hoppy; good",
+			"date":    "2025-11-08 20:30:00",
+		},
+		"2025/11/09/WEBP/image2.webp": {
+			"id": "2",
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/calendar.ics", nil)
+	rr := httptest.NewRecorder()
+	GetCalendar(context.Background(), client, cfg, NewTripCache()).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("status = %v, want %v", status, http.StatusOK)
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != "" {
+		t.Errorf("expected calendar to be served inline, got %q", got)
+	}
+
+	body := strings.ReplaceAll(rr.Body.String(), "\r\n ", "")
+	if !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(body, "END:VCALENDAR\r\n") {
+		t.Fatalf("unexpected calendar envelope: %q", body)
+	}
+	if got := strings.Count(body, "BEGIN:VEVENT"); got != 1 {
+		t.Errorf("expected 1 event, got %d", got)
+	}
+	for _, want := range []string{
+		"UID:1@beers",
+		"DTSTART:20251108T203000",
+		"SUMMARY:Test Beer by Test Brewery",
+		`LOCATION:The Pub\, Paris`,
+		"GEO:48.8566;2.3522",
+		`Comment: Hoppy\; good`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in calendar", want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"beers/backend/internal/untappd"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ImportedCheckin is a check-in photo whose metadata was completed from the
+// export.
+type ImportedCheckin struct {
+	Key    string   `json:"key"`
+	Fields []string `json:"fields"`
+}
+
+// CheckinFailure is a check-in photo which could not be updated.
+type CheckinFailure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	Updated []ImportedCheckin `json:"updated"`
+	// check-ins which already had every field of the export
+	Complete int              `json:"complete"`
+	Failed   []CheckinFailure `json:"failed"`
+	// check-ins of the export without any photo in the bucket
+	WithoutPhoto []untappd.Checkin `json:"without_photo"`
+}
+
+// exportMetadata returns the metadata fields of an exported check-in. Values
+// the journal would reject are dropped.
+func exportMetadata(c untappd.Checkin) map[string]string {
+	fields := map[string]string{
+		"beer":            c.Beer,
+		"brewery":         c.Brewery,
+		"brewery_country": c.BreweryCountry,
+		"comment":         c.Comment,
+		"rating":          c.Rating,
+		"venue":           c.Venue,
+		"city":            c.City,
+		"state":           c.State,
+		"country":         c.Country,
+		"date":            c.CreatedAt,
+		"style":           c.Style,
+		"abv":             c.ABV,
+	}
+	if c.Lat != "" && c.Lng != "" {
+		fields["latlng"] = c.Lat + "," + c.Lng
+	}
+
+	for name, v := range fields {
+		if v == "" {
+			delete(fields, name)
+			continue
+		}
+		if err := editableFields[name](v); err != nil {
+			log.Printf("check-in %s: ignoring %s %q: %v", c.ID, name, v, err)
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// exportSidecar returns the fields of an exported check-in which only the
+// sidecar of its photo can hold.
+func exportSidecar(c untappd.Checkin) map[string]any {
+	fields := map[string]any{}
+	if c.ServingType != "" {
+		fields["serving_type"] = c.ServingType
+	}
+	var friends []string
+	for name := range strings.SplitSeq(c.TaggedFriends, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			friends = append(friends, name)
+		}
+	}
+	if len(friends) > 0 {
+		fields["tagged_friends"] = friends
+	}
+	return fields
+}
+
+// updateSidecar adds the exported fields the sidecar of a check-in lacks,
+// creating it if needed, and returns the fields added. Existing values are
+// never overwritten. With dryRun, nothing is written.
+func updateSidecar(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	g renditionGroup,
+	exported map[string]any,
+	dryRun bool,
+) ([]string, error) {
+	if len(exported) == 0 {
+		return nil, nil
+	}
+
+	key := g.sidecar
+	fields := map[string]json.RawMessage{}
+	var etag *string
+	if key != "" {
+		data, version, err := fetchSidecarVersion(ctx, client, cfg, key)
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", key, err)
+		}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("invalid sidecar %s: %w", key, err)
+		}
+		etag = version
+	} else {
+		k, _ := keyLayout(cfg).Match(*g.primary.Key)
+		k.Ext = sidecarExt
+		key = keyLayout(cfg).Build(k)
+	}
+
+	var added []string
+	for name, v := range exported {
+		if _, ok := fields[name]; ok {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = b
+		added = append(added, name)
+	}
+	slices.Sort(added)
+	if len(added) == 0 || dryRun {
+		return added, nil
+	}
+
+	data, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if g.sidecar != "" {
+		err = s3client.ReplaceObject(ctx, client, cfg.BucketName, key, "application/json", data, etag)
+	} else {
+		err = s3client.CreateObject(ctx, client, cfg.BucketName, key, "application/json", data, nil)
+	}
+	if isPreconditionFailed(err) {
+		return nil, errCheckinChanged
+	}
+	if err != nil {
+		return nil, fmt.Errorf("put %s: %w", key, err)
+	}
+	log.Printf("updated %s: %s", key, strings.Join(added, ", "))
+	return added, nil
+}
+
+// missingFields returns the fields of the export a photo has no value for.
+// Existing values are never overwritten.
+func missingFields(metadata, exported map[string]string) map[string]string {
+	missing := map[string]string{}
+	for name, v := range exported {
+		if metadata[name] == "" {
+			missing[name] = v
+		}
+	}
+	return missing
+}
+
+// ImportCheckins reconciles an Untappd export with the bucket: photos are
+// matched to exported check-ins by the ID in their key, and the fields they
+// lack are filled in from the export. With dryRun, the report is built but
+// nothing is written.
+func ImportCheckins(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	checkins []untappd.Checkin,
+	dryRun bool,
+) (ImportReport, error) {
+	byID := make(map[string]untappd.Checkin, len(checkins))
+	for _, c := range checkins {
+		byID[c.ID] = c
+	}
+
+	var report ImportReport
+	found := map[string]bool{}
+	fail := func(key string, err error) {
+		log.Printf("import %s: %v", key, err)
+		report.Failed = append(report.Failed, CheckinFailure{Key: key, Error: err.Error()})
+	}
+
+	err := forEachObjectPage(ctx, client, cfg, func(groups []renditionGroup) error {
+		for _, g := range groups {
+			key := *g.primary.Key
+			k, _ := keyLayout(cfg).Match(key)
+			c, ok := byID[k.ID]
+			if !ok {
+				continue
+			}
+			found[c.ID] = true
+
+			head, err := s3client.GetObjectMetadata(ctx, client, cfg.BucketName, key)
+			if err != nil {
+				fail(key, fmt.Errorf("head: %w", err))
+				continue
+			}
+			changes := missingFields(head.Metadata, exportMetadata(c))
+			sidecar := exportSidecar(c)
+			if comment, ok := changes["comment"]; ok && metadataSize(applyChanges(head.Metadata, changes)) > maxMetadataSize {
+				// long comments belong in the sidecar
+				delete(changes, "comment")
+				sidecar["comment"] = comment
+			}
+			if metadataSize(applyChanges(head.Metadata, changes)) > maxMetadataSize {
+				fail(key, errMetadataTooLarge)
+				continue
+			}
+			if !dryRun && len(changes) > 0 {
+				if _, err := replaceCheckinMetadata(ctx, client, cfg, key, head, changes); err != nil {
+					fail(key, err)
+					continue
+				}
+			}
+			added, err := updateSidecar(ctx, client, cfg, g, sidecar, dryRun)
+			if err != nil {
+				fail(key, err)
+				continue
+			}
+
+			fields := append(slices.Collect(maps.Keys(changes)), added...)
+			if len(fields) == 0 {
+				report.Complete++
+				continue
+			}
+			slices.Sort(fields)
+			report.Updated = append(report.Updated, ImportedCheckin{Key: key, Fields: fields})
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, c := range checkins {
+		if !found[c.ID] {
+			report.WithoutPhoto = append(report.WithoutPhoto, c)
+		}
+	}
+	sort.SliceStable(report.WithoutPhoto, func(i, j int) bool {
+		return report.WithoutPhoto[i].CreatedAt > report.WithoutPhoto[j].CreatedAt
+	})
+	return report, nil
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/untappd"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestExportMetadata(t *testing.T) {
+	got := exportMetadata(untappd.Checkin{
+		ID:        "1234",
+		Beer:      "Gueuze 100% Lambic",
+		Rating:    "4.5",
+		ABV:       "not a number",
+		Lat:       "50.8467",
+		Lng:       "4.3499",
+		CreatedAt: "2025-11-08 18:30:00",
+	})
+	want := map[string]string{
+		"beer":   "Gueuze 100% Lambic",
+		"rating": "4.5",
+		"latlng": "50.8467,4.3499",
+		"date":   "2025-11-08 18:30:00",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exportMetadata() = %v, want %v", got, want)
+	}
+}
+
+func TestImportCheckins(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	heads := map[string]map[string]string{
+		// sparse metadata of an old check-in
+		"2019/05/18/JPEG/42.jpg": {"id": "42", "beer": "Saison Dupont"},
+		"2025/11/08/WEBP/1234.webp": {
+			"id": "1234", "beer": "Gueuze", "brewery": "Cantillon", "rating": "4.5",
+			"date": "2025-11-08 18:30:00",
+		},
+		// not in the export
+		"2025/11/09/WEBP/5678.webp": {"id": "5678"},
+		// comments too long for headers
+		"2025/11/10/WEBP/7.webp": {"id": "7"},
+		"2025/11/11/WEBP/8.webp": {"id": "8"},
+	}
+	checkins := []untappd.Checkin{
+		{ID: "42", Beer: "Saison Dupont (exported)", Brewery: "Brasserie Dupont", Rating: "4", CreatedAt: "2019-05-18 12:00:00"},
+		{ID: "1234", Beer: "Gueuze 100% Lambic", Brewery: "Brasserie Cantillon", Rating: "4.5", CreatedAt: "2025-11-08 18:30:00"},
+		{ID: "7", Comment: strings.Repeat("a", 3000), CreatedAt: "2025-11-10 20:00:00"},
+		{ID: "8", Comment: strings.Repeat("a", 3000)},
+		{ID: "99", Beer: "Orval", CreatedAt: "2020-01-01 12:00:00"},
+		{ID: "100", Beer: "Westmalle Tripel", CreatedAt: "2021-01-01 12:00:00"},
+	}
+
+	for _, dryRun := range []bool{false, true} {
+		copied := map[string]map[string]string{}
+		sidecars := map[string]string{}
+		mockClient := &MockS3Client{
+			ListObjectsV2Func: func(
+				ctx context.Context,
+				params *s3.ListObjectsV2Input,
+				optFns ...func(*s3.Options),
+			) (*s3.ListObjectsV2Output, error) {
+				var contents []types.Object
+				for _, key := range []string{
+					"2019/05/18/JPEG/42.jpg",
+					"2025/11/08/WEBP/1234.webp",
+					"2025/11/09/WEBP/5678.webp",
+					"2025/11/10/WEBP/7.webp",
+					"2025/11/11/WEBP/8.webp",
+				} {
+					contents = append(contents, types.Object{Key: aws.String(key)})
+				}
+				return &s3.ListObjectsV2Output{Contents: contents}, nil
+			},
+			HeadObjectFunc: func(
+				ctx context.Context,
+				params *s3.HeadObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.HeadObjectOutput, error) {
+				md, ok := heads[aws.ToString(params.Key)]
+				if !ok {
+					return nil, &types.NotFound{}
+				}
+				return &s3.HeadObjectOutput{ETag: aws.String(`"etag"`), Metadata: md}, nil
+			},
+			CopyObjectFunc: func(
+				ctx context.Context,
+				params *s3.CopyObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.CopyObjectOutput, error) {
+				copied[aws.ToString(params.Key)] = params.Metadata
+				return &s3.CopyObjectOutput{}, nil
+			},
+			PutObjectFunc: func(
+				ctx context.Context,
+				params *s3.PutObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.PutObjectOutput, error) {
+				b, _ := io.ReadAll(params.Body)
+				sidecars[aws.ToString(params.Key)] = string(b)
+				return &s3.PutObjectOutput{}, nil
+			},
+		}
+
+		report, err := ImportCheckins(context.Background(), mockClient, cfg, checkins, dryRun)
+		if err != nil {
+			t.Fatalf("ImportCheckins() error = %v", err)
+		}
+
+		// comments too long for headers go to the sidecar, in a dry run too
+		wantUpdated := []ImportedCheckin{
+			{Key: "2019/05/18/JPEG/42.jpg", Fields: []string{"brewery", "date", "rating"}},
+			{Key: "2025/11/10/WEBP/7.webp", Fields: []string{"comment", "date"}},
+			{Key: "2025/11/11/WEBP/8.webp", Fields: []string{"comment"}},
+		}
+		if !reflect.DeepEqual(report.Updated, wantUpdated) {
+			t.Errorf("dry run %v: Updated = %+v, want %+v", dryRun, report.Updated, wantUpdated)
+		}
+		if report.Complete != 1 || len(report.Failed) != 0 {
+			t.Errorf("dry run %v: Complete = %d, Failed = %+v, want 1 and none", dryRun, report.Complete, report.Failed)
+		}
+		if len(report.WithoutPhoto) != 2 || report.WithoutPhoto[0].ID != "100" || report.WithoutPhoto[1].ID != "99" {
+			t.Errorf("dry run %v: WithoutPhoto = %+v, want 100 then 99", dryRun, report.WithoutPhoto)
+		}
+
+		if dryRun {
+			if len(copied) != 0 || len(sidecars) != 0 {
+				t.Errorf("dry run wrote metadata: %v, %v", copied, sidecars)
+			}
+			continue
+		}
+		if _, ok := copied["2025/11/10/WEBP/7.webp"]["comment"]; ok {
+			t.Errorf("expected the long comment to stay out of the headers")
+		}
+		if got := sidecars["2025/11/11/WEBP/8.json"]; !strings.Contains(got, strings.Repeat("a", 3000)) {
+			t.Errorf("expected the long comment in the sidecar, got %q", got)
+		}
+		md := copied["2019/05/18/JPEG/42.jpg"]
+		if md["beer"] != "Saison Dupont" || md["brewery"] != "Brasserie Dupont" || md["rating"] != "4" {
+			t.Errorf("unexpected metadata written: %v", md)
+		}
+	}
+}
+
+func TestImportCheckinsSidecar(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	checkins := []untappd.Checkin{
+		{ID: "1", ServingType: "Bottle", TaggedFriends: "alice, bob"},
+		{ID: "2", ServingType: "Can", TaggedFriends: "carol"},
+	}
+
+	put := map[string]*s3.PutObjectInput{}
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{Contents: []types.Object{
+				{Key: aws.String("2025/11/08/WEBP/1.webp")},
+				{Key: aws.String("2025/11/09/WEBP/2.json")},
+				{Key: aws.String("2025/11/09/WEBP/2.webp")},
+			}}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{ETag: aws.String(`"etag"`)}, nil
+		},
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			if aws.ToString(params.Key) != "2025/11/09/WEBP/2.json" {
+				return nil, &types.NoSuchKey{}
+			}
+			return &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(`{"serving_type": "Draft", "price": "4.50"}`)),
+				ETag: aws.String(`"sidecar"`),
+			}, nil
+		},
+		PutObjectFunc: func(
+			ctx context.Context,
+			params *s3.PutObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.PutObjectOutput, error) {
+			put[aws.ToString(params.Key)] = params
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+
+	report, err := ImportCheckins(context.Background(), mockClient, cfg, checkins, false)
+	if err != nil {
+		t.Fatalf("ImportCheckins() error = %v", err)
+	}
+	wantUpdated := []ImportedCheckin{
+		{Key: "2025/11/08/WEBP/1.webp", Fields: []string{"serving_type", "tagged_friends"}},
+		{Key: "2025/11/09/WEBP/2.webp", Fields: []string{"tagged_friends"}},
+	}
+	if !reflect.DeepEqual(report.Updated, wantUpdated) || len(report.Failed) != 0 {
+		t.Errorf("Updated = %+v, Failed = %+v, want %+v", report.Updated, report.Failed, wantUpdated)
+	}
+
+	readPut := func(key string) map[string]any {
+		t.Helper()
+		params, ok := put[key]
+		if !ok {
+			t.Fatalf("expected %s to be written", key)
+		}
+		var fields map[string]any
+		if err := json.NewDecoder(params.Body).Decode(&fields); err != nil {
+			t.Fatalf("invalid sidecar written to %s: %v", key, err)
+		}
+		return fields
+	}
+
+	created := put["2025/11/08/WEBP/1.json"]
+	if created == nil || aws.ToString(created.IfNoneMatch) != "*" {
+		t.Errorf("expected the sidecar to be created, got %+v", created)
+	}
+	want := map[string]any{"serving_type": "Bottle", "tagged_friends": []any{"alice", "bob"}}
+	if got := readPut("2025/11/08/WEBP/1.json"); !reflect.DeepEqual(got, want) {
+		t.Errorf("created sidecar = %v, want %v", got, want)
+	}
+
+	if got := aws.ToString(put["2025/11/09/WEBP/2.json"].IfMatch); got != `"sidecar"` {
+		t.Errorf("IfMatch = %q, want the ETag read", got)
+	}
+	want = map[string]any{"serving_type": "Draft", "price": "4.50", "tagged_friends": []any{"carol"}}
+	if got := readPut("2025/11/09/WEBP/2.json"); !reflect.DeepEqual(got, want) {
+		t.Errorf("updated sidecar = %v, want %v", got, want)
+	}
+}
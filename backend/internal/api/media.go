@@ -0,0 +1,239 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/diskcache"
+	"beers/backend/internal/s3client"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// proxied photos may sit behind authentication, keep them out of shared
+	// caches
+	mediaCacheControl = "private, max-age=86400"
+
+	// how long, and for how many check-ins, the renditions of a check-in
+	// are remembered by the photo routes. Hiding a check-in takes as long
+	// to apply to its photos.
+	renditionCacheTTL  = 5 * time.Minute
+	renditionCacheSize = 4096
+)
+
+// renditionCache remembers the renditions of recently served check-ins, and
+// whether they are hidden, so serving each photo does not list the bucket.
+type renditionCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRenditions
+}
+
+type cachedRenditions struct {
+	group renditionGroup
+	// false when the check-in has no photo or is hidden
+	found bool
+	at    time.Time
+}
+
+func newRenditionCache() *renditionCache {
+	return &renditionCache{entries: map[string]cachedRenditions{}}
+}
+
+// lookup returns the renditions of the check-in of a rendition key. found
+// is false when the check-in has no photo or is hidden.
+func (c *renditionCache) lookup(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+) (renditionGroup, bool, error) {
+	k, ok := keyLayout(cfg).Match(key)
+	if !ok {
+		return renditionGroup{}, false, nil
+	}
+	checkin := k.Checkin()
+
+	c.mu.Lock()
+	e, ok := c.entries[checkin]
+	c.mu.Unlock()
+	if ok && time.Since(e.at) < renditionCacheTTL {
+		return e.group, e.found, nil
+	}
+
+	neighbours, err := listNeighbourGroups(ctx, client, cfg, key)
+	if err != nil {
+		return renditionGroup{}, false, err
+	}
+	group, found := checkinGroupOf(keyLayout(cfg), neighbours, key)
+	if found {
+		hidden, err := isPhotoHidden(ctx, client, cfg, neighbours, group)
+		if err != nil {
+			return renditionGroup{}, false, err
+		}
+		found = !hidden
+	}
+	c.mu.Lock()
+	if len(c.entries) >= renditionCacheSize {
+		clear(c.entries)
+	}
+	c.entries[checkin] = cachedRenditions{group: group, found: found, at: time.Now()}
+	c.mu.Unlock()
+	return group, found, nil
+}
+
+// negotiateRendition returns the rendition of the check-in to serve for a
+// requested one: the variant the Accept header of the image request prefers.
+// Renditions outside renditionTypes, like originals, are served as asked.
+func negotiateRendition(group renditionGroup, key, accept string) string {
+	if accept == "" || typeRank(renditionTypes, renditionType(key)) == len(renditionTypes) {
+		return key
+	}
+	if v, ok := chooseVariant(groupVariants(group), accept); ok {
+		return v.Key
+	}
+	return key
+}
+
+// hasRendition reports whether key is one of the renditions of group.
+func hasRendition(group renditionGroup, key string) bool {
+	for _, obj := range group.variants {
+		if *obj.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaMeta is cached next to each proxied photo.
+type mediaMeta struct {
+	ETag        string `json:"etag"`
+	ContentType string `json:"content_type"`
+}
+
+func mediaMetaName(key string) string { return key + "#meta" }
+
+// cachedMedia returns the cached photo and its metadata, if both are there.
+func cachedMedia(cache *diskcache.Cache, key string) (string, mediaMeta, bool) {
+	var meta mediaMeta
+
+	metaPath, ok := cache.Get(mediaMetaName(key))
+	if !ok {
+		return "", meta, false
+	}
+	b, err := os.ReadFile(metaPath)
+	if err != nil || json.Unmarshal(b, &meta) != nil {
+		return "", meta, false
+	}
+
+	p, ok := cache.Get(key)
+	if !ok {
+		return "", meta, false
+	}
+	return p, meta, true
+}
+
+// fetchMedia downloads a photo from the bucket into the cache.
+func fetchMedia(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	cache *diskcache.Cache,
+	key string,
+) (string, mediaMeta, error) {
+	out, err := s3client.GetObject(ctx, client, cfg.BucketName, key)
+	if err != nil {
+		return "", mediaMeta{}, err
+	}
+	defer out.Body.Close()
+
+	meta := mediaMeta{
+		ETag:        aws.ToString(out.ETag),
+		ContentType: aws.ToString(out.ContentType),
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = mime.TypeByExtension(path.Ext(key))
+	}
+
+	p, err := cache.Put(key, out.Body)
+	if err != nil {
+		return "", meta, err
+	}
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return "", meta, err
+	}
+	if _, err := cache.Put(mediaMetaName(key), bytes.NewReader(b)); err != nil {
+		return "", meta, err
+	}
+	return p, meta, nil
+}
+
+// GetMedia streams photos from the bucket through the backend, so the
+// bucket does not need to be public. Each photo is served in the rendition
+// of its check-in the Accept header prefers, photos of hidden check-ins are
+// not found. Photos are cached on disk, and served with ETag, conditional
+// and range request support.
+func GetMedia(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	cache *diskcache.Cache,
+) http.HandlerFunc {
+	renditions := newRenditionCache()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		if !isRenditionKey(keyLayout(cfg), key) {
+			writeJSONError(w, http.StatusNotFound, "Image not found")
+			return
+		}
+
+		group, found, err := renditions.lookup(ctx, client, cfg, key)
+		if err != nil {
+			log.Printf("media %s error: %v", key, err)
+			writeJSONError(w, http.StatusBadGateway, "Error fetching image")
+			return
+		}
+		if !found || !hasRendition(group, key) {
+			writeJSONError(w, http.StatusNotFound, "Image not found")
+			return
+		}
+		key = negotiateRendition(group, key, r.Header.Get("Accept"))
+		w.Header().Set("Vary", "Accept")
+
+		p, meta, ok := cachedMedia(cache, key)
+		if !ok {
+			var err error
+			p, meta, err = fetchMedia(ctx, client, cfg, cache, key)
+
+			var noSuchKey *types.NoSuchKey
+			if errors.As(err, &noSuchKey) {
+				writeJSONError(w, http.StatusNotFound, "Image not found")
+				return
+			}
+			if err != nil {
+				log.Printf("media %s error: %v", key, err)
+				writeJSONError(w, http.StatusBadGateway, "Error fetching image")
+				return
+			}
+		}
+
+		if meta.ETag != "" {
+			w.Header().Set("ETag", meta.ETag)
+		}
+		w.Header().Set("Cache-Control", mediaCacheControl)
+		serveCachedFile(w, r, p, meta.ContentType)
+	}
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/diskcache"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestGetMedia(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", ImageProxy: true}
+	cache, err := diskcache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls, lists := 0, 0
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			lists++
+			if got := aws.ToString(params.Prefix); got != "2025/11/08/" {
+				t.Errorf("listed %q, want the prefix of the check-in", got)
+			}
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/AVIF/image1.avif")},
+					{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{}, nil
+		},
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			calls++
+			switch aws.ToString(params.Key) {
+			case "2025/11/08/WEBP/image1.webp":
+				return &s3.GetObjectOutput{
+					Body:        io.NopCloser(strings.NewReader("webp photo content")),
+					ETag:        aws.String(`"abc123"`),
+					ContentType: aws.String("image/webp"),
+				}, nil
+			case "2025/11/08/AVIF/image1.avif":
+				return &s3.GetObjectOutput{
+					Body:        io.NopCloser(strings.NewReader("avif photo content")),
+					ETag:        aws.String(`"def456"`),
+					ContentType: aws.String("image/avif"),
+				}, nil
+			}
+			return nil, &types.NoSuchKey{}
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /media/{key...}", GetMedia(context.Background(), mockClient, cfg, cache))
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("/media/2025/11/08/WEBP/image1.webp", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	if got := rr.Body.String(); got != "webp photo content" {
+		t.Errorf("unexpected body: %q", got)
+	}
+	if got := rr.Header().Get("ETag"); got != `"abc123"` {
+		t.Errorf("ETag = %q, want %q", got, `"abc123"`)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "image/webp" {
+		t.Errorf("Content-Type = %q, want image/webp", got)
+	}
+
+	rr = serve("/media/2025/11/08/WEBP/image1.webp", http.Header{"If-None-Match": {`"abc123"`}})
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("conditional request status = %v, want %v", rr.Code, http.StatusNotModified)
+	}
+
+	rr = serve("/media/2025/11/08/WEBP/image1.webp", http.Header{"Range": {"bytes=0-3"}})
+	if rr.Code != http.StatusPartialContent {
+		t.Errorf("range request status = %v, want %v", rr.Code, http.StatusPartialContent)
+	}
+	if got := rr.Body.String(); got != "webp" {
+		t.Errorf("unexpected range body: %q", got)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected the photo to be fetched once, got %d calls", calls)
+	}
+	if lists != 1 {
+		t.Errorf("expected the renditions to be listed once, got %d listings", lists)
+	}
+
+	if rr := serve("/media/2025/11/08/WEBP/missing.webp", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("missing photo status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetMediaNegotiation(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", ImageProxy: true}
+	cache, err := diskcache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/AVIF/image1.avif")},
+					{Key: aws.String("2025/11/08/ORIGINAL/image1.heic")},
+					{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{}, nil
+		},
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			key := aws.ToString(params.Key)
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("photo:" + key))}, nil
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /media/{key...}", GetMedia(context.Background(), mockClient, cfg, cache))
+
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		expected string
+	}{
+		{"avif support", "/media/2025/11/08/WEBP/image1.webp", "image/avif,image/webp,*/*", "2025/11/08/AVIF/image1.avif"},
+		{"no avif support", "/media/2025/11/08/AVIF/image1.avif", "image/webp,*/*", "2025/11/08/WEBP/image1.webp"},
+		{"no header", "/media/2025/11/08/WEBP/image1.webp", "", "2025/11/08/WEBP/image1.webp"},
+		{"original", "/media/2025/11/08/ORIGINAL/image1.heic", "image/avif,image/webp,*/*", "2025/11/08/ORIGINAL/image1.heic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if got := rr.Body.String(); got != "photo:"+tt.expected {
+				t.Errorf("served %q, want %s", got, tt.expected)
+			}
+			if got := rr.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Vary = %q, want Accept", got)
+			}
+		})
+	}
+}
+
+func TestGetMediaHidden(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", ImageProxy: true}
+	cache, err := diskcache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/AVIF/image1.avif")},
+					{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			if got := aws.ToString(params.Key); got != "2025/11/08/WEBP/image1.webp" {
+				t.Errorf("read the metadata of %s, want the primary rendition", got)
+			}
+			return &s3.HeadObjectOutput{Metadata: map[string]string{"hidden": "true"}}, nil
+		},
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			t.Errorf("fetched %s of a hidden check-in", aws.ToString(params.Key))
+			return nil, &types.NoSuchKey{}
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /media/{key...}", GetMedia(context.Background(), mockClient, cfg, cache))
+
+	for _, path := range []string{"/media/2025/11/08/WEBP/image1.webp", "/media/2025/11/08/AVIF/image1.avif"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("GET %s status = %v, want %v", path, rr.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestGetMediaHiddenExtraPhoto(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", ImageProxy: true}
+	cache, err := diskcache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/EXTRA/image1-2.webp")},
+					{Key: aws.String("2025/11/08/WEBP/image1.json")},
+					{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			md := map[string]string{}
+			if aws.ToString(params.Key) == "2025/11/08/WEBP/image1.webp" {
+				md["hidden"] = "true"
+			}
+			return &s3.HeadObjectOutput{Metadata: md}, nil
+		},
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			if got := aws.ToString(params.Key); got != "2025/11/08/WEBP/image1.json" {
+				t.Errorf("fetched %s of a hidden check-in", got)
+				return nil, &types.NoSuchKey{}
+			}
+			sidecar := `{"photos": ["2025/11/08/EXTRA/image1-2.webp"]}`
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(sidecar))}, nil
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /media/{key...}", GetMedia(context.Background(), mockClient, cfg, cache))
+
+	// the extra photo belongs to the hidden check-in, not to a group of its own
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/media/2025/11/08/EXTRA/image1-2.webp", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// metadata header holding the version of the last migration applied
+const schemaVersionField = "schema_version"
+
+// Migration is a versioned transformation of check-in metadata. It returns
+// the fields to change, with their decoded values, in the form accepted by
+// applyChanges. Applying it twice must not change anything more.
+type Migration struct {
+	Version int
+	Name    string
+	Apply   func(metadata map[string]string) map[string]string
+}
+
+// Migrations lists every metadata migration, by increasing version. New
+// migrations are appended, existing ones never change.
+var Migrations = []Migration{
+	{Version: 1, Name: "normalize-numbers", Apply: normalizeNumbers},
+	{Version: 2, Name: "reencode-headers", Apply: reencodeHeaders},
+}
+
+// SchemaVersion is the version of the metadata once every migration ran.
+func SchemaVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// normalizeNumbers writes ratings and ABV as plain decimal numbers, e.g.
+// "4,50" or "5%" become "4.5" and "5".
+func normalizeNumbers(metadata map[string]string) map[string]string {
+	changes := map[string]string{}
+	for _, name := range []string{"rating", "abv"} {
+		v := metadata[name]
+		if v == "" {
+			continue
+		}
+		s := strings.TrimSuffix(strings.TrimSpace(v), "%")
+		s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			// left for verify to report
+			continue
+		}
+		if normalized := strconv.FormatFloat(n, 'f', -1, 64); normalized != v {
+			changes[name] = normalized
+		}
+	}
+	return changes
+}
+
+// reencodeHeaders stores every text header the way encodeMetadataValue
+// does, replacing other RFC 2047 encodings and needless encoded words.
+func reencodeHeaders(metadata map[string]string) map[string]string {
+	changes := map[string]string{}
+	for name, v := range metadata {
+		if !strings.Contains(v, "=?") {
+			continue
+		}
+		decoded, err := rfc2047Decoder.DecodeHeader(v)
+		if err != nil || decoded == "" {
+			continue
+		}
+		if encodeMetadataValue(decoded) != v {
+			changes[name] = decoded
+		}
+	}
+	return changes
+}
+
+// metadataVersion returns the schema version of an object, 0 when it has
+// never been migrated.
+func metadataVersion(metadata map[string]string) int {
+	v, _ := strconv.Atoi(metadata[schemaVersionField])
+	return v
+}
+
+// migrateMetadata runs the migrations an object has not been through yet.
+// It returns the changes to apply and the names of the migrations which
+// changed something, or no changes when the object is up to date.
+func migrateMetadata(metadata map[string]string) (changes map[string]string, applied []string) {
+	version := metadataVersion(metadata)
+	if version >= SchemaVersion() {
+		return nil, nil
+	}
+
+	current := applyChanges(metadata, nil)
+	changes = map[string]string{}
+	for _, m := range Migrations {
+		if m.Version <= version {
+			continue
+		}
+		step := m.Apply(current)
+		if len(step) == 0 {
+			continue
+		}
+		applied = append(applied, m.Name)
+		current = applyChanges(current, step)
+		for k, v := range step {
+			changes[k] = v
+		}
+	}
+	changes[schemaVersionField] = strconv.Itoa(SchemaVersion())
+	return changes, applied
+}
+
+type MigratedCheckin struct {
+	Key        string   `json:"key"`
+	Migrations []string `json:"migrations"`
+}
+
+type MigrateReport struct {
+	Checked int `json:"checked"`
+	// check-ins already at the current schema version
+	Current  int               `json:"current"`
+	Migrated []MigratedCheckin `json:"migrated"`
+	Failed   []CheckinFailure  `json:"failed"`
+}
+
+type MigrateOptions struct {
+	// report the migrations without writing them
+	DryRun bool
+	// skip the check-ins up to this key, as returned by Checkpoint
+	After string
+	// called with the key every check-in so far is at or before, once
+	// their migration is done. It stops advancing before the first
+	// check-in which failed, and is not called after a cancellation.
+	Checkpoint func(after string) error
+	// called with the number of check-ins checked so far
+	Progress func(checked int)
+}
+
+// MigrateMetadata brings the metadata of every check-in photo to the
+// current schema version. Listing pages always end on a check-in boundary,
+// so a run can resume after the last checkpoint.
+func MigrateMetadata(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	opts MigrateOptions,
+) (MigrateReport, error) {
+	layout := keyLayout(cfg)
+	var report MigrateReport
+	// set once a check-in failed, the checkpoint then stays before it so
+	// it is retried on resume
+	stalled := false
+
+	err := forEachListPage(ctx, client, cfg, func(contents []types.Object) error {
+		last := *contents[len(contents)-1].Key
+		if last <= opts.After {
+			return nil
+		}
+
+		groups := groupRenditions(layout, contents)
+		kept := groups[:0]
+		for _, g := range groups {
+			if *g.primary.Key > opts.After {
+				kept = append(kept, g)
+			}
+		}
+		groups = kept
+
+		heads := make([]*s3.HeadObjectOutput, len(groups))
+		errs := make([]error, len(groups))
+		runWorkers(len(groups), 8, func(i int) {
+			heads[i], errs[i] = s3client.GetObjectMetadata(ctx, client, cfg.BucketName, *groups[i].primary.Key)
+		})
+		// smallest key of the check-ins of the page which failed
+		firstFailed := ""
+		fail := func(key string, err error) {
+			log.Printf("migrate %s: %v", key, err)
+			report.Failed = append(report.Failed, CheckinFailure{Key: key, Error: err.Error()})
+			if firstFailed == "" || key < firstFailed {
+				firstFailed = key
+			}
+		}
+		for i, g := range groups {
+			key := *g.primary.Key
+			report.Checked++
+			if errs[i] != nil {
+				fail(key, errs[i])
+				continue
+			}
+
+			changes, applied := migrateMetadata(heads[i].Metadata)
+			if changes == nil {
+				report.Current++
+				continue
+			}
+			if !opts.DryRun {
+				if _, err := replaceCheckinMetadata(ctx, client, cfg, key, heads[i], changes); err != nil {
+					fail(key, err)
+					continue
+				}
+			}
+			report.Migrated = append(report.Migrated, MigratedCheckin{Key: key, Migrations: applied})
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(report.Checked)
+		}
+		// requests cut short by a cancellation failed, not their check-ins
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.DryRun || opts.Checkpoint == nil || stalled {
+			return nil
+		}
+		after := last
+		if firstFailed != "" {
+			stalled = true
+			after = checkpointBefore(groups, firstFailed)
+		}
+		if after != "" {
+			if err := opts.Checkpoint(after); err != nil {
+				return fmt.Errorf("checkpoint: %w", err)
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// checkpointBefore returns the last key of the check-ins of a page before
+// a failed one, or an empty key when the failed one comes first.
+func checkpointBefore(groups []renditionGroup, failed string) string {
+	after := ""
+	for _, g := range groups {
+		if key := *g.primary.Key; key < failed && key > after {
+			after = key
+		}
+	}
+	return after
+}
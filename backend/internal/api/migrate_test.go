@@ -0,0 +1,272 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestMigrateMetadata(t *testing.T) {
+	current := strconv.Itoa(SchemaVersion())
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		changes  map[string]string
+		applied  []string
+	}{
+		{
+			name:     "numbers",
+			metadata: map[string]string{"rating": "4,50", "abv": "5%"},
+			changes:  map[string]string{"rating": "4.5", "abv": "5", schemaVersionField: current},
+			applied:  []string{"normalize-numbers"},
+		},
+		{
+			name:     "base64 header",
+			metadata: map[string]string{"City": "=?UTF-8?B?QnJ1eGVsbGVz4oCTQW5kZXJsZWNodA==?="},
+			changes:  map[string]string{"city": "Bruxelles–Anderlecht", schemaVersionField: current},
+			applied:  []string{"reencode-headers"},
+		},
+		{
+			name:     "needless encoding",
+			metadata: map[string]string{"beer": "=?utf-8?q?Saison?=", "rating": "4"},
+			changes:  map[string]string{"beer": "Saison", schemaVersionField: current},
+			applied:  []string{"reencode-headers"},
+		},
+		{
+			name:     "already normalized",
+			metadata: map[string]string{"rating": "4.25", "city": "=?utf-8?q?Bruxelles=E2=80=93Anderlecht?="},
+			changes:  map[string]string{schemaVersionField: current},
+		},
+		{
+			name:     "invalid values are kept",
+			metadata: map[string]string{"rating": "great", "venue": "=?unknown?q?Caf=C3=A9?="},
+			changes:  map[string]string{schemaVersionField: current},
+		},
+		{
+			name:     "up to date",
+			metadata: map[string]string{"rating": "4,50", schemaVersionField: current},
+		},
+		{
+			name:     "partly migrated",
+			metadata: map[string]string{"rating": "4,50", "beer": "=?utf-8?q?Saison?=", schemaVersionField: "1"},
+			changes:  map[string]string{"beer": "Saison", schemaVersionField: current},
+			applied:  []string{"reencode-headers"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, applied := migrateMetadata(tt.metadata)
+			if !reflect.DeepEqual(changes, tt.changes) || !reflect.DeepEqual(applied, tt.applied) {
+				t.Fatalf("migrateMetadata() = %v, %v, want %v, %v", changes, applied, tt.changes, tt.applied)
+			}
+			if changes == nil {
+				return
+			}
+			// a second run has nothing left to do
+			if again, _ := migrateMetadata(applyChanges(tt.metadata, changes)); again != nil {
+				t.Errorf("second run changed %v", again)
+			}
+		})
+	}
+}
+
+// newMigrateMockClient lists four check-ins over two pages, and records
+// the metadata written.
+func newMigrateMockClient(t *testing.T, written map[string]map[string]string) *MockS3Client {
+	t.Helper()
+	pages := map[string][]string{
+		"":      {"2025/11/08/JPEG/1.jpg", "2025/11/08/WEBP/1.webp", "2025/11/08/WEBP/2.webp", "2025/11/09/WEBP/3.webp"},
+		"page2": {"2025/11/10/WEBP/4.webp"},
+	}
+	heads := map[string]map[string]string{
+		"2025/11/08/WEBP/1.webp": {"id": "1", "rating": "4,5"},
+		"2025/11/08/WEBP/2.webp": {"id": "2", "rating": "4", schemaVersionField: strconv.Itoa(SchemaVersion())},
+		"2025/11/09/WEBP/3.webp": {"id": "3", "abv": "6.50"},
+		"2025/11/10/WEBP/4.webp": {"id": "4"},
+	}
+
+	return &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			token := aws.ToString(params.ContinuationToken)
+			out := &s3.ListObjectsV2Output{}
+			for _, key := range pages[token] {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+			}
+			if token == "" {
+				out.IsTruncated = aws.Bool(true)
+				out.NextContinuationToken = aws.String("page2")
+			}
+			return out, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{ETag: aws.String(`"etag"`), Metadata: heads[aws.ToString(params.Key)]}, nil
+		},
+		CopyObjectFunc: func(
+			ctx context.Context,
+			params *s3.CopyObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.CopyObjectOutput, error) {
+			written[aws.ToString(params.Key)] = params.Metadata
+			return &s3.CopyObjectOutput{}, nil
+		},
+	}
+}
+
+func TestMigrateMetadataBucket(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	written := map[string]map[string]string{}
+
+	var checkpoints []string
+	report, err := MigrateMetadata(context.Background(), newMigrateMockClient(t, written), cfg, MigrateOptions{
+		Checkpoint: func(after string) error {
+			checkpoints = append(checkpoints, after)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("MigrateMetadata() error = %v", err)
+	}
+
+	if report.Checked != 4 || report.Current != 1 || len(report.Migrated) != 3 || len(report.Failed) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if len(written) != 3 {
+		t.Errorf("expected 3 objects written, got %v", written)
+	}
+	if md := written["2025/11/08/WEBP/1.webp"]; md["rating"] != "4.5" || md[schemaVersionField] != strconv.Itoa(SchemaVersion()) {
+		t.Errorf("unexpected metadata written: %v", md)
+	}
+	// the check-in of the 9th may continue on the second page
+	wantCheckpoints := []string{"2025/11/08/WEBP/2.webp", "2025/11/10/WEBP/4.webp"}
+	if !reflect.DeepEqual(checkpoints, wantCheckpoints) {
+		t.Errorf("checkpoints = %v, want %v", checkpoints, wantCheckpoints)
+	}
+}
+
+func TestMigrateMetadataResume(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	written := map[string]map[string]string{}
+
+	report, err := MigrateMetadata(context.Background(), newMigrateMockClient(t, written), cfg, MigrateOptions{
+		After: "2025/11/09/WEBP/3.webp",
+	})
+	if err != nil {
+		t.Fatalf("MigrateMetadata() error = %v", err)
+	}
+	if report.Checked != 1 || len(written) != 1 || written["2025/11/10/WEBP/4.webp"] == nil {
+		t.Errorf("expected only the last check-in to be migrated, got %+v, %v", report, written)
+	}
+}
+
+func TestMigrateMetadataDryRun(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	written := map[string]map[string]string{}
+
+	report, err := MigrateMetadata(context.Background(), newMigrateMockClient(t, written), cfg, MigrateOptions{
+		DryRun: true,
+		Checkpoint: func(after string) error {
+			t.Errorf("unexpected checkpoint %s in a dry run", after)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("MigrateMetadata() error = %v", err)
+	}
+	if len(report.Migrated) != 3 || len(written) != 0 {
+		t.Errorf("expected 3 migrations reported and none written, got %+v, %v", report, written)
+	}
+	want := MigratedCheckin{Key: "2025/11/08/WEBP/1.webp", Migrations: []string{"normalize-numbers"}}
+	if !reflect.DeepEqual(report.Migrated[0], want) {
+		t.Errorf("Migrated[0] = %+v, want %+v", report.Migrated[0], want)
+	}
+}
+
+func TestMigrateMetadataFailures(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		written := map[string]map[string]string{}
+		client := newMigrateMockClient(t, written)
+		head := client.HeadObjectFunc
+		client.HeadObjectFunc = func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			// interrupted while the first page is read
+			cancel()
+			return head(ctx, params, optFns...)
+		}
+		client.CopyObjectFunc = func(
+			ctx context.Context,
+			params *s3.CopyObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.CopyObjectOutput, error) {
+			return nil, ctx.Err()
+		}
+
+		_, err := MigrateMetadata(ctx, client, cfg, MigrateOptions{
+			Checkpoint: func(after string) error {
+				t.Errorf("unexpected checkpoint %s after a cancellation", after)
+				return nil
+			},
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		written := map[string]map[string]string{}
+		client := newMigrateMockClient(t, written)
+		copyObject := client.CopyObjectFunc
+		client.CopyObjectFunc = func(
+			ctx context.Context,
+			params *s3.CopyObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.CopyObjectOutput, error) {
+			if aws.ToString(params.Key) == "2025/11/09/WEBP/3.webp" {
+				return nil, errors.New("internal error")
+			}
+			return copyObject(ctx, params, optFns...)
+		}
+
+		var checkpoints []string
+		report, err := MigrateMetadata(context.Background(), client, cfg, MigrateOptions{
+			Checkpoint: func(after string) error {
+				checkpoints = append(checkpoints, after)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("MigrateMetadata() error = %v", err)
+		}
+		if len(report.Failed) != 1 || written["2025/11/10/WEBP/4.webp"] == nil {
+			t.Errorf("expected the other check-ins to be migrated, got %+v, %v", report, written)
+		}
+		// a resumed run retries the failed check-in
+		want := []string{"2025/11/08/WEBP/2.webp"}
+		if !reflect.DeepEqual(checkpoints, want) {
+			t.Errorf("checkpoints = %v, want %v", checkpoints, want)
+		}
+	})
+}
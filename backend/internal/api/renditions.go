@@ -0,0 +1,288 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/keylayout"
+	"beers/backend/internal/s3client"
+	"context"
+	"fmt"
+	"mime"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Variant is one stored rendition of a check-in photo.
+type Variant struct {
+	URL         string `json:"url"`
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+}
+
+// renditionTypes lists the formats photos are served in, from the most to
+// the least preferred. Other renditions, like the original upload, are only
+// picked when the client asks for them explicitly.
+var renditionTypes = []string{"image/avif", "image/webp", "image/jpeg", "image/png"}
+
+// decodableTypes lists the formats thumbnails and the image index can be
+// computed from, from the most to the least preferred.
+var decodableTypes = []string{"image/webp", "image/jpeg", "image/png"}
+
+// extension of the JSON objects holding extra check-in metadata
+const sidecarExt = "json"
+
+// renditionGroup holds every rendition stored for one check-in.
+type renditionGroup struct {
+	// rendition the metadata, thumbnails and index entry are read from
+	primary  types.Object
+	variants []types.Object
+	// key of the metadata sidecar, empty when there is none
+	sidecar string
+}
+
+// isRenditionKey reports whether a key is a photo following the layout,
+// metadata sidecars are not.
+func isRenditionKey(layout *keylayout.Layout, key string) bool {
+	k, ok := layout.Match(key)
+	return ok && !strings.EqualFold(k.Ext, sidecarExt)
+}
+
+// renditionType returns the content type of a rendition from its extension.
+func renditionType(key string) string {
+	t, _, err := mime.ParseMediaType(mime.TypeByExtension(path.Ext(key)))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return t
+}
+
+// isDecodableKey reports whether thumbnails can be rendered from a key.
+func isDecodableKey(layout *keylayout.Layout, key string) bool {
+	return isRenditionKey(layout, key) && typeRank(decodableTypes, renditionType(key)) < len(decodableTypes)
+}
+
+// typeRank returns the position of t in types, or len(types) if absent.
+func typeRank(types []string, t string) int {
+	for i, candidate := range types {
+		if candidate == t {
+			return i
+		}
+	}
+	return len(types)
+}
+
+// groupRenditions gathers the renditions and sidecar of each check-in, in
+// the order the check-ins first appear. Objects which do not follow the key
+// layout, and check-ins without any photo, are dropped.
+func groupRenditions(layout *keylayout.Layout, contents []types.Object) []renditionGroup {
+	var groups []renditionGroup
+	index := map[string]int{}
+	for _, obj := range contents {
+		if obj.Key == nil {
+			continue
+		}
+		k, ok := layout.Match(*obj.Key)
+		if !ok {
+			continue
+		}
+		checkin := k.Checkin()
+
+		i, seen := index[checkin]
+		if !seen {
+			i = len(groups)
+			index[checkin] = i
+			groups = append(groups, renditionGroup{})
+		}
+		if strings.EqualFold(k.Ext, sidecarExt) {
+			groups[i].sidecar = *obj.Key
+			continue
+		}
+		groups[i].variants = append(groups[i].variants, obj)
+	}
+
+	withPhotos := groups[:0]
+	for _, g := range groups {
+		if len(g.variants) > 0 {
+			withPhotos = append(withPhotos, g)
+		}
+	}
+	groups = withPhotos
+
+	for i := range groups {
+		g := &groups[i]
+		sort.SliceStable(g.variants, func(a, b int) bool {
+			return typeRank(renditionTypes, renditionType(*g.variants[a].Key)) <
+				typeRank(renditionTypes, renditionType(*g.variants[b].Key))
+		})
+		g.primary = g.variants[0]
+		best := len(decodableTypes)
+		for _, v := range g.variants {
+			if rank := typeRank(decodableTypes, renditionType(*v.Key)); rank < best {
+				g.primary, best = v, rank
+			}
+		}
+	}
+	return groups
+}
+
+// splitTrailingCheckins holds back the objects sharing the key prefix of the
+// last object of a listing page. Renditions of a check-in only differ after
+// that prefix, so they may continue on the next page.
+func splitTrailingCheckins(
+	layout *keylayout.Layout,
+	contents []types.Object,
+) (complete, pending []types.Object) {
+	groupPrefix := func(obj types.Object) string {
+		return layout.GroupPrefix(aws.ToString(obj.Key))
+	}
+
+	n := len(contents)
+	if n == 0 {
+		return nil, nil
+	}
+	last := groupPrefix(contents[n-1])
+	for n > 0 && groupPrefix(contents[n-1]) == last {
+		n--
+	}
+	return contents[:n], contents[n:]
+}
+
+// acceptQuality returns the quality the Accept header gives to a content
+// type, and whether the header names the type rather than a wildcard. An
+// empty header accepts everything.
+func acceptQuality(accept, contentType string) (q float64, exact bool) {
+	if strings.TrimSpace(accept) == "" {
+		return 1, false
+	}
+
+	mainType, _, _ := strings.Cut(contentType, "/")
+	best, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var s int
+		switch {
+		case mediaType == contentType:
+			s = 2
+		case mediaType == mainType+"/*":
+			s = 1
+		case mediaType == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		best, specificity = q, s
+	}
+	return best, specificity == 2
+}
+
+// chooseVariant picks the variant the Accept header rates best. On ties, a
+// type the header names wins over one only matched by a wildcard, since
+// browsers list the newer formats they support, then the lighter format
+// wins. Renditions outside renditionTypes must be named explicitly by the
+// header. When nothing is acceptable, ok is false.
+func chooseVariant(variants []Variant, accept string) (chosen Variant, ok bool) {
+	bestQ, bestExact, bestRank := 0.0, false, 0
+	for _, v := range variants {
+		rank := typeRank(renditionTypes, v.ContentType)
+		q, exact := acceptQuality(accept, v.ContentType)
+		if q <= 0 || (rank == len(renditionTypes) && !exact) {
+			continue
+		}
+		better := q > bestQ ||
+			(q == bestQ && exact && !bestExact) ||
+			(q == bestQ && exact == bestExact && rank < bestRank)
+		if !ok || better {
+			chosen, bestQ, bestExact, bestRank, ok = v, q, exact, rank, true
+		}
+	}
+	return chosen, ok
+}
+
+// groupVariants lists the renditions of a check-in without their URLs.
+func groupVariants(g renditionGroup) []Variant {
+	variants := make([]Variant, 0, len(g.variants))
+	for _, obj := range g.variants {
+		variants = append(variants, Variant{Key: *obj.Key, ContentType: renditionType(*obj.Key)})
+	}
+	return variants
+}
+
+// findRenditionGroup lists the renditions and sidecar of the check-in a key
+// belongs to. found is false when the check-in has no photo.
+func findRenditionGroup(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+) (group renditionGroup, found bool, err error) {
+	groups, err := listNeighbourGroups(ctx, client, cfg, key)
+	if err != nil {
+		return renditionGroup{}, false, err
+	}
+	group, found = checkinGroupOf(keyLayout(cfg), groups, key)
+	return group, found, nil
+}
+
+// listNeighbourGroups lists the check-ins sharing the group prefix of a key:
+// its own, and the ones its photo may be an extra photo of.
+func listNeighbourGroups(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+) ([]renditionGroup, error) {
+	layout := keyLayout(cfg)
+	if _, ok := layout.Match(key); !ok {
+		return nil, nil
+	}
+
+	var (
+		contents []types.Object
+		token    string
+		prefix   = layout.GroupPrefix(key)
+	)
+	for {
+		out, err := s3client.ListObjects(ctx, client, cfg.BucketName, prefix, token)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, err)
+		}
+		contents = append(contents, out.Contents...)
+		if !aws.ToBool(out.IsTruncated) || aws.ToString(out.NextContinuationToken) == "" {
+			break
+		}
+		token = aws.ToString(out.NextContinuationToken)
+	}
+	return groupRenditions(layout, contents), nil
+}
+
+// checkinGroupOf returns the group of the check-in a key belongs to.
+func checkinGroupOf(layout *keylayout.Layout, groups []renditionGroup, key string) (renditionGroup, bool) {
+	k, ok := layout.Match(key)
+	if !ok {
+		return renditionGroup{}, false
+	}
+	for _, g := range groups {
+		if gk, _ := layout.Match(*g.primary.Key); gk.Checkin() == k.Checkin() {
+			return g, true
+		}
+	}
+	return renditionGroup{}, false
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"beers/backend/internal/keylayout"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestIsRenditionKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"2025/11/08/WEBP/image1.webp", true},
+		{"2025/11/08/AVIF/image1.avif", true},
+		{"2025/11/08/WEBP/image1.json", false},
+		{"2025/11/08/WEBP/image1.JSON", false},
+		{"extra/image1-2.webp", false},
+	}
+
+	for _, tt := range tests {
+		if got := isRenditionKey(keylayout.Default, tt.key); got != tt.expected {
+			t.Errorf("isRenditionKey(%q) = %v, want %v", tt.key, got, tt.expected)
+		}
+	}
+}
+
+func TestIsDecodableKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"2025/11/08/WEBP/image1.webp", true},
+		{"2025/11/08/JPEG/image1.jpg", true},
+		{"2025/11/08/AVIF/image1.avif", false},
+		{"2025/11/08/image1.webp", false},
+		{"_meta/index.json", false},
+	}
+
+	for _, tt := range tests {
+		if got := isDecodableKey(keylayout.Default, tt.key); got != tt.expected {
+			t.Errorf("isDecodableKey(%q) = %v, want %v", tt.key, got, tt.expected)
+		}
+	}
+}
+
+func TestGroupRenditions(t *testing.T) {
+	objs := []types.Object{
+		{Key: aws.String("2025/11/08/AVIF/image1.avif")},
+		{Key: aws.String("2025/11/08/AVIF/image2.avif")},
+		{Key: aws.String("2025/11/08/JPEG/image1.jpg")},
+		{Key: aws.String("2025/11/08/ORIGINAL/image1.heic")},
+		{Key: aws.String("2025/11/08/WEBP/image1.json")},
+		{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+		{Key: aws.String("2025/11/08/WEBP/image3.json")},
+		{Key: aws.String("2025/11/08/notes.txt")},
+	}
+
+	groups := groupRenditions(keylayout.Default, objs)
+	if got := len(groups); got != 2 {
+		t.Fatalf("expected 2 check-ins, got %d", got)
+	}
+
+	first := groups[0]
+	if got := *first.primary.Key; got != "2025/11/08/WEBP/image1.webp" {
+		t.Errorf("primary = %q, want the webp rendition", got)
+	}
+	if first.sidecar != "2025/11/08/WEBP/image1.json" {
+		t.Errorf("sidecar = %q, want the json object", first.sidecar)
+	}
+	want := []string{
+		"2025/11/08/AVIF/image1.avif",
+		"2025/11/08/WEBP/image1.webp",
+		"2025/11/08/JPEG/image1.jpg",
+		"2025/11/08/ORIGINAL/image1.heic",
+	}
+	if len(first.variants) != len(want) {
+		t.Fatalf("expected %d variants, got %d", len(want), len(first.variants))
+	}
+	for i, key := range want {
+		if got := *first.variants[i].Key; got != key {
+			t.Errorf("variant %d = %q, want %q", i, got, key)
+		}
+	}
+
+	// nothing to decode, the only rendition is used
+	if got := *groups[1].primary.Key; got != "2025/11/08/AVIF/image2.avif" {
+		t.Errorf("primary = %q, want the avif rendition", got)
+	}
+}
+
+func TestSplitTrailingCheckins(t *testing.T) {
+	objs := []types.Object{
+		{Key: aws.String("2025/11/07/WEBP/image1.webp")},
+		{Key: aws.String("2025/11/08/JPEG/image2.jpg")},
+		{Key: aws.String("2025/11/08/JPEG/image3.jpg")},
+	}
+
+	complete, pending := splitTrailingCheckins(keylayout.Default, objs)
+	if len(complete) != 1 || len(pending) != 2 {
+		t.Errorf("expected 1 complete and 2 pending objects, got %d and %d", len(complete), len(pending))
+	}
+}
+
+func TestChooseVariant(t *testing.T) {
+	variants := []Variant{
+		{Key: "WEBP/image1.webp", ContentType: "image/webp"},
+		{Key: "AVIF/image1.avif", ContentType: "image/avif"},
+		{Key: "JPEG/image1.jpg", ContentType: "image/jpeg"},
+		{Key: "ORIGINAL/image1.heic", ContentType: "image/heic"},
+	}
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"no header", "", "image/avif"},
+		{"browser", "image/avif,image/webp,image/apng,image/*,*/*;q=0.8", "image/avif"},
+		{"no avif", "image/webp,image/*;q=0.8", "image/webp"},
+		{"avif only by wildcard", "image/webp,*/*", "image/webp"},
+		{"avif refused", "image/avif;q=0,image/*", "image/webp"},
+		{"jpeg only", "image/jpeg", "image/jpeg"},
+		{"original", "image/heic", "image/heic"},
+		{"json", "application/json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := chooseVariant(variants, tt.accept)
+			if got.ContentType != tt.want || ok != (tt.want != "") {
+				t.Errorf("chooseVariant() = %q, %v, want %q", got.ContentType, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,558 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"beers/backend/internal/thumbnail"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// check-ins per JSON page of a static build
+	defaultStaticPageSize = 50
+	// limit how many photos are downloaded or decoded at once
+	staticWorkers = 4
+)
+
+// directories of a static build, rebuilt on every run
+const (
+	staticDataDir     = "api"
+	staticCheckinsDir = "checkins"
+	staticMediaDir    = "media"
+	staticThumbsDir   = "img"
+)
+
+var errNoPermanentURL = errors.New("photos have no permanent URL without R2_PUBLIC_URL, copy them instead")
+
+// StaticOptions configures a static build of the journal.
+type StaticOptions struct {
+	// absolute URL the site will be hosted at, the feeds link to it
+	BaseURL string
+	// check-ins per JSON page, defaults to 50
+	PageSize int
+	// copy the photos into the build instead of linking to the bucket
+	Photos bool
+	// render the thumbnails of the srcsets into the build
+	Thumbnails bool
+	// called with the number of check-ins written so far
+	Progress func(done int)
+}
+
+// StaticReport sums up a static build.
+type StaticReport struct {
+	Checkins   int `json:"checkins"`
+	Pages      int `json:"pages"`
+	Photos     int `json:"photos"`
+	Thumbnails int `json:"thumbnails"`
+	// files of an earlier build which are gone from the journal
+	Removed int `json:"removed"`
+}
+
+// staticPageURL returns the URL of a JSON page. The first page is served at
+// the URL the frontend requests first, later ones are linked by next.
+func staticPageURL(page int) string {
+	if page == 1 {
+		return "/" + staticDataDir + "/images"
+	}
+	return fmt.Sprintf("/%s/pages/%d.json", staticDataDir, page)
+}
+
+// staticCheckinURL returns the URL of the HTML page of a check-in.
+func staticCheckinURL(cfg *config.AppConfig, key string) string {
+	k, ok := keyLayout(cfg).Match(key)
+	if !ok {
+		return ""
+	}
+	u, err := url.JoinPath("/"+staticCheckinsDir, k.Checkin()+".html")
+	if err != nil {
+		return ""
+	}
+	return u
+}
+
+func staticThumbnailURL(key string, width int) string {
+	u, err := url.JoinPath("/"+staticThumbsDir, key, fmt.Sprintf("%d.jpg", width))
+	if err != nil {
+		return ""
+	}
+	return u
+}
+
+// staticBuild writes the files of a static build, remembering them so the
+// leftovers of earlier builds can be removed.
+type staticBuild struct {
+	dir string
+
+	mu      sync.Mutex
+	written map[string]bool
+}
+
+func (b *staticBuild) keep(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.written[filepath.FromSlash(name)] = true
+}
+
+// exists reports whether an earlier build already wrote a file. Photos and
+// their thumbnails never change, so they are only written once.
+func (b *staticBuild) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(b.dir, filepath.FromSlash(name)))
+	return err == nil
+}
+
+// write creates a file of the build through a temporary file, so an
+// interrupted build never leaves a truncated one.
+func (b *staticBuild) write(name string, fn func(w io.Writer) error) error {
+	p := filepath.Join(b.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := fn(f); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return err
+	}
+	b.keep(name)
+	return nil
+}
+
+func (b *staticBuild) writeJSON(name string, v any) error {
+	return b.write(name, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+}
+
+// prune removes the files of the generated directories which this build
+// did not write, like the pages of check-ins hidden since the last one.
+func (b *staticBuild) prune() (int, error) {
+	removed := 0
+	for _, sub := range []string{staticDataDir, staticCheckinsDir, staticMediaDir, staticThumbsDir} {
+		root := filepath.Join(b.dir, sub)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(b.dir, p)
+			if err != nil {
+				return err
+			}
+			if b.written[rel] {
+				return nil
+			}
+			removed++
+			return os.Remove(p)
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// copyObject downloads a bucket object into the build.
+func (b *staticBuild) copyObject(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key, name string,
+) (bool, error) {
+	if b.exists(name) {
+		b.keep(name)
+		return false, nil
+	}
+	out, err := s3client.GetObject(ctx, client, cfg.BucketName, key)
+	if err != nil {
+		return false, fmt.Errorf("get %s: %w", key, err)
+	}
+	defer out.Body.Close()
+	return true, b.write(name, func(w io.Writer) error {
+		_, err := io.Copy(w, out.Body)
+		return err
+	})
+}
+
+// renderThumbnails writes the thumbnails of the srcset of a photo.
+func (b *staticBuild) renderThumbnails(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	key string,
+) (int, error) {
+	var missing []int
+	for _, width := range thumbnailWidths {
+		name := strings.TrimPrefix(staticThumbnailURL(key, width), "/")
+		if b.exists(name) {
+			b.keep(name)
+			continue
+		}
+		missing = append(missing, width)
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	out, err := s3client.GetObject(ctx, client, cfg.BucketName, key)
+	if err != nil {
+		return 0, fmt.Errorf("get %s: %w", key, err)
+	}
+	defer out.Body.Close()
+	photo, err := io.ReadAll(out.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read %s: %w", key, err)
+	}
+
+	for _, width := range missing {
+		name := strings.TrimPrefix(staticThumbnailURL(key, width), "/")
+		err := b.write(name, func(w io.Writer) error {
+			return thumbnail.Render(w, bytes.NewReader(photo), thumbnail.Options{Width: width})
+		})
+		if err != nil {
+			return 0, fmt.Errorf("thumbnail %s: %w", name, err)
+		}
+	}
+	return len(missing), nil
+}
+
+// staticAssets copies the photos and renders the thumbnails of one
+// check-in, and points its URLs at them.
+func (b *staticBuild) staticAssets(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	img *Image,
+	opts StaticOptions,
+	report *StaticReport,
+	mu *sync.Mutex,
+) error {
+	photoURL := func(key string) (string, error) {
+		return url.JoinPath(cfg.PublicURL, key)
+	}
+	if opts.Photos {
+		photoURL = func(key string) (string, error) {
+			name := path.Join(staticMediaDir, key)
+			copied, err := b.copyObject(ctx, client, cfg, key, name)
+			if copied {
+				mu.Lock()
+				report.Photos++
+				mu.Unlock()
+			}
+			if err != nil {
+				return "", err
+			}
+			return url.JoinPath("/", name)
+		}
+	}
+
+	var err error
+	for i := range img.Variants {
+		v := &img.Variants[i]
+		if v.URL, err = photoURL(v.Key); err != nil {
+			return err
+		}
+		if v.Key == img.Key {
+			img.URL = v.URL
+		}
+	}
+	img.Photos = nil
+	for _, key := range img.Metadata.Photos {
+		u, err := photoURL(key)
+		if err != nil {
+			return err
+		}
+		img.Photos = append(img.Photos, u)
+	}
+
+	// thumbnails are served by the backend, which is not there
+	img.Srcset = ""
+	if !opts.Thumbnails || !isDecodableKey(keyLayout(cfg), img.Key) {
+		return nil
+	}
+	rendered, err := b.renderThumbnails(ctx, client, cfg, img.Key)
+	if err != nil {
+		// the full photo is still there
+		log.Printf("error rendering thumbnails of %s: %v", img.Key, err)
+		return nil
+	}
+	mu.Lock()
+	report.Thumbnails += rendered
+	mu.Unlock()
+
+	entries := make([]string, 0, len(thumbnailWidths))
+	for _, w := range thumbnailWidths {
+		entries = append(entries, fmt.Sprintf("%s %dw", staticThumbnailURL(img.Key, w), w))
+	}
+	img.Srcset = strings.Join(entries, ", ")
+	return nil
+}
+
+type staticCheckinPage struct {
+	Image   Image
+	Title   string
+	Date    string
+	Place   string
+	Details [][2]string
+	Newer   string
+	Older   string
+}
+
+var staticCheckinTemplate = template.Must(template.New("checkin").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · Beers</title>
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:image" content="{{.Image.URL}}">
+<link rel="alternate" type="application/atom+xml" title="Beers" href="/feed.atom">
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+img { max-width: 100%; height: auto; border-radius: 4px; }
+dt { font-weight: bold; margin-top: .5rem; }
+dd { margin: 0; white-space: pre-line; }
+nav { display: flex; justify-content: space-between; margin: 1rem 0; }
+</style>
+</head>
+<body>
+<nav><a href="/">All check-ins</a>{{if .Newer}} <a href="{{.Newer}}">Newer</a>{{end}}{{if .Older}} <a href="{{.Older}}">Older</a>{{end}}</nav>
+<h1>{{.Title}}</h1>
+{{if .Date}}<p>{{.Date}}{{if .Place}} · {{.Place}}{{end}}</p>{{end}}
+<p><img src="{{.Image.URL}}"{{if .Image.Srcset}} srcset="{{.Image.Srcset}}" sizes="(max-width: 48rem) 100vw, 48rem"{{end}}{{if .Image.Width}} width="{{.Image.Width}}" height="{{.Image.Height}}"{{end}} alt="{{.Title}}"></p>
+{{range .Image.Photos}}<p><img src="{{.}}" alt=""></p>
+{{end}}<dl>
+{{range .Details}}<dt>{{index . 0}}</dt><dd>{{index . 1}}</dd>
+{{end}}</dl>
+</body>
+</html>
+`))
+
+// newStaticCheckinPage describes a check-in for its HTML page. newer and
+// older link to its neighbours in the journal.
+func newStaticCheckinPage(img Image, newer, older string) staticCheckinPage {
+	md := img.Metadata
+	page := staticCheckinPage{
+		Image: img,
+		Title: checkinTitle(md),
+		Place: checkinPlace(md),
+		Newer: newer,
+		Older: older,
+	}
+	if at, err := parseCheckinDate(md); err == nil {
+		page.Date = at.Format("Monday 2 January 2006, 15:04")
+	}
+
+	add := func(label, value string) {
+		if value != "" {
+			page.Details = append(page.Details, [2]string{label, value})
+		}
+	}
+	add("Style", md.Style)
+	if md.ABV != "" {
+		add("ABV", md.ABV+"%")
+	}
+	add("Rating", md.Rating)
+	add("Brewery country", md.BreweryCountry)
+	add("Serving", md.ServingType)
+	add("Price", md.Price)
+	add("With", strings.Join(md.TaggedFriends, ", "))
+	add("Comment", md.Comment)
+	return page
+}
+
+// checkBaseURL returns an error unless base is an absolute http(s) URL.
+func checkBaseURL(base string) error {
+	if u, err := url.Parse(base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base URL %q", base)
+	}
+	return nil
+}
+
+// absoluteImages returns copies of the images with the URLs of photos served
+// by the backend made absolute against base, as feed readers and exported
+// files need.
+func absoluteImages(images []Image, base string) []Image {
+	abs := func(u string) string {
+		if !strings.HasPrefix(u, "/") {
+			return u
+		}
+		return strings.TrimSuffix(base, "/") + u
+	}
+	out := make([]Image, len(images))
+	for i, img := range images {
+		img.URL = abs(img.URL)
+		img.Variants = append([]Variant(nil), img.Variants...)
+		for j := range img.Variants {
+			img.Variants[j].URL = abs(img.Variants[j].URL)
+		}
+		out[i] = img
+	}
+	return out
+}
+
+// BuildStatic renders the journal into dir as a site any static host can
+// serve from its root: the JSON pages the frontend loads, an HTML page per
+// check-in, the feeds and the calendar. Photos are linked from the public
+// bucket unless copied, thumbnails are only available when rendered.
+// Generated files of an earlier build which are not part of this one are
+// removed, copied photos and thumbnails are reused.
+func BuildStatic(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	dir string,
+	opts StaticOptions,
+) (StaticReport, error) {
+	var report StaticReport
+	if opts.BaseURL == "" {
+		return report, errors.New("the base URL of the site is required for the feeds")
+	}
+	if err := checkBaseURL(opts.BaseURL); err != nil {
+		return report, err
+	}
+	if !opts.Photos && cfg.PublicURL == "" {
+		return report, errNoPermanentURL
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultStaticPageSize
+	}
+
+	images, err := ListAllImages(ctx, client, cfg)
+	if err != nil {
+		return report, err
+	}
+	sortImagesNewestFirst(images)
+	report.Checkins = len(images)
+
+	b := &staticBuild{dir: dir, written: map[string]bool{}}
+
+	// photos and thumbnails first, the pages link to them
+	var (
+		mu       sync.Mutex
+		done     int
+		firstErr error
+		jobs     = make(chan int)
+		wg       sync.WaitGroup
+	)
+	wg.Add(staticWorkers)
+	for w := 0; w < staticWorkers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := b.staticAssets(ctx, client, cfg, &images[i], opts, &report, &mu)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range images {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return report, firstErr
+	}
+
+	// JSON pages, in the format of /api/images
+	for start := 0; start < len(images) || start == 0; start += opts.PageSize {
+		end := min(start+opts.PageSize, len(images))
+		page := start/opts.PageSize + 1
+		resp := ImageResponse{Images: images[start:end], HasMore: end < len(images)}
+		if resp.HasMore {
+			resp.Next = staticPageURL(page + 1)
+		}
+		if err := b.writeJSON(strings.TrimPrefix(staticPageURL(page), "/"), resp); err != nil {
+			return report, err
+		}
+		report.Pages++
+	}
+
+	for i, img := range images {
+		var newer, older string
+		if i > 0 {
+			newer = staticCheckinURL(cfg, images[i-1].Key)
+		}
+		if i+1 < len(images) {
+			older = staticCheckinURL(cfg, images[i+1].Key)
+		}
+		name := strings.TrimPrefix(staticCheckinURL(cfg, img.Key), "/")
+		page := newStaticCheckinPage(img, newer, older)
+		if err := b.write(name, func(w io.Writer) error {
+			return staticCheckinTemplate.Execute(w, page)
+		}); err != nil {
+			return report, err
+		}
+	}
+
+	base := strings.TrimSuffix(opts.BaseURL, "/")
+	recent := absoluteImages(images[:min(feedSize, len(images))], base)
+	for name, render := range map[string]func(io.Writer, feed) error{
+		"feed.atom": renderAtom,
+		"feed.rss":  renderRSS,
+		"feed.json": renderJSONFeed,
+	} {
+		f := feed{siteURL: base, feedURL: base + "/" + name, images: recent}
+		if len(recent) > 0 {
+			f.updated = feedTime(recent[0])
+		}
+		if err := b.write(name, func(w io.Writer) error { return render(w, f) }); err != nil {
+			return report, err
+		}
+	}
+
+	err = b.write("calendar.ics", func(w io.Writer) error {
+		cw, err := newICalWriter(w, exportSource{})
+		if err != nil {
+			return err
+		}
+		for _, img := range absoluteImages(images, base) {
+			if err := cw.Write(img); err != nil {
+				return err
+			}
+		}
+		return cw.Close()
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.Removed, err = b.prune()
+	return report, err
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func newStaticMockClient(t *testing.T, metadata map[string]map[string]string) *MockS3Client {
+	t.Helper()
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newExportMockClient(metadata)
+	client.GetObjectFunc = func(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error) {
+		body := photo.Bytes()
+		if !strings.HasSuffix(aws.ToString(params.Key), ".png") {
+			body = []byte("photo:" + aws.ToString(params.Key))
+		}
+		return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+	}
+	return client
+}
+
+func readStaticFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatalf("missing %s: %v", name, err)
+	}
+	return string(b)
+}
+
+func TestBuildStatic(t *testing.T) {
+	// a private bucket served through the backend
+	cfg := &config.AppConfig{BucketName: "test-bucket", ImageProxy: true}
+	metadata := map[string]map[string]string{
+		"2025/11/08/WEBP/1.webp": {"id": "1", "beer": "Gueuze", "brewery": "Cantillon", "date": "2025-11-08 18:30:00"},
+		"2025/11/02/PNG/2.png":   {"id": "2", "beer": "Orval", "brewery": "Orval", "date": "2025-11-02 20:00:00"},
+		"2025/10/12/WEBP/3.webp": {"id": "3", "beer": "Kriek", "comment": "<b>sour</b>", "date": "2025-10-12 17:00:00"},
+		"2025/10/01/WEBP/4.webp": {"id": "4", "beer": "Hidden", "hidden": "true", "date": "2025-10-01 17:00:00"},
+	}
+	client := newStaticMockClient(t, metadata)
+	dir := t.TempDir()
+	opts := StaticOptions{BaseURL: "https://beers.example.com/", PageSize: 2, Photos: true, Thumbnails: true}
+
+	report, err := BuildStatic(context.Background(), client, cfg, dir, opts)
+	if err != nil {
+		t.Fatalf("BuildStatic() error = %v", err)
+	}
+	expected := StaticReport{Checkins: 3, Pages: 2, Photos: 3, Thumbnails: len(thumbnailWidths)}
+	if report != expected {
+		t.Errorf("report = %+v, want %+v", report, expected)
+	}
+
+	var first ImageResponse
+	if err := json.Unmarshal([]byte(readStaticFile(t, dir, "api/images")), &first); err != nil {
+		t.Fatalf("could not decode the first page: %v", err)
+	}
+	if len(first.Images) != 2 || !first.HasMore || first.Next != "/api/pages/2.json" {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	if first.Images[0].Metadata.Beer != "Gueuze" || first.Images[0].URL != "/media/2025/11/08/WEBP/1.webp" {
+		t.Errorf("unexpected newest check-in: %+v", first.Images[0])
+	}
+	if first.Images[0].Srcset != "" {
+		t.Errorf("expected no srcset for an undecodable photo, got %q", first.Images[0].Srcset)
+	}
+	if !strings.Contains(first.Images[1].Srcset, "/img/2025/11/02/PNG/2.png/320.jpg 320w") {
+		t.Errorf("unexpected srcset: %q", first.Images[1].Srcset)
+	}
+
+	var last ImageResponse
+	if err := json.Unmarshal([]byte(readStaticFile(t, dir, "api/pages/2.json")), &last); err != nil {
+		t.Fatalf("could not decode the last page: %v", err)
+	}
+	if len(last.Images) != 1 || last.HasMore || last.Next != "" {
+		t.Errorf("unexpected last page: %+v", last)
+	}
+
+	if got := readStaticFile(t, dir, "media/2025/10/12/WEBP/3.webp"); got != "photo:2025/10/12/WEBP/3.webp" {
+		t.Errorf("unexpected photo content: %q", got)
+	}
+	readStaticFile(t, dir, "img/2025/11/02/PNG/2.png/640.jpg")
+
+	page := readStaticFile(t, dir, "checkins/2025/10/12/3.html")
+	if !strings.Contains(page, "&lt;b&gt;sour&lt;/b&gt;") || !strings.Contains(page, `href="/checkins/2025/11/02/2.html"`) {
+		t.Errorf("unexpected check-in page:\n%s", page)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "checkins/2025/10/01/4.html")); err == nil {
+		t.Errorf("expected no page for the hidden check-in")
+	}
+
+	atom := readStaticFile(t, dir, "feed.atom")
+	if !strings.Contains(atom, "https://beers.example.com/media/2025/11/08/WEBP/1.webp") ||
+		!strings.Contains(atom, `href="https://beers.example.com/feed.atom"`) {
+		t.Errorf("expected absolute URLs in the feed:\n%s", atom)
+	}
+	readStaticFile(t, dir, "feed.rss")
+	readStaticFile(t, dir, "feed.json")
+	if ics := readStaticFile(t, dir, "calendar.ics"); strings.Count(ics, "BEGIN:VEVENT") != 3 {
+		t.Errorf("expected 3 events in the calendar:\n%s", ics)
+	}
+
+	// a later build reuses the photos and drops the check-ins gone since
+	delete(metadata, "2025/10/12/WEBP/3.webp")
+	report, err = BuildStatic(context.Background(), client, cfg, dir, opts)
+	if err != nil {
+		t.Fatalf("BuildStatic() error = %v", err)
+	}
+	expected = StaticReport{Checkins: 2, Pages: 1, Removed: 3}
+	if report != expected {
+		t.Errorf("report = %+v, want %+v", report, expected)
+	}
+	for _, name := range []string{"api/pages/2.json", "checkins/2025/10/12/3.html", "media/2025/10/12/WEBP/3.webp"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			t.Errorf("expected %s to be removed", name)
+		}
+	}
+}
+
+func TestBuildStaticPublicURL(t *testing.T) {
+	metadata := map[string]map[string]string{
+		"2025/11/08/WEBP/1.webp": {"id": "1", "beer": "Gueuze", "date": "2025-11-08 18:30:00"},
+	}
+	client := newStaticMockClient(t, metadata)
+	opts := StaticOptions{BaseURL: "https://beers.example.com"}
+
+	_, err := BuildStatic(context.Background(), client, &config.AppConfig{BucketName: "test-bucket"}, t.TempDir(), opts)
+	if !errors.Is(err, errNoPermanentURL) {
+		t.Errorf("error = %v, want %v", err, errNoPermanentURL)
+	}
+
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://photos.example.com", ImageProxy: true}
+	dir := t.TempDir()
+	if _, err := BuildStatic(context.Background(), client, cfg, dir, opts); err != nil {
+		t.Fatalf("BuildStatic() error = %v", err)
+	}
+	var first ImageResponse
+	if err := json.Unmarshal([]byte(readStaticFile(t, dir, "api/images")), &first); err != nil {
+		t.Fatalf("could not decode the first page: %v", err)
+	}
+	if len(first.Images) != 1 || first.Images[0].URL != "https://photos.example.com/2025/11/08/WEBP/1.webp" {
+		t.Errorf("expected the public bucket URL, got %+v", first.Images)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "media")); err == nil {
+		t.Errorf("expected no copied photos")
+	}
+
+	opts.BaseURL = "beers.example.com"
+	if _, err := BuildStatic(context.Background(), client, cfg, t.TempDir(), opts); err == nil {
+		t.Errorf("expected an error for a relative base URL")
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/s3client"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// number of entries of each top list
+const statsTopN = 10
+
+type StatsCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type Stats struct {
+	Checkins  int `json:"checkins"`
+	Beers     int `json:"beers"`
+	Breweries int `json:"breweries"`
+	Venues    int `json:"venues"`
+	Countries int `json:"countries"`
+	// mean of the rated check-ins
+	AverageRating float64      `json:"average_rating"`
+	First         string       `json:"first,omitempty"`
+	Last          string       `json:"last,omitempty"`
+	PerYear       []StatsCount `json:"per_year"`
+	TopBreweries  []StatsCount `json:"top_breweries"`
+	TopStyles     []StatsCount `json:"top_styles"`
+	TopCountries  []StatsCount `json:"top_countries"`
+}
+
+// statsCounter tallies the check-ins, one listing page at a time.
+type statsCounter struct {
+	checkins    int
+	ratingSum   float64
+	rated       int
+	first, last string
+	beers       map[string]int
+	breweries   map[string]int
+	venues      map[string]int
+	countries   map[string]int
+	styles      map[string]int
+	years       map[string]int
+}
+
+func newStatsCounter() *statsCounter {
+	return &statsCounter{
+		beers:     map[string]int{},
+		breweries: map[string]int{},
+		venues:    map[string]int{},
+		countries: map[string]int{},
+		styles:    map[string]int{},
+		years:     map[string]int{},
+	}
+}
+
+func (c *statsCounter) add(img Image) {
+	md := img.Metadata
+	c.checkins++
+
+	count := func(m map[string]int, name string) {
+		if name = strings.TrimSpace(name); name != "" {
+			m[name]++
+		}
+	}
+	if md.Beer != "" {
+		count(c.beers, md.Brewery+"\x00"+md.Beer)
+	}
+	count(c.breweries, md.Brewery)
+	count(c.venues, md.Venue)
+	count(c.countries, md.Country)
+	count(c.styles, md.Style)
+
+	if r, err := strconv.ParseFloat(md.Rating, 64); err == nil && r > 0 {
+		c.ratingSum += r
+		c.rated++
+	}
+	if at, err := parseCheckinDate(md); err == nil {
+		count(c.years, strconv.Itoa(at.Year()))
+		if c.first == "" || md.Date < c.first {
+			c.first = md.Date
+		}
+		if md.Date > c.last {
+			c.last = md.Date
+		}
+	}
+}
+
+// topCounts returns the n largest counts, by decreasing count then name.
+// A negative n keeps every count.
+func topCounts(m map[string]int, n int) []StatsCount {
+	counts := make([]StatsCount, 0, len(m))
+	for name, count := range m {
+		counts = append(counts, StatsCount{Name: name, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	if n >= 0 && len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+func (c *statsCounter) stats() Stats {
+	s := Stats{
+		Checkins:     c.checkins,
+		Beers:        len(c.beers),
+		Breweries:    len(c.breweries),
+		Venues:       len(c.venues),
+		Countries:    len(c.countries),
+		First:        c.first,
+		Last:         c.last,
+		PerYear:      topCounts(c.years, -1),
+		TopBreweries: topCounts(c.breweries, statsTopN),
+		TopStyles:    topCounts(c.styles, statsTopN),
+		TopCountries: topCounts(c.countries, statsTopN),
+	}
+	sort.Slice(s.PerYear, func(i, j int) bool { return s.PerYear[i].Name < s.PerYear[j].Name })
+	if c.rated > 0 {
+		s.AverageRating = c.ratingSum / float64(c.rated)
+	}
+	return s
+}
+
+// ComputeStats summarises the visible check-ins of the journal.
+func ComputeStats(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) (Stats, error) {
+	c := newStatsCounter()
+	err := forEachImagePage(ctx, client, cfg, func(page []Image) error {
+		for _, img := range page {
+			c.add(img)
+		}
+		return ctx.Err()
+	})
+	return c.stats(), err
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatsCounter(t *testing.T) {
+	c := newStatsCounter()
+	for _, md := range []CheckinMetadata{
+		{Beer: "Gueuze", Brewery: "Cantillon", Country: "Belgium", Style: "Lambic", Rating: "4.5", Date: "2024-06-01 18:00:00"},
+		{Beer: "Gueuze", Brewery: "Cantillon", Country: "Belgium", Style: "Lambic", Rating: "4", Date: "2025-11-08 18:30:00"},
+		{Beer: "Kriek", Brewery: "Cantillon", Venue: "Moeder Lambic", Country: "Belgium", Style: "Lambic", Date: "2025-11-08 19:30:00"},
+		{Beer: "Saison", Brewery: "Dupont", Country: "France", Style: "Saison", Rating: "3.5", Date: "not a date"},
+	} {
+		c.add(Image{Metadata: md})
+	}
+
+	got := c.stats()
+	want := Stats{
+		Checkins:      4,
+		Beers:         3,
+		Breweries:     2,
+		Venues:        1,
+		Countries:     2,
+		AverageRating: 4,
+		First:         "2024-06-01 18:00:00",
+		Last:          "2025-11-08 19:30:00",
+		PerYear:       []StatsCount{{"2024", 1}, {"2025", 2}},
+		TopBreweries:  []StatsCount{{"Cantillon", 3}, {"Dupont", 1}},
+		TopStyles:     []StatsCount{{"Lambic", 3}, {"Saison", 1}},
+		TopCountries:  []StatsCount{{"Belgium", 3}, {"France", 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTopCounts(t *testing.T) {
+	got := topCounts(map[string]int{"a": 1, "b": 3, "c": 3, "d": 2}, 3)
+	want := []StatsCount{{"b", 3}, {"c", 3}, {"d", 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("topCounts() = %v, want %v", got, want)
+	}
+}
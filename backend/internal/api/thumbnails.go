@@ -0,0 +1,165 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/diskcache"
+	"beers/backend/internal/s3client"
+	"beers/backend/internal/thumbnail"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// renditions are derived from immutable photos, so they never change
+	thumbnailCacheControl = "public, max-age=31536000, immutable"
+	// limit how many photos are decoded at once
+	thumbnailWorkers = 2
+)
+
+// widths advertised in the srcset of each image
+var thumbnailWidths = []int{320, 640, 1280}
+
+// isThumbnailSize reports whether the options are those of a rendition of
+// the srcset, the only ones rendered so the cache cannot be flooded.
+func isThumbnailSize(o thumbnail.Options) bool {
+	return o.Height == 0 && slices.Contains(thumbnailWidths, o.Width)
+}
+
+func thumbnailURL(key string, width int) string {
+	u, err := url.JoinPath("/img", key)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s?w=%d", u, width)
+}
+
+// thumbnailSrcset returns a srcset attribute value listing the thumbnail
+// renditions of the image.
+func thumbnailSrcset(key string) string {
+	entries := make([]string, 0, len(thumbnailWidths))
+	for _, w := range thumbnailWidths {
+		entries = append(entries, fmt.Sprintf("%s %dw", thumbnailURL(key, w), w))
+	}
+	return strings.Join(entries, ", ")
+}
+
+func serveCachedFile(w http.ResponseWriter, r *http.Request, path, contentType string) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("open cached file %s: %v", path, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		log.Printf("stat cached file %s: %v", path, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// GetThumbnail serves a resized rendition of a photo, rendering it on the
+// first request and caching it on disk. Photos of hidden check-ins are not
+// found.
+func GetThumbnail(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	cache *diskcache.Cache,
+) http.HandlerFunc {
+	sem := make(chan struct{}, thumbnailWorkers)
+	renditions := newRenditionCache()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		if !isDecodableKey(keyLayout(cfg), key) {
+			writeJSONError(w, http.StatusNotFound, "Image not found")
+			return
+		}
+
+		opts, err := thumbnail.ParseOptions(r.URL.Query())
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !isThumbnailSize(opts) {
+			writeJSONError(w, http.StatusBadRequest, "Unsupported image size")
+			return
+		}
+
+		// thumbnails of hidden check-ins are not served
+		group, found, err := renditions.lookup(ctx, client, cfg, key)
+		if err != nil {
+			log.Printf("thumbnail %s error: %v", key, err)
+			writeJSONError(w, http.StatusBadGateway, "Error fetching image")
+			return
+		}
+		if !found || !hasRendition(group, key) {
+			writeJSONError(w, http.StatusNotFound, "Image not found")
+			return
+		}
+
+		name := key + "?" + opts.String()
+		path, ok := cache.Get(name)
+		if !ok {
+			sem <- struct{}{}
+			path, err = renderThumbnail(ctx, client, cfg, cache, key, name, opts)
+			<-sem
+
+			var noSuchKey *types.NoSuchKey
+			if errors.As(err, &noSuchKey) {
+				writeJSONError(w, http.StatusNotFound, "Image not found")
+				return
+			}
+			if err != nil {
+				log.Printf("thumbnail %s error: %v", name, err)
+				writeJSONError(w, http.StatusInternalServerError, "Error rendering image")
+				return
+			}
+		}
+
+		w.Header().Set("Cache-Control", thumbnailCacheControl)
+		serveCachedFile(w, r, path, thumbnail.ContentType)
+	}
+}
+
+func renderThumbnail(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	cache *diskcache.Cache,
+	key, name string,
+	opts thumbnail.Options,
+) (string, error) {
+	// another request may have rendered it while we waited
+	if path, ok := cache.Get(name); ok {
+		return path, nil
+	}
+
+	out, err := s3client.GetObject(ctx, client, cfg.BucketName, key)
+	if err != nil {
+		return "", err
+	}
+	defer out.Body.Close()
+
+	var buf bytes.Buffer
+	if err := thumbnail.Render(&buf, out.Body, opts); err != nil {
+		return "", err
+	}
+	return cache.Put(name, &buf)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/diskcache"
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestThumbnailSrcset(t *testing.T) {
+	got := thumbnailSrcset("2025/11/08/WEBP/image1.webp")
+	want := "/img/2025/11/08/WEBP/image1.webp?w=320 320w, " +
+		"/img/2025/11/08/WEBP/image1.webp?w=640 640w, " +
+		"/img/2025/11/08/WEBP/image1.webp?w=1280 1280w"
+	if got != want {
+		t.Errorf("thumbnailSrcset() = %q, want %q", got, want)
+	}
+}
+
+func TestGetThumbnail(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	cache, err := diskcache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 800, 400))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := 0
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("2025/11/08/WEBP/image1.webp")},
+					{Key: aws.String("2025/11/08/WEBP/secret.webp")},
+				},
+			}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			if aws.ToString(params.Key) == "2025/11/08/WEBP/secret.webp" {
+				return &s3.HeadObjectOutput{Metadata: map[string]string{"hidden": "true"}}, nil
+			}
+			return &s3.HeadObjectOutput{}, nil
+		},
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			calls++
+			if *params.Key != "2025/11/08/WEBP/image1.webp" {
+				return nil, &types.NoSuchKey{}
+			}
+			return &s3.GetObjectOutput{
+				Body: io.NopCloser(bytes.NewReader(photo.Bytes())),
+			}, nil
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /img/{key...}", GetThumbnail(context.Background(), mockClient, cfg, cache))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/img/2025/11/08/WEBP/image1.webp?w=320", nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("status = %v, want %v", status, http.StatusOK)
+		}
+		if got := rr.Header().Get("Cache-Control"); got != thumbnailCacheControl {
+			t.Errorf("unexpected Cache-Control: %q", got)
+		}
+		img, err := jpeg.Decode(rr.Body)
+		if err != nil {
+			t.Fatalf("response is not a JPEG: %v", err)
+		}
+		if got := img.Bounds().Size(); got != image.Pt(320, 160) {
+			t.Errorf("thumbnail size = %v, want 320x160", got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the photo to be fetched once, got %d calls", calls)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/img/2025/11/08/WEBP/missing.webp?w=320", http.StatusNotFound},
+		{"/img/2025/11/08/WEBP/secret.webp?w=320", http.StatusNotFound},
+		{"/img/2025/11/08/JPEG/image1.jpg?w=320", http.StatusNotFound},
+		{"/img/2025/11/08/WEBP/image1.webp?w=99999", http.StatusBadRequest},
+		{"/img/2025/11/08/WEBP/image1.webp?w=321", http.StatusBadRequest},
+		// only the width is read
+		{"/img/2025/11/08/WEBP/image1.webp?w=320&h=320&fit=cover", http.StatusOK},
+		{"/img/2025/11/08/WEBP/image1.webp", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != tt.status {
+			t.Errorf("GET %s status = %v, want %v", tt.path, rr.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/geo"
+	"beers/backend/internal/s3client"
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// check-ins closer than this to home are never part of a trip
+	tripMinDistanceKm = 100
+	// a longer silence between two far away check-ins starts a new trip
+	tripMaxGap = 72 * time.Hour
+
+	tripDateLayout = "2006-01-02"
+)
+
+type Trip struct {
+	ID        string   `json:"id"`
+	Start     string   `json:"start"`
+	End       string   `json:"end"`
+	Cities    []string `json:"cities"`
+	Countries []string `json:"countries"`
+	Checkins  []Image  `json:"checkins"`
+}
+
+type TripResponse struct {
+	Trips []Trip `json:"trips"`
+}
+
+// appendUnique appends s to list unless it is empty or already present.
+func appendUnique(list []string, s string) []string {
+	if s == "" {
+		return list
+	}
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// detectTrips groups check-ins into trips: runs of check-ins far away from
+// home where no two consecutive ones are more than maxGap apart. A check-in
+// near home ends the current trip. Check-ins without a usable date or
+// location are ignored. Trips are returned newest first.
+func detectTrips(images []Image, home geo.LatLng, minDistanceKm float64, maxGap time.Duration) []Trip {
+	type point struct {
+		img Image
+		at  time.Time
+		far bool
+	}
+
+	points := make([]point, 0, len(images))
+	for _, img := range images {
+		at, err := parseCheckinDate(img.Metadata)
+		if err != nil {
+			continue
+		}
+		loc, err := geo.ParseLatLng(img.Metadata.LatLng)
+		if err != nil {
+			continue
+		}
+		points = append(points, point{
+			img: img,
+			at:  at,
+			far: geo.DistanceKm(home, loc) >= minDistanceKm,
+		})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].at.Before(points[j].at) })
+
+	var (
+		trips []Trip
+		cur   *Trip
+		last  time.Time
+	)
+	closeTrip := func() {
+		if cur != nil {
+			cur.End = last.Format(tripDateLayout)
+			trips = append(trips, *cur)
+			cur = nil
+		}
+	}
+
+	for _, p := range points {
+		if !p.far {
+			closeTrip()
+			continue
+		}
+		if cur != nil && p.at.Sub(last) > maxGap {
+			closeTrip()
+		}
+		if cur == nil {
+			id := p.img.Metadata.ID
+			if id == "" {
+				id = p.img.Key
+			}
+			cur = &Trip{
+				ID:        id,
+				Start:     p.at.Format(tripDateLayout),
+				Cities:    []string{},
+				Countries: []string{},
+			}
+		}
+		cur.Cities = appendUnique(cur.Cities, p.img.Metadata.City)
+		cur.Countries = appendUnique(cur.Countries, p.img.Metadata.Country)
+		cur.Checkins = append(cur.Checkins, p.img)
+		last = p.at
+	}
+	closeTrip()
+
+	// newest first
+	for i, j := 0, len(trips)-1; i < j; i, j = i+1, j-1 {
+		trips[i], trips[j] = trips[j], trips[i]
+	}
+	return trips
+}
+
+// TripCache remembers the metadata of every check-in along the version of
+// its objects, so detecting trips again only reads the check-ins which
+// changed since. The bucket is still listed each time.
+type TripCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedCheckin
+}
+
+type cachedCheckin struct {
+	// ETags and modification times of the primary rendition and sidecar
+	version string
+	read    checkinRead
+}
+
+func NewTripCache() *TripCache {
+	return &TripCache{entries: map[string]cachedCheckin{}}
+}
+
+// objectVersion identifies the content and metadata of a listed object.
+// Replacing the metadata changes its modification time.
+func objectVersion(obj types.Object) string {
+	return aws.ToString(obj.ETag) + "@" + aws.ToTime(obj.LastModified).Format(time.RFC3339Nano)
+}
+
+// images returns every visible check-in of the bucket, in key order.
+func (c *TripCache) images(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) ([]Image, error) {
+	var images []Image
+	seen := map[string]bool{}
+	err := forEachListPage(ctx, client, cfg, func(contents []types.Object) error {
+		versions := make(map[string]string, len(contents))
+		for _, obj := range contents {
+			versions[aws.ToString(obj.Key)] = objectVersion(obj)
+		}
+		groups := groupRenditions(keyLayout(cfg), contents)
+		reads := make([]checkinRead, len(groups))
+		groupVersions := make([]string, len(groups))
+		var stale []int
+
+		c.mu.Lock()
+		for i, g := range groups {
+			key := *g.primary.Key
+			seen[key] = true
+			groupVersions[i] = versions[key]
+			if g.sidecar != "" {
+				groupVersions[i] += " " + versions[g.sidecar]
+			}
+			if e, ok := c.entries[key]; ok && e.version == groupVersions[i] {
+				reads[i] = e.read
+			} else {
+				stale = append(stale, i)
+			}
+		}
+		c.mu.Unlock()
+
+		staleGroups := make([]renditionGroup, len(stale))
+		for j, i := range stale {
+			staleGroups[j] = groups[i]
+		}
+		fresh := readCheckins(ctx, client, cfg, staleGroups, 4)
+		c.mu.Lock()
+		for j, i := range stale {
+			reads[i] = fresh[j]
+			if fresh[j].err == nil {
+				c.entries[*groups[i].primary.Key] = cachedCheckin{version: groupVersions[i], read: fresh[j]}
+			}
+		}
+		c.mu.Unlock()
+
+		images = append(images, visibleImages(buildImages(ctx, client, cfg, groups, reads))...)
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// forget the check-ins gone since
+	c.mu.Lock()
+	maps.DeleteFunc(c.entries, func(key string, _ cachedCheckin) bool { return !seen[key] })
+	c.mu.Unlock()
+	return images, nil
+}
+
+// Trips detects the trips around the configured home location.
+func (c *TripCache) Trips(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) ([]Trip, error) {
+	home, err := geo.ParseLatLng(cfg.HomeLatLng)
+	if err != nil {
+		return nil, err
+	}
+	images, err := c.images(ctx, client, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return detectTrips(images, home, tripMinDistanceKm, tripMaxGap), nil
+}
+
+func GetTrips(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	cache *TripCache,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		if _, err := geo.ParseLatLng(cfg.HomeLatLng); err != nil {
+			writeJSONError(w, http.StatusNotFound, "Home location is not configured")
+			return
+		}
+
+		trips, err := cache.Trips(ctx, client, cfg)
+		if err != nil {
+			log.Printf("load trips error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Error listing objects")
+			return
+		}
+		if trips == nil {
+			trips = []Trip{}
+		}
+
+		if err := json.NewEncoder(w).Encode(TripResponse{Trips: trips}); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/geo"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func tripImage(id, date, latlng, city string) Image {
+	return Image{
+		Key: id,
+		Metadata: CheckinMetadata{
+			ID:     id,
+			Date:   date,
+			LatLng: latlng,
+			City:   city,
+		},
+	}
+}
+
+func TestDetectTrips(t *testing.T) {
+	home := geo.LatLng{Lat: 51.5072, Lng: -0.1276} // London
+	images := []Image{
+		tripImage("1", "2025-05-01 20:00:00", "51.51,-0.12", "London"),
+		tripImage("2", "2025-05-02 18:00:00", "48.8566,2.3522", "Paris"),
+		tripImage("3", "2025-05-03 21:00:00", "50.8503,4.3517", "Brussels"),
+		tripImage("4", "2025-05-04 12:00:00", "", "Brussels"),
+		tripImage("5", "2025-05-05 19:00:00", "51.50,-0.13", "London"),
+		tripImage("6", "2025-06-10 19:00:00", "52.3676,4.9041", "Amsterdam"),
+		// too long after the previous check-in, starts another trip
+		tripImage("7", "2025-06-20 19:00:00", "52.3676,4.9041", "Amsterdam"),
+	}
+
+	trips := detectTrips(images, home, tripMinDistanceKm, tripMaxGap)
+
+	if got := len(trips); got != 3 {
+		t.Fatalf("expected 3 trips, got %d", got)
+	}
+
+	first := trips[2]
+	if first.ID != "2" {
+		t.Errorf("expected trip ID 2, got %q", first.ID)
+	}
+	if first.Start != "2025-05-02" || first.End != "2025-05-03" {
+		t.Errorf("unexpected trip dates: %s - %s", first.Start, first.End)
+	}
+	if got := len(first.Checkins); got != 2 {
+		t.Errorf("expected 2 check-ins, got %d", got)
+	}
+	if len(first.Cities) != 2 || first.Cities[0] != "Paris" || first.Cities[1] != "Brussels" {
+		t.Errorf("unexpected cities: %v", first.Cities)
+	}
+
+	if trips[0].ID != "7" || trips[1].ID != "6" {
+		t.Errorf("expected newest trips first, got %q and %q", trips[0].ID, trips[1].ID)
+	}
+}
+
+func TestDetectTripsMaxGap(t *testing.T) {
+	home := geo.LatLng{Lat: 51.5072, Lng: -0.1276}
+	images := []Image{
+		tripImage("1", "2025-05-01 20:00:00", "48.8566,2.3522", "Paris"),
+		tripImage("2", "2025-05-02 20:00:00", "48.8566,2.3522", "Paris"),
+	}
+
+	if got := len(detectTrips(images, home, tripMinDistanceKm, time.Hour)); got != 2 {
+		t.Errorf("expected 2 trips with a short gap, got %d", got)
+	}
+	if got := len(detectTrips(images, home, tripMinDistanceKm, tripMaxGap)); got != 1 {
+		t.Errorf("expected 1 trip with the default gap, got %d", got)
+	}
+}
+
+func TestTripCache(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", ImageProxy: true, HomeLatLng: "51.5072,-0.1276"}
+	modified := time.Date(2025, 11, 8, 20, 0, 0, 0, time.UTC)
+	objects := []types.Object{
+		{Key: aws.String("2025/11/08/WEBP/1.webp"), ETag: aws.String(`"a"`), LastModified: aws.Time(modified)},
+		{Key: aws.String("2025/11/09/WEBP/2.webp"), ETag: aws.String(`"b"`), LastModified: aws.Time(modified)},
+	}
+	metadata := map[string]map[string]string{
+		"2025/11/08/WEBP/1.webp": {"id": "1", "date": "2025-11-08 18:00:00", "latlng": "48.8566,2.3522"},
+		"2025/11/09/WEBP/2.webp": {"id": "2", "date": "2025-11-09 18:00:00", "latlng": "48.8566,2.3522"},
+	}
+
+	var heads []string
+	client := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{Contents: objects}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			heads = append(heads, aws.ToString(params.Key))
+			return &s3.HeadObjectOutput{Metadata: metadata[aws.ToString(params.Key)]}, nil
+		},
+	}
+	cache := NewTripCache()
+
+	tests := []struct {
+		name   string
+		change func()
+		heads  int
+		trips  int
+	}{
+		{"first", func() {}, 2, 1},
+		{"unchanged", func() {}, 0, 1},
+		{"edited", func() {
+			// hiding a check-in rewrites its metadata
+			metadata["2025/11/09/WEBP/2.webp"]["hidden"] = "true"
+			objects[1].LastModified = aws.Time(modified.Add(time.Hour))
+		}, 1, 1},
+		{"deleted", func() { objects = objects[1:] }, 0, 0},
+	}
+	for _, tt := range tests {
+		tt.change()
+		heads = nil
+		trips, err := cache.Trips(context.Background(), client, cfg)
+		if err != nil {
+			t.Fatalf("%s: Trips() error = %v", tt.name, err)
+		}
+		if len(heads) != tt.heads {
+			t.Errorf("%s: read %v, want %d check-ins read", tt.name, heads, tt.heads)
+		}
+		if len(trips) != tt.trips {
+			t.Errorf("%s: got %d trips, want %d", tt.name, len(trips), tt.trips)
+		}
+	}
+	if len(cache.entries) != 1 {
+		t.Errorf("expected the deleted check-in to be forgotten, %d cached", len(cache.entries))
+	}
+}
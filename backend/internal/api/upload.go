@@ -0,0 +1,157 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/keylayout"
+	"beers/backend/internal/photo"
+	"beers/backend/internal/s3client"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxUploadSize = 32 << 20
+	// part of the upload kept in memory, the rest goes to temporary files
+	maxUploadMemory = 8 << 20
+)
+
+var checkinIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// newCheckinID returns an ID for check-ins which were not recorded on
+// Untappd.
+func newCheckinID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "manual-" + hex.EncodeToString(b)
+}
+
+// PostCheckin adds a check-in from a multipart form holding a JPEG or PNG
+// photo and the check-in fields. The photo is stored as WEBP without its
+// EXIF data, scaled down to fit the size limits of photo.ConvertToWebp.
+func PostCheckin(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, "Photo too large")
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, "Invalid multipart form")
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		fields := map[string]string{}
+		for name := range editableFields {
+			if v := strings.TrimSpace(r.FormValue(name)); v != "" {
+				fields[name] = v
+			}
+		}
+		if fields["date"] == "" {
+			writeJSONError(w, http.StatusBadRequest, "Missing date")
+			return
+		}
+		if err := validateChanges(fields); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		at, _ := time.Parse(checkinDateLayout, fields["date"])
+
+		id := r.FormValue("id")
+		if id == "" {
+			id = newCheckinID()
+		} else if !checkinIDRe.MatchString(id) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid id")
+			return
+		}
+
+		file, _, err := r.FormFile("photo")
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Missing photo")
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Error reading photo")
+			return
+		}
+
+		webp, bounds, err := photo.ConvertToWebp(data)
+		if errors.Is(err, photo.ErrUnsupportedFormat) {
+			writeJSONError(w, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		if errors.Is(err, photo.ErrTooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "Photo too large")
+			return
+		}
+		if err != nil {
+			log.Printf("upload convert error: %v", err)
+			writeJSONError(w, http.StatusBadRequest, "Invalid image")
+			return
+		}
+
+		key := keyLayout(cfg).Build(keylayout.Key{
+			Year:   at.Year(),
+			Month:  at.Month(),
+			Day:    at.Day(),
+			Format: photo.Format,
+			ID:     id,
+			Ext:    photo.Extension,
+		})
+		metadata := applyChanges(map[string]string{
+			"id":               id,
+			schemaVersionField: strconv.Itoa(SchemaVersion()),
+		}, fields)
+		if metadataSize(metadata) > maxMetadataSize {
+			writeJSONError(w, http.StatusBadRequest, "Metadata too large, use a sidecar")
+			return
+		}
+
+		err = s3client.CreateObject(ctx, client, cfg.BucketName, key, photo.ContentType, webp, metadata)
+		if isPreconditionFailed(err) {
+			writeJSONError(w, http.StatusConflict, "Check-in already exists")
+			return
+		}
+		if err != nil {
+			log.Printf("upload %s error: %v", key, err)
+			writeJSONError(w, http.StatusBadGateway, "Error storing photo")
+			return
+		}
+		log.Printf("uploaded %s (%d bytes)", key, len(webp))
+
+		img := Image{
+			Key:         key,
+			ContentType: photo.ContentType,
+			Srcset:      thumbnailSrcset(key),
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+			Metadata:    newCheckinMetadata(metadata, nil),
+		}
+		if img.URL, err = photoURL(ctx, client, cfg, key); err != nil {
+			log.Printf("failed to build URL of %s: %v", key, err)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(img); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+	}
+}
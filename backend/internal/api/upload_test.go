@@ -0,0 +1,173 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"golang.org/x/image/webp"
+)
+
+// newUploadRequest builds a multipart check-in upload.
+func newUploadRequest(t *testing.T, fields map[string]string, photo []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	if photo != nil {
+		fw, err := mw.CreateFormFile("photo", "photo.png")
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		fw.Write(photo)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/checkins", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPostCheckin(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://example.com"}
+
+	var put *s3.PutObjectInput
+	var stored []byte
+	mockClient := &MockS3Client{
+		PutObjectFunc: func(
+			ctx context.Context,
+			params *s3.PutObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.PutObjectOutput, error) {
+			put = params
+			stored, _ = io.ReadAll(params.Body)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+
+	req := newUploadRequest(t, map[string]string{
+		"id":      "1234",
+		"date":    "2025-11-08 18:30:00",
+		"beer":    "Gueuze 100% Lambic",
+		"brewery": "Cantillon",
+		"city":    "Bruxelles–Anderlecht",
+		"rating":  "4.5",
+	}, encodePNG(t, 40, 30))
+	rr := httptest.NewRecorder()
+	PostCheckin(context.Background(), mockClient, cfg).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %v, want %v: %s", rr.Code, http.StatusCreated, rr.Body)
+	}
+	if put == nil {
+		t.Fatal("expected the photo to be stored")
+	}
+	if got := aws.ToString(put.Key); got != "2025/11/08/WEBP/1234.webp" {
+		t.Errorf("Key = %q", got)
+	}
+	if got := aws.ToString(put.ContentType); got != "image/webp" {
+		t.Errorf("ContentType = %q, want image/webp", got)
+	}
+	if got := aws.ToString(put.IfNoneMatch); got != "*" {
+		t.Errorf("IfNoneMatch = %q, want existing check-ins kept", got)
+	}
+	if put.Metadata["id"] != "1234" || put.Metadata["brewery"] != "Cantillon" {
+		t.Errorf("unexpected metadata: %v", put.Metadata)
+	}
+	if !strings.HasPrefix(put.Metadata["city"], "=?utf-8?q?") {
+		t.Errorf("expected city to be RFC 2047 encoded, got %q", put.Metadata["city"])
+	}
+	if _, err := webp.DecodeConfig(bytes.NewReader(stored)); err != nil {
+		t.Errorf("stored photo is not a webp image: %v", err)
+	}
+
+	var img Image
+	if err := json.NewDecoder(rr.Body).Decode(&img); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if img.URL != "https://example.com/2025/11/08/WEBP/1234.webp" || img.Width != 40 || img.Height != 30 {
+		t.Errorf("unexpected response: %+v", img)
+	}
+	if img.Metadata.City != "Bruxelles–Anderlecht" {
+		t.Errorf("City = %q", img.Metadata.City)
+	}
+}
+
+func TestPostCheckinErrors(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket", PublicURL: "https://example.com"}
+	conflict := &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	photo := encodePNG(t, 4, 4)
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		photo  []byte
+		putErr error
+		status int
+	}{
+		{"missing date", map[string]string{"beer": "Saison"}, photo, nil, http.StatusBadRequest},
+		{"invalid date", map[string]string{"date": "yesterday"}, photo, nil, http.StatusBadRequest},
+		{"invalid rating", map[string]string{"date": "2025-11-08 18:30:00", "rating": "6"}, photo, nil, http.StatusBadRequest},
+		{"invalid id", map[string]string{"date": "2025-11-08 18:30:00", "id": "../1"}, photo, nil, http.StatusBadRequest},
+		{"missing photo", map[string]string{"date": "2025-11-08 18:30:00"}, nil, nil, http.StatusBadRequest},
+		{"unsupported photo", map[string]string{"date": "2025-11-08 18:30:00"}, []byte("GIF89a"), nil, http.StatusUnsupportedMediaType},
+		{"existing check-in", map[string]string{"date": "2025-11-08 18:30:00", "id": "1"}, photo, conflict, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := &MockS3Client{
+				PutObjectFunc: func(
+					ctx context.Context,
+					params *s3.PutObjectInput,
+					optFns ...func(*s3.Options),
+				) (*s3.PutObjectOutput, error) {
+					if tt.putErr != nil {
+						return nil, tt.putErr
+					}
+					return &s3.PutObjectOutput{}, nil
+				},
+			}
+
+			rr := httptest.NewRecorder()
+			PostCheckin(context.Background(), mockClient, cfg).ServeHTTP(rr, newUploadRequest(t, tt.fields, tt.photo))
+			if rr.Code != tt.status {
+				t.Errorf("status = %v, want %v: %s", rr.Code, tt.status, rr.Body)
+			}
+		})
+	}
+}
+
+func TestNewCheckinID(t *testing.T) {
+	id := newCheckinID()
+	if !checkinIDRe.MatchString(id) {
+		t.Errorf("newCheckinID() = %q, not a valid id", id)
+	}
+	if id == newCheckinID() {
+		t.Errorf("expected random ids")
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/keylayout"
+	"beers/backend/internal/s3client"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// kinds of problems found by VerifyBucket
+const (
+	IssueLayout          = "layout"
+	IssueMissingMetadata = "missing-metadata"
+	IssueInvalidDate     = "invalid-date"
+	IssueInvalidRating   = "invalid-rating"
+	IssueInvalidABV      = "invalid-abv"
+	IssueMonthMismatch   = "month-mismatch"
+	IssueDuplicateID     = "duplicate-id"
+	IssueEncoding        = "encoding"
+	IssueUnreadable      = "unreadable"
+)
+
+// fields every check-in photo is expected to carry
+var requiredFields = []string{"id", "beer", "brewery", "date"}
+
+type VerifyIssue struct {
+	Key    string `json:"key"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+type VerifyReport struct {
+	Objects  int           `json:"objects"`
+	Checkins int           `json:"checkins"`
+	Issues   []VerifyIssue `json:"issues"`
+}
+
+// checkMetadata returns the problems of the metadata of a check-in photo.
+// Text fields of its sidecar take precedence over the headers.
+func checkMetadata(k keylayout.Key, key string, metadata map[string]string, sidecar map[string]any) []VerifyIssue {
+	var issues []VerifyIssue
+	add := func(kind, format string, args ...any) {
+		issues = append(issues, VerifyIssue{Key: key, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	fields := maps.Clone(metadata)
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	for name, v := range sidecar {
+		if s, ok := v.(string); ok {
+			fields[name] = s
+		}
+	}
+
+	var missing []string
+	for _, name := range requiredFields {
+		if fields[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		add(IssueMissingMetadata, "no %s", strings.Join(missing, ", "))
+	}
+
+	if date := fields["date"]; date != "" {
+		at, err := time.Parse(checkinDateLayout, date)
+		switch {
+		case err != nil:
+			add(IssueInvalidDate, "date %q is not formatted as %s", date, checkinDateLayout)
+		case at.Year() != k.Year || at.Month() != k.Month:
+			add(IssueMonthMismatch, "dated %s but stored under %04d/%02d", date, k.Year, k.Month)
+		}
+	}
+	if rating := fields["rating"]; rating != "" {
+		if err := editableFields["rating"](rating); err != nil {
+			add(IssueInvalidRating, "rating %q %v", rating, err)
+		}
+	}
+	if abv := fields["abv"]; abv != "" {
+		if err := editableFields["abv"](abv); err != nil {
+			add(IssueInvalidABV, "abv %q %v", abv, err)
+		}
+	}
+
+	// only headers are RFC 2047 encoded
+	for _, name := range slices.Sorted(maps.Keys(metadata)) {
+		v := metadata[name]
+		if !strings.Contains(v, "=?") {
+			continue
+		}
+		if _, err := rfc2047Decoder.DecodeHeader(v); err != nil {
+			add(IssueEncoding, "%s %q cannot be decoded: %v", name, v, err)
+		}
+	}
+	return issues
+}
+
+// VerifyBucket scans the whole bucket and reports objects outside the key
+// layout and check-ins whose metadata is missing, invalid or inconsistent.
+// progress, when set, is called with the number of check-ins checked so far.
+func VerifyBucket(
+	ctx context.Context,
+	client s3client.S3Client,
+	cfg *config.AppConfig,
+	progress func(checked int),
+) (VerifyReport, error) {
+	layout := keyLayout(cfg)
+	report := VerifyReport{Issues: []VerifyIssue{}}
+	// key of the first check-in seen with each ID
+	ids := map[string]string{}
+
+	err := forEachListPage(ctx, client, cfg, func(contents []types.Object) error {
+		report.Objects += len(contents)
+		for _, obj := range contents {
+			// sidecars follow the layout too
+			if _, ok := layout.Match(aws.ToString(obj.Key)); !ok {
+				report.Issues = append(report.Issues, VerifyIssue{
+					Key:    *obj.Key,
+					Kind:   IssueLayout,
+					Detail: "does not match " + layout.String(),
+				})
+			}
+		}
+
+		groups := groupRenditions(layout, contents)
+		reads := readCheckins(ctx, client, cfg, groups, 8)
+		sidecars := make([]map[string]any, len(groups))
+		// extra photos of a check-in are listed along it, not check-ins of their own
+		extra := map[string]bool{}
+		for i, g := range groups {
+			if len(reads[i].sidecar) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(reads[i].sidecar, &sidecars[i]); err != nil {
+				report.Issues = append(report.Issues, VerifyIssue{Key: g.sidecar, Kind: IssueUnreadable, Detail: err.Error()})
+				continue
+			}
+			photos, _ := sidecars[i]["photos"].([]any)
+			for _, photo := range photos {
+				if photo, ok := photo.(string); ok && isExtraPhotoKey(layout, *g.primary.Key, photo) {
+					extra[photo] = true
+				}
+			}
+		}
+
+		checked := 0
+		for i, g := range groups {
+			if slices.ContainsFunc(g.variants, func(obj types.Object) bool { return extra[*obj.Key] }) {
+				continue
+			}
+			checked++
+
+			key := *g.primary.Key
+			if reads[i].err != nil {
+				report.Issues = append(report.Issues, VerifyIssue{Key: key, Kind: IssueUnreadable, Detail: reads[i].err.Error()})
+				continue
+			}
+			k, _ := layout.Match(key)
+			metadata := reads[i].head.Metadata
+			report.Issues = append(report.Issues, checkMetadata(k, key, metadata, sidecars[i])...)
+
+			id := metadata["id"]
+			if s, ok := sidecars[i]["id"].(string); ok {
+				id = s
+			}
+			if id == "" {
+				id = k.ID
+			}
+			if first, ok := ids[id]; ok {
+				report.Issues = append(report.Issues, VerifyIssue{
+					Key:    key,
+					Kind:   IssueDuplicateID,
+					Detail: fmt.Sprintf("id %s already used by %s", id, first),
+				})
+			} else {
+				ids[id] = key
+			}
+		}
+
+		report.Checkins += checked
+		if progress != nil {
+			progress(report.Checkins)
+		}
+		return ctx.Err()
+	})
+	return report, err
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"beers/backend/internal/config"
+	"beers/backend/internal/keylayout"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestCheckMetadata(t *testing.T) {
+	key := "2025/11/08/WEBP/1234.webp"
+	k, _ := keylayout.Default.Match(key)
+	valid := map[string]string{
+		"id":      "1234",
+		"beer":    "Gueuze",
+		"brewery": "Cantillon",
+		"date":    "2025-11-08 18:30:00",
+		"rating":  "4.5",
+		"abv":     "5",
+		"city":    "=?utf-8?q?Bruxelles=E2=80=93Anderlecht?=",
+	}
+	with := func(name, value string) map[string]string {
+		md := map[string]string{}
+		for k, v := range valid {
+			md[k] = v
+		}
+		if value == "" {
+			delete(md, name)
+		} else {
+			md[name] = value
+		}
+		return md
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		sidecar  map[string]any
+		kinds    []string
+	}{
+		{"valid", valid, nil, nil},
+		{"no metadata", map[string]string{}, nil, []string{IssueMissingMetadata}},
+		{"no brewery", with("brewery", ""), nil, []string{IssueMissingMetadata}},
+		{"invalid date", with("date", "08/11/2025"), nil, []string{IssueInvalidDate}},
+		{"other month", with("date", "2025-10-31 23:30:00"), nil, []string{IssueMonthMismatch}},
+		{"invalid rating", with("rating", "4,5"), nil, []string{IssueInvalidRating}},
+		{"invalid abv", with("abv", "150"), nil, []string{IssueInvalidABV}},
+		{"invalid encoding", with("venue", "=?unknown?q?Caf=C3=A9?="), nil, []string{IssueEncoding}},
+		{"brewery in sidecar", with("brewery", ""), map[string]any{"brewery": "Cantillon", "photos": []any{}}, nil},
+		{"invalid date in sidecar", valid, map[string]any{"date": "08/11/2025"}, []string{IssueInvalidDate}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := checkMetadata(k, key, tt.metadata, tt.sidecar)
+			if len(issues) != len(tt.kinds) {
+				t.Fatalf("checkMetadata() = %+v, want kinds %v", issues, tt.kinds)
+			}
+			for i, issue := range issues {
+				if issue.Kind != tt.kinds[i] || issue.Key != key {
+					t.Errorf("issue %d = %+v, want kind %s", i, issue, tt.kinds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyBucket(t *testing.T) {
+	cfg := &config.AppConfig{BucketName: "test-bucket"}
+	heads := map[string]map[string]string{
+		"2025/11/08/WEBP/1234.webp": {"id": "1234", "beer": "Gueuze", "brewery": "Cantillon", "date": "2025-11-08 18:30:00"},
+		// reuploaded on another day
+		"2025/11/09/WEBP/1234.webp": {"id": "1234", "beer": "Gueuze", "brewery": "Cantillon", "date": "2025-11-09 18:30:00"},
+		// the rest of its metadata is in the sidecar
+		"2025/11/11/WEBP/9.webp": {"id": "9"},
+	}
+
+	mockClient := &MockS3Client{
+		ListObjectsV2Func: func(
+			ctx context.Context,
+			params *s3.ListObjectsV2Input,
+			optFns ...func(*s3.Options),
+		) (*s3.ListObjectsV2Output, error) {
+			var contents []types.Object
+			for _, key := range []string{
+				"2025/11/08/JPEG/1234.jpg",
+				"2025/11/08/WEBP/1234.webp",
+				"2025/11/09/WEBP/1234.webp",
+				"2025/11/10/WEBP/5678.webp",
+				"2025/11/11/WEBP/9-glass.webp",
+				"2025/11/11/WEBP/9.json",
+				"2025/11/11/WEBP/9.webp",
+				"notes.txt",
+			} {
+				contents = append(contents, types.Object{Key: aws.String(key)})
+			}
+			return &s3.ListObjectsV2Output{Contents: contents}, nil
+		},
+		HeadObjectFunc: func(
+			ctx context.Context,
+			params *s3.HeadObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.HeadObjectOutput, error) {
+			md, ok := heads[aws.ToString(params.Key)]
+			if !ok {
+				return nil, errors.New("access denied")
+			}
+			return &s3.HeadObjectOutput{Metadata: md}, nil
+		},
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			sidecar := `{"beer": "Kriek", "brewery": "Cantillon", "date": "2025-11-11 19:00:00", "photos": ["2025/11/11/WEBP/9-glass.webp"]}`
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(sidecar))}, nil
+		},
+	}
+
+	var checked int
+	report, err := VerifyBucket(context.Background(), mockClient, cfg, func(n int) { checked = n })
+	if err != nil {
+		t.Fatalf("VerifyBucket() error = %v", err)
+	}
+	// the extra photo is not a check-in of its own
+	if report.Objects != 8 || report.Checkins != 4 || checked != 4 {
+		t.Errorf("counted %d objects and %d check-ins (progress %d), want 8 and 4", report.Objects, report.Checkins, checked)
+	}
+
+	want := map[string]string{
+		"notes.txt":                 IssueLayout,
+		"2025/11/09/WEBP/1234.webp": IssueDuplicateID,
+		"2025/11/10/WEBP/5678.webp": IssueUnreadable,
+	}
+	if len(report.Issues) != len(want) {
+		t.Fatalf("Issues = %+v, want %v", report.Issues, want)
+	}
+	for _, issue := range report.Issues {
+		if want[issue.Key] != issue.Kind {
+			t.Errorf("unexpected issue %+v", issue)
+		}
+	}
+}
@@ -1,8 +1,16 @@
 package config
 
 import (
+	"beers/backend/internal/geo"
+	"beers/backend/internal/keylayout"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type AppConfig struct {
@@ -11,8 +19,15 @@ type AppConfig struct {
 	AccessKeyID     string
 	SecretAccessKey string
 	PublicURL       string
+	PresignTTL      time.Duration
 	BucketRegion    string
 	Port            string
+	HomeLatLng      string
+	CacheDir        string
+	CacheMaxBytes   int64
+	ImageProxy      bool
+	KeyLayout       *keylayout.Layout
+	AdminToken      string
 }
 
 func Load() (*AppConfig, error) {
@@ -21,17 +36,25 @@ func Load() (*AppConfig, error) {
 		"R2_ACCOUNT_ID":        nil,
 		"R2_ACCESS_KEY_ID":     nil,
 		"R2_SECRET_ACCESS_KEY": nil,
-		"R2_PUBLIC_URL":        nil,
 	}
 
-	// populate map and check missing
-	for key := range envs {
+	// populate map and report every missing variable at once
+	var missing []string
+	for _, key := range slices.Sorted(maps.Keys(envs)) {
 		val := os.Getenv(key)
 		if val == "" {
-			return nil, fmt.Errorf("environment variable %s is not set", key)
+			missing = append(missing, key)
+			continue
 		}
 		envs[key] = &val
 	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		return nil, fmt.Errorf("environment variable %s is not set", missing[0])
+	default:
+		return nil, fmt.Errorf("environment variables %s are not set", strings.Join(missing, ", "))
+	}
 
 	bucketRegion := os.Getenv("BUCKET_REGION")
 	if bucketRegion == "" {
@@ -43,13 +66,134 @@ func Load() (*AppConfig, error) {
 		port = "8080"
 	}
 
+	// optional, photos are served with presigned URLs when unset
+	publicURL := os.Getenv("R2_PUBLIC_URL")
+
+	presignTTL := time.Hour
+	if s := os.Getenv("PRESIGN_TTL"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("environment variable PRESIGN_TTL is not a valid duration: %q", s)
+		}
+		presignTTL = d
+	}
+
+	cacheDir := os.Getenv("CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), "beers-cache")
+	}
+
+	cacheMaxMB := int64(1024)
+	if s := os.Getenv("CACHE_MAX_SIZE_MB"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("environment variable CACHE_MAX_SIZE_MB is not a valid size: %q", s)
+		}
+		cacheMaxMB = n
+	}
+
+	// serve photos through the backend instead of from the bucket
+	imageProxy := false
+	if s := os.Getenv("IMAGE_PROXY"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("environment variable IMAGE_PROXY is not a boolean: %q", s)
+		}
+		imageProxy = b
+	}
+
+	keyLayout := keylayout.Default
+	if s := os.Getenv("KEY_LAYOUT"); s != "" {
+		l, err := keylayout.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("environment variable KEY_LAYOUT is not a valid template: %w", err)
+		}
+		keyLayout = l
+	}
+
+	// optional, enables the endpoints editing the journal
+	adminToken := os.Getenv("ADMIN_TOKEN")
+
+	// optional, enables trip detection
+	homeLatLng := os.Getenv("HOME_LATLNG")
+	if homeLatLng != "" {
+		if _, err := geo.ParseLatLng(homeLatLng); err != nil {
+			return nil, fmt.Errorf("environment variable HOME_LATLNG is not a valid \"lat,lng\" pair: %q", homeLatLng)
+		}
+	}
+
 	return &AppConfig{
 		BucketName:      *envs["BUCKET_NAME"],
 		AccountID:       *envs["R2_ACCOUNT_ID"],
 		AccessKeyID:     *envs["R2_ACCESS_KEY_ID"],
 		SecretAccessKey: *envs["R2_SECRET_ACCESS_KEY"],
-		PublicURL:       *envs["R2_PUBLIC_URL"],
+		PublicURL:       publicURL,
+		PresignTTL:      presignTTL,
 		BucketRegion:    bucketRegion,
 		Port:            port,
+		HomeLatLng:      homeLatLng,
+		CacheDir:        cacheDir,
+		CacheMaxBytes:   cacheMaxMB << 20,
+		ImageProxy:      imageProxy,
+		KeyLayout:       keyLayout,
+		AdminToken:      adminToken,
 	}, nil
 }
+
+// Setting is one entry of the configuration summary.
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// redact hides a secret, only keeping its length.
+func redact(s string) string {
+	if s == "" {
+		return "(unset)"
+	}
+	return fmt.Sprintf("*** (%d characters)", len(s))
+}
+
+// redactID hides most of an identifier, keeping its first characters so
+// two deployments can still be told apart.
+func redactID(s string) string {
+	if len(s) < 12 {
+		return redact(s)
+	}
+	return fmt.Sprintf("%s*** (%d characters)", s[:4], len(s))
+}
+
+// Summary lists the settings in effect, with credentials redacted, keyed
+// by the environment variable setting them.
+func (c *AppConfig) Summary() []Setting {
+	orUnset := func(s string) string {
+		if s == "" {
+			return "(unset)"
+		}
+		return s
+	}
+	publicURL := c.PublicURL
+	if publicURL == "" {
+		publicURL = fmt.Sprintf("(unset, presigned URLs valid for %s)", c.PresignTTL)
+	}
+	adminToken := "(unset, admin API disabled)"
+	if c.AdminToken != "" {
+		adminToken = redact(c.AdminToken)
+	}
+
+	return []Setting{
+		{"BUCKET_NAME", c.BucketName},
+		{"BUCKET_REGION", c.BucketRegion},
+		{"R2_ACCOUNT_ID", c.AccountID},
+		{"R2_ACCESS_KEY_ID", redactID(c.AccessKeyID)},
+		{"R2_SECRET_ACCESS_KEY", redact(c.SecretAccessKey)},
+		{"R2_PUBLIC_URL", publicURL},
+		{"PORT", c.Port},
+		{"HOME_LATLNG", orUnset(c.HomeLatLng)},
+		{"CACHE_DIR", c.CacheDir},
+		{"CACHE_MAX_SIZE_MB", strconv.FormatInt(c.CacheMaxBytes>>20, 10)},
+		{"IMAGE_PROXY", strconv.FormatBool(c.ImageProxy)},
+		{"KEY_LAYOUT", c.KeyLayout.String()},
+		{"ADMIN_TOKEN", adminToken},
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -36,9 +38,157 @@ func TestLoad(t *testing.T) {
 		t.Errorf("expected BucketRegion to be 'auto', got %s", cfg.BucketRegion)
 	}
 
+	if cfg.CacheDir == "" {
+		t.Errorf("expected CacheDir to default to a temporary directory")
+	}
+
 	os.Unsetenv("BUCKET_NAME")
 	_, err = Load()
 	if err == nil {
 		t.Errorf("expected an error, but got nil")
 	}
 }
+
+func TestLoadOptional(t *testing.T) {
+	t.Setenv("BUCKET_NAME", "test-bucket")
+	t.Setenv("R2_ACCOUNT_ID", "test-account-id")
+	t.Setenv("R2_ACCESS_KEY_ID", "test-access-key-id")
+	t.Setenv("R2_SECRET_ACCESS_KEY", "test-secret-access-key")
+	t.Setenv("R2_PUBLIC_URL", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PublicURL != "" {
+		t.Errorf("expected PublicURL to be empty, got %s", cfg.PublicURL)
+	}
+	if cfg.PresignTTL != time.Hour {
+		t.Errorf("expected PresignTTL to default to 1h, got %s", cfg.PresignTTL)
+	}
+
+	t.Setenv("PRESIGN_TTL", "10m")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PresignTTL != 10*time.Minute {
+		t.Errorf("expected PresignTTL to be 10m, got %s", cfg.PresignTTL)
+	}
+
+	t.Setenv("PRESIGN_TTL", "soon")
+	if _, err := Load(); err == nil {
+		t.Errorf("expected an error for an invalid PRESIGN_TTL")
+	}
+	t.Setenv("PRESIGN_TTL", "")
+
+	t.Setenv("IMAGE_PROXY", "true")
+	t.Setenv("CACHE_MAX_SIZE_MB", "10")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ImageProxy {
+		t.Errorf("expected ImageProxy to be enabled")
+	}
+	if cfg.CacheMaxBytes != 10<<20 {
+		t.Errorf("expected CacheMaxBytes to be 10MB, got %d", cfg.CacheMaxBytes)
+	}
+
+	t.Setenv("IMAGE_PROXY", "maybe")
+	if _, err := Load(); err == nil {
+		t.Errorf("expected an error for an invalid IMAGE_PROXY")
+	}
+	t.Setenv("IMAGE_PROXY", "")
+
+	if cfg.KeyLayout.String() != "{yyyy}/{mm}/{dd}/{format}/{id}.{ext}" {
+		t.Errorf("expected the default key layout, got %s", cfg.KeyLayout)
+	}
+	t.Setenv("KEY_LAYOUT", "photos/{yyyy}-{mm}/{id}-{format}.{ext}")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.KeyLayout.String() != "photos/{yyyy}-{mm}/{id}-{format}.{ext}" {
+		t.Errorf("unexpected key layout: %s", cfg.KeyLayout)
+	}
+
+	if cfg.AdminToken != "" {
+		t.Errorf("expected AdminToken to be empty, got %q", cfg.AdminToken)
+	}
+	t.Setenv("ADMIN_TOKEN", "secret")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.AdminToken != "secret" {
+		t.Errorf("expected AdminToken to be set, got %q", cfg.AdminToken)
+	}
+
+	t.Setenv("KEY_LAYOUT", "{id}.{ext}")
+	if _, err := Load(); err == nil {
+		t.Errorf("expected an error for an invalid KEY_LAYOUT")
+	}
+	t.Setenv("KEY_LAYOUT", "")
+
+	t.Setenv("HOME_LATLNG", "51.5072,-0.1276")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.HomeLatLng != "51.5072,-0.1276" {
+		t.Errorf("unexpected HomeLatLng: %q", cfg.HomeLatLng)
+	}
+	t.Setenv("HOME_LATLNG", "home")
+	if _, err := Load(); err == nil {
+		t.Errorf("expected an error for an invalid HOME_LATLNG")
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	t.Setenv("BUCKET_NAME", "test-bucket")
+	t.Setenv("R2_ACCOUNT_ID", "")
+	t.Setenv("R2_ACCESS_KEY_ID", "test-access-key-id")
+	t.Setenv("R2_SECRET_ACCESS_KEY", "")
+
+	_, err := Load()
+	want := "environment variables R2_ACCOUNT_ID, R2_SECRET_ACCESS_KEY are not set"
+	if err == nil || err.Error() != want {
+		t.Errorf("Load() error = %v, want %q", err, want)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	t.Setenv("BUCKET_NAME", "test-bucket")
+	t.Setenv("R2_ACCOUNT_ID", "test-account-id")
+	t.Setenv("R2_ACCESS_KEY_ID", "0123456789abcdef")
+	t.Setenv("R2_SECRET_ACCESS_KEY", "very-secret-access-key")
+	t.Setenv("R2_PUBLIC_URL", "")
+	t.Setenv("ADMIN_TOKEN", "short")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := map[string]string{}
+	for _, s := range cfg.Summary() {
+		settings[s.Name] = s.Value
+	}
+	want := map[string]string{
+		"BUCKET_NAME":          "test-bucket",
+		"R2_ACCESS_KEY_ID":     "0123*** (16 characters)",
+		"R2_SECRET_ACCESS_KEY": "*** (22 characters)",
+		"ADMIN_TOKEN":          "*** (5 characters)",
+		"R2_PUBLIC_URL":        "(unset, presigned URLs valid for 1h0m0s)",
+	}
+	for name, value := range want {
+		if settings[name] != value {
+			t.Errorf("%s = %q, want %q", name, settings[name], value)
+		}
+	}
+	for _, s := range cfg.Summary() {
+		if strings.Contains(s.Value, "very-secret") {
+			t.Errorf("%s leaks the secret access key: %q", s.Name, s.Value)
+		}
+	}
+}
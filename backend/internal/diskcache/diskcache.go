@@ -0,0 +1,174 @@
+package diskcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const tmpPrefix = ".tmp-"
+
+// Cache stores files on disk under a hash of their name. When it grows over
+// its maximum size, the least recently used entries are evicted.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu   sync.Mutex
+	size int64
+	// last use of the entries read since the cache was opened, the others
+	// were last used when written. The modification times are left alone
+	// as they are served as Last-Modified.
+	used map[string]time.Time
+}
+
+// New opens the cache stored in dir, creating it if needed. A maxBytes of
+// zero disables eviction.
+func New(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+
+	c := &Cache{dir: dir, maxBytes: maxBytes, used: map[string]time.Time{}}
+	entries, err := c.entries()
+	if err != nil {
+		return nil, fmt.Errorf("scan cache dir: %w", err)
+	}
+	for _, e := range entries {
+		c.size += e.size
+	}
+	return c, nil
+}
+
+// Path returns where the entry for name is stored, whether it exists or not.
+func (c *Cache) Path(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	h := hex.EncodeToString(sum[:])
+	// shard entries so a directory never grows too large
+	return filepath.Join(c.dir, h[:2], h)
+}
+
+// Get returns the path of the entry for name, if it is cached, and marks it
+// as recently used.
+func (c *Cache) Get(name string) (string, bool) {
+	p := c.Path(name)
+	if _, err := os.Stat(p); err != nil {
+		return "", false
+	}
+	c.mu.Lock()
+	c.used[p] = time.Now()
+	c.mu.Unlock()
+	return p, true
+}
+
+// Put stores the content of r as the entry for name and returns its path.
+// The entry is written to a temporary file first, so readers never see a
+// partial entry.
+func (c *Cache) Put(name string, r io.Reader) (string, error) {
+	p := c.Path(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), tmpPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if info, err := os.Stat(p); err == nil {
+		c.size -= info.Size()
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", err
+	}
+	c.size += n
+	delete(c.used, p)
+
+	if c.maxBytes > 0 && c.size > c.maxBytes {
+		c.evict(p)
+	}
+	return p, nil
+}
+
+// Size returns the total size of the cached entries, in bytes.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+type entry struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+func (c *Cache) entries() ([]entry, error) {
+	var entries []entry
+	err := filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tmpPrefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{path: p, size: info.Size(), lastUsed: info.ModTime()})
+		return nil
+	})
+	return entries, err
+}
+
+// evict removes the least recently used entries until the cache is back
+// under 90% of its maximum size, leaving some room before the next
+// eviction. The entry just written is kept. c.mu must be held.
+func (c *Cache) evict(keep string) {
+	entries, err := c.entries()
+	if err != nil {
+		return
+	}
+	for i, e := range entries {
+		if used, ok := c.used[e.path]; ok {
+			entries[i].lastUsed = used
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].lastUsed.Before(entries[j].lastUsed) })
+
+	target := c.maxBytes / 10 * 9
+	for _, e := range entries {
+		if c.size <= target {
+			return
+		}
+		if e.path == keep {
+			continue
+		}
+		if err := os.Remove(e.path); err == nil {
+			c.size -= e.size
+			delete(c.used, e.path)
+		}
+	}
+}
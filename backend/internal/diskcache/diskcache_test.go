@@ -0,0 +1,95 @@
+package diskcache
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := c.Get("2025/11/08/WEBP/image1.webp?w=320"); ok {
+		t.Fatalf("expected a miss on an empty cache")
+	}
+
+	p, err := c.Put("2025/11/08/WEBP/image1.webp?w=320", strings.NewReader("thumbnail"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the modification time is served as Last-Modified, using an entry
+	// must not change it
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(p, old, old)
+
+	got, ok := c.Get("2025/11/08/WEBP/image1.webp?w=320")
+	if !ok || got != p {
+		t.Fatalf("Get() = %q, %v, want %q, true", got, ok, p)
+	}
+	if info, err := os.Stat(got); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("expected the modification time to be kept")
+	}
+	b, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(b) != "thumbnail" {
+		t.Errorf("unexpected content: %q", b)
+	}
+
+	if _, ok := c.Get("2025/11/08/WEBP/image1.webp?w=640"); ok {
+		t.Errorf("expected a miss for another name")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	put := func(name string) {
+		t.Helper()
+		if _, err := c.Put(name, strings.NewReader(strings.Repeat("x", 40))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	put("a")
+	put("b")
+	// "b" was written after "a", but "a" was used since
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(c.Path("a"), old.Add(-time.Minute), old.Add(-time.Minute))
+	os.Chtimes(c.Path("b"), old, old)
+	c.Get("a")
+
+	// over the maximum size
+	put("c")
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected the least recently used entry to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("expected a recently used entry to be kept")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Errorf("expected the new entry to be kept")
+	}
+	if got := c.Size(); got != 80 {
+		t.Errorf("Size() = %d, want 80", got)
+	}
+
+	reopened, err := New(dir, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := reopened.Size(); got != 80 {
+		t.Errorf("Size() after reopening = %d, want 80", got)
+	}
+}
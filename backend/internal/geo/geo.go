@@ -0,0 +1,47 @@
+// Package geo parses coordinates and measures distances between them.
+package geo
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusKm = 6371
+
+// LatLng is a point on Earth, in degrees.
+type LatLng struct {
+	Lat, Lng float64
+}
+
+// ParseLatLng parses a "lat,lng" pair as stored in the check-in metadata.
+func ParseLatLng(s string) (LatLng, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return LatLng{}, errors.New("invalid latlng format")
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return LatLng{}, err
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return LatLng{}, err
+	}
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return LatLng{}, errors.New("latlng out of range")
+	}
+	return LatLng{Lat: lat, Lng: lng}, nil
+}
+
+// DistanceKm returns the great-circle distance between two points using the
+// haversine formula.
+func DistanceKm(a, b LatLng) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(b.Lat - a.Lat)
+	dLng := rad(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(a.Lat))*math.Cos(rad(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
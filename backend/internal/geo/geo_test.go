@@ -0,0 +1,54 @@
+package geo
+
+import "testing"
+
+func TestParseLatLng(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  LatLng
+		expectErr bool
+	}{
+		{
+			name:     "valid",
+			input:    "51.5072,-0.1276",
+			expected: LatLng{Lat: 51.5072, Lng: -0.1276},
+		},
+		{
+			name:     "with spaces",
+			input:    " 48.8566, 2.3522 ",
+			expected: LatLng{Lat: 48.8566, Lng: 2.3522},
+		},
+		{
+			name:      "empty",
+			input:     "",
+			expectErr: true,
+		},
+		{
+			name:      "out of range",
+			input:     "91,0",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLatLng(tt.input)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseLatLng() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && got != tt.expected {
+				t.Errorf("ParseLatLng() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	london := LatLng{Lat: 51.5072, Lng: -0.1276}
+	paris := LatLng{Lat: 48.8566, Lng: 2.3522}
+
+	if got := DistanceKm(london, paris); got < 340 || got > 345 {
+		t.Errorf("DistanceKm(london, paris) = %.1f, want about 343", got)
+	}
+}
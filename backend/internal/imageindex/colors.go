@@ -0,0 +1,189 @@
+package imageindex
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const (
+	paletteSize = 5
+	// colors closer than this (in RGB space) are merged in the palette
+	paletteMinDistance = 48
+)
+
+// ColorFamilies lists the names a palette color can be classified as.
+var ColorFamilies = []string{
+	"red",
+	"orange",
+	"yellow",
+	"green",
+	"blue",
+	"purple",
+	"pink",
+	"brown",
+	"black",
+	"white",
+	"gray",
+}
+
+type rgb struct {
+	r, g, b float64
+}
+
+func (c rgb) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", int(c.r+0.5), int(c.g+0.5), int(c.b+0.5))
+}
+
+func (c rgb) distance(o rgb) float64 {
+	return math.Sqrt((c.r-o.r)*(c.r-o.r) + (c.g-o.g)*(c.g-o.g) + (c.b-o.b)*(c.b-o.b))
+}
+
+// dominantColors returns up to n hex colors covering the largest areas of
+// img, most dominant first. Pixels are grouped in coarse RGB buckets, and
+// each color is the average of its bucket.
+func dominantColors(img image.Image, n int) []string {
+	type bucket struct {
+		sum   rgb
+		count int
+	}
+	buckets := map[int]*bucket{}
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			r8, g8, b8 := r>>8, g>>8, bl>>8
+			// 3 bits per channel
+			id := int(r8>>5)<<6 | int(g8>>5)<<3 | int(b8>>5)
+			bk, ok := buckets[id]
+			if !ok {
+				bk = &bucket{}
+				buckets[id] = bk
+			}
+			bk.sum.r += float64(r8)
+			bk.sum.g += float64(g8)
+			bk.sum.b += float64(b8)
+			bk.count++
+		}
+	}
+
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		sorted = append(sorted, bk)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].count > sorted[j].count })
+
+	var palette []rgb
+	for _, bk := range sorted {
+		c := rgb{
+			r: bk.sum.r / float64(bk.count),
+			g: bk.sum.g / float64(bk.count),
+			b: bk.sum.b / float64(bk.count),
+		}
+		similar := false
+		for _, p := range palette {
+			if p.distance(c) < paletteMinDistance {
+				similar = true
+				break
+			}
+		}
+		if similar {
+			continue
+		}
+		palette = append(palette, c)
+		if len(palette) == n {
+			break
+		}
+	}
+
+	colors := make([]string, 0, len(palette))
+	for _, c := range palette {
+		colors = append(colors, c.hex())
+	}
+	return colors
+}
+
+func parseHexColor(s string) (rgb, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return rgb{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return rgb{}, fmt.Errorf("invalid color %q", s)
+	}
+	return rgb{r: float64(v >> 16), g: float64(v >> 8 & 0xff), b: float64(v & 0xff)}, nil
+}
+
+// ColorFamily classifies a "#rrggbb" color in one of ColorFamilies, using
+// its hue, saturation and lightness.
+func ColorFamily(hex string) string {
+	c, err := parseHexColor(hex)
+	if err != nil {
+		return ""
+	}
+
+	r, g, b := c.r/255, c.g/255, c.b/255
+	hi, lo := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (hi + lo) / 2
+
+	var s, h float64
+	if d := hi - lo; d > 0 {
+		s = d / (1 - math.Abs(2*l-1))
+		switch hi {
+		case r:
+			h = math.Mod((g-b)/d, 6)
+		case g:
+			h = (b-r)/d + 2
+		default:
+			h = (r-g)/d + 4
+		}
+		h *= 60
+		if h < 0 {
+			h += 360
+		}
+	}
+
+	switch {
+	case l < 0.15:
+		return "black"
+	case l > 0.85 && s < 0.5:
+		return "white"
+	case s < 0.15:
+		if l > 0.7 {
+			return "white"
+		}
+		return "gray"
+	case h >= 15 && h < 45 && l < 0.4:
+		return "brown"
+	case h < 15 || h >= 345:
+		return "red"
+	case h < 45:
+		return "orange"
+	case h < 70:
+		return "yellow"
+	case h < 170:
+		return "green"
+	case h < 255:
+		return "blue"
+	case h < 290:
+		return "purple"
+	default:
+		return "pink"
+	}
+}
+
+// IsColorFamily reports whether name is one of ColorFamilies.
+func IsColorFamily(name string) bool {
+	for _, f := range ColorFamilies {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package imageindex
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDominantColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			c := color.RGBA{R: 200, G: 120, B: 20, A: 255} // amber
+			if x < 3 {
+				c = color.RGBA{R: 250, G: 250, B: 250, A: 255} // white
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	got := dominantColors(img, paletteSize)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 colors, got %v", got)
+	}
+	if got[0] != "#c87814" || got[1] != "#fafafa" {
+		t.Errorf("dominantColors() = %v, want [#c87814 #fafafa]", got)
+	}
+}
+
+func TestColorFamily(t *testing.T) {
+	tests := []struct {
+		hex      string
+		expected string
+	}{
+		{"#c87814", "orange"},
+		{"#5a3210", "brown"},
+		{"#e8c020", "yellow"},
+		{"#101010", "black"},
+		{"#fafafa", "white"},
+		{"#808080", "gray"},
+		{"#c01010", "red"},
+		{"#2040c0", "blue"},
+		{"#20a040", "green"},
+		{"invalid", ""},
+	}
+	for _, tt := range tests {
+		if got := ColorFamily(tt.hex); got != tt.expected {
+			t.Errorf("ColorFamily(%q) = %q, want %q", tt.hex, got, tt.expected)
+		}
+	}
+}
+
+func TestEntryMatches(t *testing.T) {
+	e := Entry{Colors: []string{"#fafafa", "#c87814", "#101010"}}
+	if !e.Matches("orange") {
+		t.Errorf("expected the second color to match")
+	}
+	if e.Matches("black") {
+		t.Errorf("expected only the two most dominant colors to match")
+	}
+}
@@ -0,0 +1,115 @@
+package imageindex
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// dHash computes the 64-bit difference hash of img: it is shrunk to 9x8
+// grayscale pixels, and each bit tells whether a pixel is brighter than its
+// right neighbour. Resized or recompressed copies of a photo hash the same
+// or within a few bits.
+func dHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+func formatHash(h uint64) string { return fmt.Sprintf("%016x", h) }
+
+func parseHash(s string) (uint64, error) { return strconv.ParseUint(s, 16, 64) }
+
+// Distance returns the number of differing bits between two hashes.
+func Distance(a, b uint64) int { return bits.OnesCount64(a ^ b) }
+
+// DuplicateGroup is a set of photos whose hashes are all within the
+// threshold of another photo of the group.
+type DuplicateGroup struct {
+	Keys []string `json:"keys"`
+	// largest distance between two linked photos of the group
+	Distance int `json:"distance"`
+}
+
+// Duplicates groups the indexed photos whose hashes differ by at most
+// threshold bits. Groups and their keys are sorted.
+func (idx *Index) Duplicates(threshold int) []DuplicateGroup {
+	type hashed struct {
+		key  string
+		hash uint64
+	}
+
+	idx.mu.RLock()
+	photos := make([]hashed, 0, len(idx.entries))
+	for key, e := range idx.entries {
+		if e.Version < entryVersion {
+			continue
+		}
+		h, err := parseHash(e.DHash)
+		if err != nil {
+			continue
+		}
+		photos = append(photos, hashed{key: key, hash: h})
+	}
+	idx.mu.RUnlock()
+	sort.Slice(photos, func(i, j int) bool { return photos[i].key < photos[j].key })
+
+	// union-find over the photos, linking every close pair
+	parent := make([]int, len(photos))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	maxDistance := map[int]int{}
+	for i := range photos {
+		for j := i + 1; j < len(photos); j++ {
+			d := Distance(photos[i].hash, photos[j].hash)
+			if d > threshold {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			if ri != rj {
+				parent[rj] = ri
+				maxDistance[ri] = max(maxDistance[ri], maxDistance[rj])
+			}
+			maxDistance[ri] = max(maxDistance[ri], d)
+		}
+	}
+
+	members := map[int][]string{}
+	for i, p := range photos {
+		r := find(i)
+		members[r] = append(members[r], p.key)
+	}
+
+	var groups []DuplicateGroup
+	for r, keys := range members {
+		if len(keys) < 2 {
+			continue
+		}
+		groups = append(groups, DuplicateGroup{Keys: keys, Distance: maxDistance[r]})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Keys[0] < groups[j].Keys[0] })
+	return groups
+}
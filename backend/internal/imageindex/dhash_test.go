@@ -0,0 +1,67 @@
+package imageindex
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+func checkerImage(w, h, cell int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			c := color.RGBA{A: 255}
+			if (x/cell+y/cell)%2 == 0 {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	original := gradientImage(320, 240)
+	resized := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), original, original.Bounds(), draw.Src, nil)
+
+	if d := Distance(dHash(original), dHash(resized)); d > 4 {
+		t.Errorf("expected a resized copy to be close, distance = %d", d)
+	}
+	if d := Distance(dHash(original), dHash(checkerImage(320, 240, 40))); d < 10 {
+		t.Errorf("expected different photos to be far apart, distance = %d", d)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	idx, err := Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	idx.Put("2025/11/08/WEBP/a.webp", Entry{DHash: formatHash(0xff00ff00ff00ff00)})
+	idx.Put("2025/11/09/WEBP/b.webp", Entry{DHash: formatHash(0xff00ff00ff00ff01)})
+	idx.Put("2025/11/10/WEBP/c.webp", Entry{DHash: formatHash(0xff00ff00ff00ff03)})
+	idx.Put("2025/11/11/WEBP/d.webp", Entry{DHash: formatHash(0x00ff00ff00ff00ff)})
+	idx.Put("2025/11/12/WEBP/e.webp", Entry{})
+
+	got := idx.Duplicates(1)
+	want := []DuplicateGroup{{
+		Keys: []string{
+			"2025/11/08/WEBP/a.webp",
+			"2025/11/09/WEBP/b.webp",
+			"2025/11/10/WEBP/c.webp",
+		},
+		Distance: 1,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Duplicates() = %+v, want %+v", got, want)
+	}
+
+	if got := idx.Duplicates(0); len(got) != 0 {
+		t.Errorf("expected no exact duplicates, got %+v", got)
+	}
+}
@@ -0,0 +1,276 @@
+package imageindex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// bumped when Entry gains fields, so older entries get recomputed
+	entryVersion = 3
+
+	blurhashXComponents = 4
+	blurhashYComponents = 3
+	// photos are shrunk to this width before computing their blurhash
+	analyzeWidth = 32
+)
+
+// Entry holds what is derived from a photo's pixels, which is too slow to
+// compute while serving a request.
+type Entry struct {
+	Version  int      `json:"version"`
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	Blurhash string   `json:"blurhash"`
+	Colors   []string `json:"colors"`
+	DHash    string   `json:"dhash"`
+}
+
+// Matches reports whether one of the two most dominant colors of the photo
+// belongs to the given color family.
+func (e Entry) Matches(family string) bool {
+	for i, c := range e.Colors {
+		if i == 2 {
+			break
+		}
+		if ColorFamily(c) == family {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze computes the index entry of a decoded photo.
+func Analyze(img image.Image) Entry {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	sw := min(analyzeWidth, w)
+	sh := max(1, h*sw/max(1, w))
+	small := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, draw.Src, nil)
+
+	return Entry{
+		Width:    w,
+		Height:   h,
+		Blurhash: encodeBlurhash(small, blurhashXComponents, blurhashYComponents),
+		Colors:   dominantColors(small, paletteSize),
+		DHash:    formatHash(dHash(img)),
+	}
+}
+
+// Index is a persistent map of object keys to their entry, stored as a JSON
+// file. The server and the commands share the file: each save merges the
+// changes of this process over the file as it is on disk, so the entries
+// written by the others are kept.
+type Index struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[string]Entry
+	// keys put (true) or removed (false) since the last save
+	changed map[string]bool
+	// of the file when it was last read or written
+	modTime time.Time
+
+	// serializes the saves of this process
+	saveMu sync.Mutex
+}
+
+// readIndexFile loads the entries stored at path, none if it does not
+// exist yet.
+func readIndexFile(path string) (map[string]Entry, time.Time, error) {
+	entries := map[string]Entry{}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("read index: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("read index: %w", err)
+	}
+	if err := json.NewDecoder(f).Decode(&entries); err != nil {
+		return nil, time.Time{}, fmt.Errorf("decode index %s: %w", path, err)
+	}
+	return entries, info.ModTime(), nil
+}
+
+// Open loads the index stored at path, or starts an empty one if the file
+// does not exist yet.
+func Open(path string) (*Index, error) {
+	entries, modTime, err := readIndexFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{path: path, entries: entries, changed: map[string]bool{}, modTime: modTime}, nil
+}
+
+// mergeLocked replaces the entries with the ones read from disk, with the
+// unsaved changes of this process applied over them.
+func (idx *Index) mergeLocked(disk map[string]Entry) {
+	for key, put := range idx.changed {
+		if put {
+			disk[key] = idx.entries[key]
+		} else {
+			delete(disk, key)
+		}
+	}
+	idx.entries = disk
+}
+
+// Reload picks up the entries other processes saved since the file was last
+// read, keeping the unsaved changes of this one.
+func (idx *Index) Reload() error {
+	info, err := os.Stat(idx.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read index: %w", err)
+	}
+	idx.mu.RLock()
+	unchanged := info.ModTime().Equal(idx.modTime)
+	idx.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	disk, modTime, err := readIndexFile(idx.path)
+	if err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.mergeLocked(disk)
+	idx.modTime = modTime
+	return nil
+}
+
+// Get returns the entry of a photo, unless it is missing or was computed by
+// an older version.
+func (idx *Index) Get(key string) (Entry, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	e, ok := idx.entries[key]
+	if !ok || e.Version < entryVersion {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+func (idx *Index) Put(key string, e Entry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	e.Version = entryVersion
+	idx.entries[key] = e
+	idx.changed[key] = true
+}
+
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.entries)
+}
+
+// Prune removes the entries of the photos missing from keys, such as
+// deleted photos, and returns how many were removed.
+func (idx *Index) Prune(keys []string) int {
+	keep := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		keep[key] = true
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	removed := 0
+	for key := range idx.entries {
+		if !keep[key] {
+			delete(idx.entries, key)
+			idx.changed[key] = false
+			removed++
+		}
+	}
+	return removed
+}
+
+// Save merges the changes of this process into the file on disk, and
+// replaces it atomically.
+func (idx *Index) Save() error {
+	idx.saveMu.Lock()
+	defer idx.saveMu.Unlock()
+
+	disk, _, err := readIndexFile(idx.path)
+	if err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	idx.mergeLocked(disk)
+	b, err := json.Marshal(idx.entries)
+	saved := idx.changed
+	idx.changed = map[string]bool{}
+	idx.mu.Unlock()
+
+	var modTime time.Time
+	if err == nil {
+		modTime, err = writeIndexFile(idx.path, b)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if err != nil {
+		// the changes which did not make it to disk are saved next time
+		for key, put := range saved {
+			if _, ok := idx.changed[key]; !ok {
+				idx.changed[key] = put
+			}
+		}
+		return err
+	}
+	idx.modTime = modTime
+	return nil
+}
+
+// writeIndexFile replaces the file at path through a temporary file unique
+// to this save, so concurrent saves never write to the same one.
+func writeIndexFile(path string, b []byte) (time.Time, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return time.Time{}, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return time.Time{}, err
+	}
+	if err := f.Close(); err != nil {
+		return time.Time{}, err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return time.Time{}, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,187 @@
+package imageindex
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func gradientImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 10), uint8((x + y) * 4), 255})
+		}
+	}
+	return img
+}
+
+func TestEncodeBlurhash(t *testing.T) {
+	// reference value from the blurhash reference implementation
+	want := "LxH27b2kwzX5mAWYjuf7gKfkfQfj"
+	if got := encodeBlurhash(gradientImage(32, 24), 4, 3); got != want {
+		t.Errorf("encodeBlurhash() = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	e := Analyze(gradientImage(320, 240))
+	if e.Width != 320 || e.Height != 240 {
+		t.Errorf("unexpected size: %dx%d", e.Width, e.Height)
+	}
+	// size flag, max AC, 4 chars of DC and 2 chars per AC component
+	if got, want := len(e.Blurhash), 2+4+2*(blurhashXComponents*blurhashYComponents-1); got != want {
+		t.Errorf("blurhash length = %d, want %d", got, want)
+	}
+}
+
+func TestIndexSaveAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+
+	idx, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if idx.Len() != 0 {
+		t.Fatalf("expected a new index to be empty")
+	}
+
+	want := Entry{
+		Version:  entryVersion,
+		Width:    800,
+		Height:   600,
+		Blurhash: "LxH27b2kwzX5mAWYjuf7gKfkfQfj",
+		Colors:   []string{"#c08040"},
+	}
+	idx.Put("2025/11/08/WEBP/image1.webp", want)
+	if err := idx.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, ok := reopened.Get("2025/11/08/WEBP/image1.webp")
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %+v, %v, want %+v, true", got, ok, want)
+	}
+}
+
+func TestIndexGetOutdatedEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	// written before colors were indexed
+	old := `{"2025/11/08/WEBP/image1.webp":{"width":800,"height":600,"blurhash":"LxH27b2kwzX5mAWYjuf7gKfkfQfj"}}`
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	idx, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := idx.Get("2025/11/08/WEBP/image1.webp"); ok {
+		t.Errorf("expected an outdated entry to be reported as missing")
+	}
+}
+
+func TestIndexPrune(t *testing.T) {
+	idx, err := Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	idx.Put("2025/11/08/WEBP/image1.webp", Entry{Width: 800})
+	idx.Put("2025/11/08/WEBP/deleted.webp", Entry{Width: 800})
+
+	if removed := idx.Prune([]string{"2025/11/08/WEBP/image1.webp", "2025/11/09/WEBP/new.webp"}); removed != 1 {
+		t.Errorf("Prune() = %d, want 1", removed)
+	}
+	if _, ok := idx.Get("2025/11/08/WEBP/deleted.webp"); ok {
+		t.Errorf("expected the deleted photo to be pruned")
+	}
+	if _, ok := idx.Get("2025/11/08/WEBP/image1.webp"); !ok {
+		t.Errorf("expected the listed photo to be kept")
+	}
+}
+
+func TestIndexSaveMerges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	// the server and a command sharing the file
+	server, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.Put("2025/11/08/WEBP/served.webp", Entry{Width: 800})
+	server.Put("2025/11/08/WEBP/deleted.webp", Entry{Width: 800})
+	if err := server.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	command, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	command.Put("2025/11/09/WEBP/synced.webp", Entry{Width: 640})
+	command.Prune([]string{"2025/11/08/WEBP/served.webp", "2025/11/09/WEBP/synced.webp"})
+	if err := command.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the server saves with its stale copy
+	server.Put("2025/11/10/WEBP/new.webp", Entry{Width: 320})
+	if err := server.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{"2025/11/08/WEBP/served.webp", "2025/11/09/WEBP/synced.webp", "2025/11/10/WEBP/new.webp"} {
+		if _, ok := reopened.Get(key); !ok {
+			t.Errorf("expected %s to be saved", key)
+		}
+	}
+	if _, ok := reopened.Get("2025/11/08/WEBP/deleted.webp"); ok {
+		t.Errorf("expected the pruned entry to stay removed")
+	}
+	if _, ok := server.Get("2025/11/09/WEBP/synced.webp"); !ok {
+		t.Errorf("expected the server to see the entries of the command after saving")
+	}
+
+	tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestIndexReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	server, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.Put("2025/11/08/WEBP/unsaved.webp", Entry{Width: 800})
+
+	command, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	command.Put("2025/11/09/WEBP/synced.webp", Entry{Width: 640})
+	if err := command.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := server.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := server.Get("2025/11/09/WEBP/synced.webp"); !ok {
+		t.Errorf("expected the reload to pick up the command's entry")
+	}
+	if _, ok := server.Get("2025/11/08/WEBP/unsaved.webp"); !ok {
+		t.Errorf("expected the reload to keep the unsaved entry")
+	}
+}
@@ -0,0 +1,223 @@
+package imageindex
+
+import (
+	"beers/backend/internal/s3client"
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"sync"
+	"time"
+
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// size of the queue of photos waiting to be analyzed
+	queueSize = 256
+	// how often the index file is checked for entries saved by the commands
+	reloadInterval = time.Minute
+	// how long a photo which failed to be analyzed is left alone, doubled
+	// on each failure up to maxRetryDelay
+	retryDelay    = time.Minute
+	maxRetryDelay = 24 * time.Hour
+)
+
+// failure records when a photo failed to be analyzed, so broken photos are
+// not downloaded again on every request listing them.
+type failure struct {
+	count   int
+	retryAt time.Time
+}
+
+// Indexer fills an Index by downloading and analyzing photos from the
+// bucket, either on demand or in the background.
+type Indexer struct {
+	client s3client.S3Client
+	bucket string
+	index  *Index
+
+	queue    chan string
+	mu       sync.Mutex
+	pending  map[string]bool
+	failures map[string]failure
+}
+
+func NewIndexer(client s3client.S3Client, bucket string, index *Index) *Indexer {
+	return &Indexer{
+		client:   client,
+		bucket:   bucket,
+		index:    index,
+		queue:    make(chan string, queueSize),
+		pending:  map[string]bool{},
+		failures: map[string]failure{},
+	}
+}
+
+// Lookup returns the indexed entry of a photo.
+func (ix *Indexer) Lookup(key string) (Entry, bool) {
+	return ix.index.Get(key)
+}
+
+// Duplicates reports the groups of near-duplicate photos in the index,
+// including the photos other processes indexed since it was loaded.
+func (ix *Indexer) Duplicates(threshold int) []DuplicateGroup {
+	if err := ix.index.Reload(); err != nil {
+		log.Printf("reload index error: %v", err)
+	}
+	return ix.index.Duplicates(threshold)
+}
+
+// IndexKey analyzes a photo and stores its entry, without saving the index.
+func (ix *Indexer) IndexKey(ctx context.Context, key string) (Entry, error) {
+	out, err := s3client.GetObject(ctx, ix.client, ix.bucket, key)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer out.Body.Close()
+
+	img, _, err := image.Decode(out.Body)
+	if err != nil {
+		return Entry{}, fmt.Errorf("decode %s: %w", key, err)
+	}
+
+	e := Analyze(img)
+	ix.index.Put(key, e)
+	return e, nil
+}
+
+// IndexMissing analyzes the photos among keys which are not indexed yet,
+// with a few concurrent workers. The index is saved regularly, so an
+// interrupted run resumes where it stopped. Photos which fail are logged and
+// skipped; progress, if set, is called after each photo.
+func (ix *Indexer) IndexMissing(ctx context.Context, keys []string, progress func(done, total int)) error {
+	const workers = 4
+	const saveEvery = 100
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := ix.index.Get(key); !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	jobs := make(chan string)
+	wg := sync.WaitGroup{}
+	var mu sync.Mutex
+	done := 0
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				if _, err := ix.IndexKey(ctx, key); err != nil {
+					log.Printf("index %s error: %v", key, err)
+				}
+
+				mu.Lock()
+				done++
+				if done%saveEvery == 0 {
+					if err := ix.index.Save(); err != nil {
+						log.Printf("save index error: %v", err)
+					}
+				}
+				if progress != nil {
+					progress(done, len(missing))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, key := range missing {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ix.index.Save(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Prune drops the entries of the photos missing from keys and saves the
+// index. It returns how many entries were removed.
+func (ix *Indexer) Prune(keys []string) (int, error) {
+	removed := ix.index.Prune(keys)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, ix.index.Save()
+}
+
+// Enqueue schedules a photo for background indexing. It never blocks: when
+// the queue is full, the photo is picked up again on a later request.
+// Photos which recently failed to be analyzed are skipped.
+func (ix *Indexer) Enqueue(key string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.pending[key] || time.Now().Before(ix.failures[key].retryAt) {
+		return
+	}
+	select {
+	case ix.queue <- key:
+		ix.pending[key] = true
+	default:
+	}
+}
+
+// Run indexes the enqueued photos until ctx is done, saving the index each
+// time the queue is drained. The entries saved by other processes, like the
+// sync command, are picked up regularly.
+func (ix *Indexer) Run(ctx context.Context) {
+	reload := time.NewTicker(reloadInterval)
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload.C:
+			if err := ix.index.Reload(); err != nil {
+				log.Printf("reload index error: %v", err)
+			}
+		case key := <-ix.queue:
+			if drained := ix.indexQueued(ctx, key); drained {
+				if err := ix.index.Save(); err != nil {
+					log.Printf("save index error: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// indexQueued analyzes a photo taken from the queue, backing off when it
+// fails. It reports whether the queue is now empty.
+func (ix *Indexer) indexQueued(ctx context.Context, key string) bool {
+	_, err := ix.IndexKey(ctx, key)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	delete(ix.pending, key)
+	if err != nil {
+		f := ix.failures[key]
+		delay := min(retryDelay<<f.count, maxRetryDelay)
+		if delay < maxRetryDelay {
+			f.count++
+		}
+		f.retryAt = time.Now().Add(delay)
+		ix.failures[key] = f
+		log.Printf("index %s error, retrying in %s: %v", key, delay, err)
+	} else {
+		delete(ix.failures, key)
+	}
+	return len(ix.queue) == 0
+}
@@ -0,0 +1,207 @@
+package imageindex
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type MockS3Client struct {
+	GetObjectFunc func(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error)
+}
+
+func (m *MockS3Client) ListObjectsV2(
+	ctx context.Context,
+	params *s3.ListObjectsV2Input,
+	optFns ...func(*s3.Options),
+) (*s3.ListObjectsV2Output, error) {
+	panic("ListObjectsV2 not expected on MockS3Client")
+}
+
+func (m *MockS3Client) HeadObject(
+	ctx context.Context,
+	params *s3.HeadObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.HeadObjectOutput, error) {
+	panic("HeadObject not expected on MockS3Client")
+}
+
+func (m *MockS3Client) GetObject(
+	ctx context.Context,
+	params *s3.GetObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.GetObjectOutput, error) {
+	return m.GetObjectFunc(ctx, params, optFns...)
+}
+
+func (m *MockS3Client) CopyObject(
+	ctx context.Context,
+	params *s3.CopyObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.CopyObjectOutput, error) {
+	panic("CopyObject not expected on MockS3Client")
+}
+
+func (m *MockS3Client) PutObject(
+	ctx context.Context,
+	params *s3.PutObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.PutObjectOutput, error) {
+	panic("PutObject not expected on MockS3Client")
+}
+
+func TestIndexKey(t *testing.T) {
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, gradientImage(64, 48)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockClient := &MockS3Client{
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			if got, want := aws.ToString(params.Bucket), "test-bucket"; got != want {
+				t.Errorf("Bucket = %q, want %q", got, want)
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(photo.Bytes()))}, nil
+		},
+	}
+
+	idx, err := Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ix := NewIndexer(mockClient, "test-bucket", idx)
+
+	if _, ok := ix.Lookup("2025/11/08/WEBP/image1.webp"); ok {
+		t.Fatalf("expected no entry before indexing")
+	}
+	e, err := ix.IndexKey(context.Background(), "2025/11/08/WEBP/image1.webp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Width != 64 || e.Height != 48 || e.Blurhash == "" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if got, ok := ix.Lookup("2025/11/08/WEBP/image1.webp"); !ok || got.Blurhash != e.Blurhash {
+		t.Errorf("Lookup() = %+v, %v, want %+v, true", got, ok, e)
+	}
+}
+
+func TestIndexMissing(t *testing.T) {
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, gradientImage(64, 48)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var mu sync.Mutex
+	fetched := map[string]bool{}
+	mockClient := &MockS3Client{
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			mu.Lock()
+			fetched[aws.ToString(params.Key)] = true
+			mu.Unlock()
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(photo.Bytes()))}, nil
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "index.json")
+	idx, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	idx.Put("2025/11/08/WEBP/indexed.webp", Entry{DHash: "ff00ff00ff00ff00"})
+	ix := NewIndexer(mockClient, "test-bucket", idx)
+
+	keys := []string{
+		"2025/11/08/WEBP/indexed.webp",
+		"2025/11/09/WEBP/new1.webp",
+		"2025/11/10/WEBP/new2.webp",
+	}
+	calls := 0
+	err = ix.IndexMissing(context.Background(), keys, func(done, total int) {
+		calls++
+		if total != 2 {
+			t.Errorf("total = %d, want 2", total)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 2 || len(fetched) != 2 || fetched["2025/11/08/WEBP/indexed.webp"] {
+		t.Errorf("expected only the 2 missing photos to be indexed, fetched %v", fetched)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reopened.Len() != 3 {
+		t.Errorf("expected the saved index to hold 3 entries, got %d", reopened.Len())
+	}
+}
+
+func TestIndexerBackoff(t *testing.T) {
+	calls := 0
+	mockClient := &MockS3Client{
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			calls++
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("not an image"))}, nil
+		},
+	}
+	idx, err := Open(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ix := NewIndexer(mockClient, "test-bucket", idx)
+	const key = "2025/11/08/WEBP/broken.webp"
+
+	for i, want := range []time.Duration{retryDelay, 2 * retryDelay} {
+		ix.Enqueue(key)
+		if len(ix.queue) != 1 {
+			t.Fatalf("attempt %d: expected the photo to be queued", i)
+		}
+		ix.indexQueued(context.Background(), <-ix.queue)
+
+		// requests listing the photo meanwhile do not queue it again
+		ix.Enqueue(key)
+		if len(ix.queue) != 0 {
+			t.Fatalf("attempt %d: expected a failed photo not to be queued again", i)
+		}
+		if got := time.Until(ix.failures[key].retryAt); got > want || got < want-time.Second {
+			t.Errorf("attempt %d: retrying in %s, want %s", i, got, want)
+		}
+
+		// the delay is over
+		f := ix.failures[key]
+		f.retryAt = time.Now()
+		ix.failures[key] = f
+	}
+	if calls != 2 {
+		t.Errorf("expected the photo to be fetched twice, got %d calls", calls)
+	}
+}
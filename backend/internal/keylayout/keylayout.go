@@ -0,0 +1,202 @@
+// Package keylayout describes how check-in photos are named in the bucket,
+// with a key template such as {yyyy}/{mm}/{dd}/{format}/{id}.{ext}.
+package keylayout
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTemplate is the layout written by untappd-recorder.
+const DefaultTemplate = "{yyyy}/{mm}/{dd}/{format}/{id}.{ext}"
+
+// Default is the layout of DefaultTemplate.
+var Default = MustParse(DefaultTemplate)
+
+// patterns of the placeholders a template can use
+var placeholders = map[string]string{
+	"yyyy":   `\d{4}`,
+	"mm":     `\d{2}`,
+	"dd":     `\d{2}`,
+	"format": `[^/]+`,
+	"id":     `[^/]+`,
+	"ext":    `[^/.]+`,
+}
+
+var (
+	placeholderRe = regexp.MustCompile(`\{([a-z]+)\}`)
+	required      = []string{"yyyy", "mm", "format", "id", "ext"}
+)
+
+// Key holds the components of an object key.
+type Key struct {
+	Year   int
+	Month  time.Month
+	Day    int
+	Format string
+	ID     string
+	Ext    string
+}
+
+// Checkin identifies the check-in a key belongs to. The renditions of a
+// check-in only differ by their format and extension.
+func (k Key) Checkin() string {
+	return fmt.Sprintf("%04d/%02d/%02d/%s", k.Year, k.Month, k.Day, k.ID)
+}
+
+// Layout parses and builds the keys of a template.
+type Layout struct {
+	template string
+	re       *regexp.Regexp
+	// literal text and date placeholders before any other placeholder
+	monthPrefix string
+}
+
+// Parse compiles a key template. It must contain the {yyyy}, {mm},
+// {format}, {id} and {ext} placeholders, with the year and month first so
+// the check-ins of a month can be listed by prefix.
+func Parse(template string) (*Layout, error) {
+	if template == "" {
+		return nil, errors.New("empty key template")
+	}
+
+	var (
+		pattern     strings.Builder
+		prefix      strings.Builder
+		seen        = map[string]bool{}
+		inPrefix    = true
+		last        int
+		placeholder = placeholderRe.FindAllStringSubmatchIndex(template, -1)
+	)
+	pattern.WriteString("^")
+	for _, m := range placeholder {
+		literal, name := template[last:m[0]], template[m[2]:m[3]]
+		last = m[1]
+
+		expr, ok := placeholders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s} in key template", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate placeholder {%s} in key template", name)
+		}
+		seen[name] = true
+
+		pattern.WriteString(regexp.QuoteMeta(literal))
+		pattern.WriteString("(?P<" + name + ">" + expr + ")")
+
+		if inPrefix {
+			prefix.WriteString(literal)
+			if name == "yyyy" || name == "mm" {
+				prefix.WriteString("{" + name + "}")
+			} else {
+				inPrefix = false
+			}
+		}
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]) + "$")
+
+	for _, name := range required {
+		if !seen[name] {
+			return nil, fmt.Errorf("key template is missing the {%s} placeholder", name)
+		}
+	}
+	monthPrefix := prefix.String()
+	if !strings.Contains(monthPrefix, "{yyyy}") || !strings.Contains(monthPrefix, "{mm}") {
+		return nil, errors.New("key template must start with the {yyyy} and {mm} placeholders")
+	}
+
+	return &Layout{
+		template:    template,
+		re:          regexp.MustCompile(pattern.String()),
+		monthPrefix: monthPrefix,
+	}, nil
+}
+
+// MustParse is like Parse but panics on invalid templates.
+func MustParse(template string) *Layout {
+	l, err := Parse(template)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+func (l *Layout) String() string { return l.template }
+
+// Match parses the components of a key. It reports false when the key does
+// not follow the layout.
+func (l *Layout) Match(key string) (Key, bool) {
+	m := l.re.FindStringSubmatch(key)
+	if m == nil {
+		return Key{}, false
+	}
+
+	k := Key{Day: 1}
+	for i, name := range l.re.SubexpNames() {
+		switch name {
+		case "yyyy":
+			k.Year, _ = strconv.Atoi(m[i])
+		case "mm":
+			month, _ := strconv.Atoi(m[i])
+			k.Month = time.Month(month)
+		case "dd":
+			k.Day, _ = strconv.Atoi(m[i])
+		case "format":
+			k.Format = m[i]
+		case "id":
+			k.ID = m[i]
+		case "ext":
+			k.Ext = m[i]
+		}
+	}
+	if k.Month < time.January || k.Month > time.December || k.Day < 1 || k.Day > 31 {
+		return Key{}, false
+	}
+	return k, true
+}
+
+// Build returns the key of the given components. Components missing from
+// the template are ignored.
+func (l *Layout) Build(k Key) string {
+	return strings.NewReplacer(
+		"{yyyy}", fmt.Sprintf("%04d", k.Year),
+		"{mm}", fmt.Sprintf("%02d", int(k.Month)),
+		"{dd}", fmt.Sprintf("%02d", k.Day),
+		"{format}", k.Format,
+		"{id}", k.ID,
+		"{ext}", k.Ext,
+	).Replace(l.template)
+}
+
+// Month returns the first day of the month a key was recorded in.
+func (l *Layout) Month(key string) (time.Time, error) {
+	k, ok := l.Match(key)
+	if !ok {
+		return time.Time{}, fmt.Errorf("key %q does not match layout %s", key, l.template)
+	}
+	return time.Date(k.Year, k.Month, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// MonthPrefix returns the prefix shared by every key of a month.
+func (l *Layout) MonthPrefix(t time.Time) string {
+	return strings.NewReplacer(
+		"{yyyy}", fmt.Sprintf("%04d", t.Year()),
+		"{mm}", fmt.Sprintf("%02d", int(t.Month())),
+	).Replace(l.monthPrefix)
+}
+
+// GroupPrefix returns the part of a key before its format, shared by the
+// renditions of a check-in and by its neighbours in listing order.
+func (l *Layout) GroupPrefix(key string) string {
+	loc := l.re.FindStringSubmatchIndex(key)
+	if loc == nil {
+		return key
+	}
+	i := l.re.SubexpIndex("format")
+	return key[:loc[2*i]]
+}
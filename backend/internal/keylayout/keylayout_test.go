@@ -0,0 +1,160 @@
+package keylayout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		expectErr bool
+	}{
+		{name: "default", template: DefaultTemplate},
+		{name: "flat", template: "photos/{yyyy}-{mm}-{dd}_{id}_{format}.{ext}"},
+		{name: "no day", template: "{yyyy}/{mm}/{format}/{id}.{ext}"},
+		{name: "empty", template: "", expectErr: true},
+		{name: "missing id", template: "{yyyy}/{mm}/{format}.{ext}", expectErr: true},
+		{name: "unknown placeholder", template: "{yyyy}/{mm}/{venue}/{format}/{id}.{ext}", expectErr: true},
+		{name: "duplicate placeholder", template: "{yyyy}/{mm}/{id}/{format}/{id}.{ext}", expectErr: true},
+		{name: "month not first", template: "{format}/{yyyy}/{mm}/{id}.{ext}", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.template)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("Parse(%q) error = %v, expectErr %v", tt.template, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	flat := MustParse("photos/{yyyy}-{mm}-{dd}_{id}_{format}.{ext}")
+
+	tests := []struct {
+		name     string
+		layout   *Layout
+		key      string
+		expected Key
+		ok       bool
+	}{
+		{
+			name:     "default",
+			layout:   Default,
+			key:      "2025/11/08/WEBP/image1.webp",
+			expected: Key{Year: 2025, Month: time.November, Day: 8, Format: "WEBP", ID: "image1", Ext: "webp"},
+			ok:       true,
+		},
+		{
+			name:     "dotted id",
+			layout:   Default,
+			key:      "2025/11/08/JPEG/image.1.jpg",
+			expected: Key{Year: 2025, Month: time.November, Day: 8, Format: "JPEG", ID: "image.1", Ext: "jpg"},
+			ok:       true,
+		},
+		{
+			name:     "flat",
+			layout:   flat,
+			key:      "photos/2025-11-08_1234_avif.avif",
+			expected: Key{Year: 2025, Month: time.November, Day: 8, Format: "avif", ID: "1234", Ext: "avif"},
+			ok:       true,
+		},
+		{name: "missing format", layout: Default, key: "2025/11/08/image1.webp"},
+		{name: "invalid month", layout: Default, key: "2025/13/08/WEBP/image1.webp"},
+		{name: "other object", layout: Default, key: "_meta/hidden.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.layout.Match(tt.key)
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("Match(%q) = %+v, %v, want %+v, %v", tt.key, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCheckin(t *testing.T) {
+	webp, _ := Default.Match("2025/11/08/WEBP/image1.webp")
+	jpeg, _ := Default.Match("2025/11/08/JPEG/image1.jpg")
+	other, _ := Default.Match("2025/11/09/JPEG/image1.jpg")
+
+	if webp.Checkin() != jpeg.Checkin() {
+		t.Errorf("renditions belong to different check-ins: %s and %s", webp.Checkin(), jpeg.Checkin())
+	}
+	if webp.Checkin() == other.Checkin() {
+		t.Errorf("expected different check-ins on different days")
+	}
+}
+
+func TestMonth(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		expected  time.Time
+		expectErr bool
+	}{
+		{
+			name:     "valid key",
+			key:      "2025/11/08/WEBP/image.webp",
+			expected: time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "invalid key",
+			key:       "2025-11-08-webp-image.webp",
+			expectErr: true,
+		},
+		{
+			name:      "empty key",
+			key:       "",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Default.Month(tt.key)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("Month() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && !got.Equal(tt.expected) {
+				t.Errorf("Month() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMonthPrefix(t *testing.T) {
+	at := time.Date(2025, time.March, 14, 0, 0, 0, 0, time.UTC)
+
+	if got := Default.MonthPrefix(at); got != "2025/03/" {
+		t.Errorf("MonthPrefix() = %q, want %q", got, "2025/03/")
+	}
+	flat := MustParse("photos/{yyyy}-{mm}-{dd}_{id}_{format}.{ext}")
+	if got := flat.MonthPrefix(at); got != "photos/2025-03-" {
+		t.Errorf("MonthPrefix() = %q, want %q", got, "photos/2025-03-")
+	}
+}
+
+func TestGroupPrefix(t *testing.T) {
+	if got := Default.GroupPrefix("2025/11/08/WEBP/image1.webp"); got != "2025/11/08/" {
+		t.Errorf("GroupPrefix() = %q, want %q", got, "2025/11/08/")
+	}
+	if got := Default.GroupPrefix("notes.txt"); got != "notes.txt" {
+		t.Errorf("GroupPrefix() = %q, want the key itself", got)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	k := Key{Year: 2025, Month: time.March, Day: 4, Format: "WEBP", ID: "1234", Ext: "webp"}
+
+	if got := Default.Build(k); got != "2025/03/04/WEBP/1234.webp" {
+		t.Errorf("Build() = %q, want %q", got, "2025/03/04/WEBP/1234.webp")
+	}
+	if got, ok := Default.Match(Default.Build(k)); !ok || got != k {
+		t.Errorf("Match(Build()) = %+v, %v, want %+v", got, ok, k)
+	}
+}
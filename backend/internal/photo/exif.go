@@ -0,0 +1,118 @@
+package photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// EXIF tag holding the orientation of the camera
+const orientationTag = 0x0112
+
+// jpegOrientation returns the EXIF orientation of a JPEG file, from 1 to 8,
+// or 1 when it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// start of the image data, no more metadata
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of a TIFF
+// header, as embedded in EXIF segments.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != orientationTag {
+			continue
+		}
+		o := int(order.Uint16(tiff[entry+8:]))
+		if o < 1 || o > 8 {
+			return 1
+		}
+		return o
+	}
+	return 1
+}
+
+// orient returns img as it should be displayed given its EXIF orientation.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// source pixel of each destination pixel
+	var src func(x, y int) (int, int)
+	switch orientation {
+	case 2: // mirrored
+		src = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // upside down
+		src = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // mirrored upside down
+		src = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // mirrored, rotated counterclockwise
+		src = func(x, y int) (int, int) { return y, x }
+	case 6: // rotated counterclockwise
+		src = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // mirrored, rotated clockwise
+		src = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // rotated clockwise
+		src = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := src(x, y)
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,107 @@
+// Package photo prepares uploaded photos for the bucket.
+package photo
+
+import (
+	"beers/backend/internal/thumbnail"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+const (
+	// format folder, extension and content type of the stored photos
+	Format      = "WEBP"
+	Extension   = "webp"
+	ContentType = "image/webp"
+	// longest side of the stored photos, larger uploads are scaled down
+	MaxSize = thumbnail.MaxSize
+	// largest upload decoded, a small file can hold a huge image
+	MaxPixels = 50_000_000
+	// largest stored photo; the encoder is lossless, so detailed photos are
+	// scaled down further to stay under it
+	MaxBytes = 4 << 20
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format, expected JPEG or PNG")
+	ErrTooLarge          = fmt.Errorf("image larger than %d pixels", MaxPixels)
+)
+
+// ConvertToWebp decodes a JPEG or PNG photo and encodes it as WEBP. The
+// photo is turned upright according to its EXIF orientation, and none of
+// its metadata is kept.
+//
+// The only WEBP encoder available without cgo is lossless, which makes
+// photos several times larger than a lossy one would. Photos are scaled
+// down until they fit in MaxBytes rather than stored that large.
+func ConvertToWebp(data []byte) (webp []byte, bounds image.Rectangle, err error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, image.Rectangle{}, ErrUnsupportedFormat
+	}
+	// check the size before decoding the whole image
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("decode image: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, image.Rectangle{}, ErrTooLarge
+	}
+
+	var img image.Image
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		if err == nil {
+			img = orient(img, jpegOrientation(data))
+		}
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("decode image: %w", err)
+	}
+
+	src, size := img, MaxSize
+	for {
+		img = src
+		if b := src.Bounds(); b.Dx() > size || b.Dy() > size {
+			img = thumbnail.Resize(src, thumbnail.Options{Width: size, Height: size})
+		}
+
+		var buf bytes.Buffer
+		if err := encodeWebp(&buf, img); err != nil {
+			return nil, image.Rectangle{}, fmt.Errorf("encode webp: %w", err)
+		}
+		if buf.Len() <= MaxBytes || size <= minSize {
+			return buf.Bytes(), img.Bounds(), nil
+		}
+
+		// the output grows with the number of pixels, aim a little lower
+		b := img.Bounds()
+		scale := math.Sqrt(float64(MaxBytes)/float64(buf.Len())) * 0.9
+		size = max(minSize, int(float64(max(b.Dx(), b.Dy()))*scale))
+	}
+}
+
+// smallest longest side photos are scaled down to so they fit in MaxBytes
+const minSize = 640
+
+// encodeWebp encodes img, turning the panics the encoder raises on some
+// noisy images into errors.
+func encodeWebp(w io.Writer, img image.Image) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return nativewebp.Encode(w, img, nil)
+}
@@ -0,0 +1,204 @@
+package photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand/v2"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// withOrientation inserts an EXIF segment holding an orientation tag right
+// after the start marker of a JPEG file.
+func withOrientation(t *testing.T, data []byte, orientation uint16) []byte {
+	t.Helper()
+
+	tiff := []byte("MM\x00\x2a")
+	tiff = binary.BigEndian.AppendUint32(tiff, 8)
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientationTag)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	tiff = binary.BigEndian.AppendUint32(tiff, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestJpegOrientation(t *testing.T) {
+	data := encodeJPEG(t, image.NewRGBA(image.Rect(0, 0, 4, 2)))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected int
+	}{
+		{"no exif", data, 1},
+		{"rotated", withOrientation(t, data, 6), 6},
+		{"mirrored", withOrientation(t, data, 2), 2},
+		{"out of range", withOrientation(t, data, 9), 1},
+		{"not a jpeg", []byte("GIF89a"), 1},
+		{"truncated", withOrientation(t, data, 6)[:12], 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.expected {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 3x2 image with a red top-left pixel
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	red := color.NRGBA{R: 255, A: 255}
+	img.Set(0, 0, red)
+
+	tests := []struct {
+		orientation int
+		size        image.Point
+		red         image.Point
+	}{
+		{1, image.Pt(3, 2), image.Pt(0, 0)},
+		{2, image.Pt(3, 2), image.Pt(2, 0)},
+		{3, image.Pt(3, 2), image.Pt(2, 1)},
+		{4, image.Pt(3, 2), image.Pt(0, 1)},
+		{5, image.Pt(2, 3), image.Pt(0, 0)},
+		{6, image.Pt(2, 3), image.Pt(1, 0)},
+		{7, image.Pt(2, 3), image.Pt(1, 2)},
+		{8, image.Pt(2, 3), image.Pt(0, 2)},
+	}
+
+	for _, tt := range tests {
+		got := orient(img, tt.orientation)
+		if size := got.Bounds().Size(); size != tt.size {
+			t.Errorf("orientation %d: size = %v, want %v", tt.orientation, size, tt.size)
+			continue
+		}
+		if c := color.NRGBAModel.Convert(got.At(tt.red.X, tt.red.Y)); c != red {
+			t.Errorf("orientation %d: pixel at %v = %v, want red", tt.orientation, tt.red, c)
+		}
+	}
+}
+
+func TestConvertToWebp(t *testing.T) {
+	landscape := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, landscape); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		size image.Point
+	}{
+		{"png", pngData.Bytes(), image.Pt(40, 20)},
+		{"jpeg", encodeJPEG(t, landscape), image.Pt(40, 20)},
+		{"rotated jpeg", withOrientation(t, encodeJPEG(t, landscape), 6), image.Pt(20, 40)},
+		{"too large", encodeJPEG(t, image.NewRGBA(image.Rect(0, 0, 2*MaxSize, MaxSize))), image.Pt(MaxSize, MaxSize/2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, bounds, err := ConvertToWebp(tt.data)
+			if err != nil {
+				t.Fatalf("ConvertToWebp() error = %v", err)
+			}
+			if bounds.Size() != tt.size {
+				t.Errorf("bounds = %v, want size %v", bounds, tt.size)
+			}
+			cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("output is not a webp image: %v", err)
+			}
+			if cfg.Width != tt.size.X || cfg.Height != tt.size.Y {
+				t.Errorf("webp size = %dx%d, want %v", cfg.Width, cfg.Height, tt.size)
+			}
+			if bytes.Contains(data, []byte("Exif")) {
+				t.Errorf("expected EXIF data to be stripped")
+			}
+		})
+	}
+}
+
+// withPNGSize rewrites the size in the header of a PNG file, leaving its
+// pixel data as is.
+func withPNGSize(data []byte, width, height uint32) []byte {
+	out := append([]byte{}, data...)
+	// signature, chunk length and type, then the IHDR data and its CRC
+	ihdr := out[12:29]
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	binary.BigEndian.PutUint32(out[29:], crc32.ChecksumIEEE(ihdr))
+	return out
+}
+
+func TestConvertToWebpMaxBytes(t *testing.T) {
+	// noise does not compress, so a lossless copy is over the limit
+	noise := image.NewNRGBA(image.Rect(0, 0, 1400, 1400))
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range noise.Pix {
+		noise.Pix[i] = byte(r.Uint32() % 64)
+		if i%4 == 3 {
+			noise.Pix[i] = 0xff
+		}
+	}
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, noise); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+
+	data, bounds, err := ConvertToWebp(pngData.Bytes())
+	if err != nil {
+		t.Fatalf("ConvertToWebp() error = %v", err)
+	}
+	if len(data) > MaxBytes {
+		t.Errorf("output is %d bytes, want at most %d", len(data), MaxBytes)
+	}
+	if bounds.Dx() >= 1400 || bounds.Dx() != bounds.Dy() {
+		t.Errorf("expected the photo to be scaled down, got %v", bounds)
+	}
+}
+
+func TestConvertToWebpErrors(t *testing.T) {
+	if _, _, err := ConvertToWebp([]byte("GIF89a not supported")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("error = %v, want ErrUnsupportedFormat", err)
+	}
+	var small bytes.Buffer
+	if err := png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	if _, _, err := ConvertToWebp(withPNGSize(small.Bytes(), 10000, 10000)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("error = %v, want ErrTooLarge", err)
+	}
+	if _, _, err := ConvertToWebp([]byte("\x89PNG\r\n\x1a\ntruncated")); err == nil || errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("error = %v, want a decoding error", err)
+	}
+}
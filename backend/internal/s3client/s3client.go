@@ -2,13 +2,17 @@ package s3client
 
 import (
 	"beers/backend/internal/config"
+	"bytes"
 	"context"
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type S3Client interface {
@@ -23,14 +27,62 @@ type S3Client interface {
 		params *s3.HeadObjectInput,
 		optFns ...func(*s3.Options),
 	) (*s3.HeadObjectOutput, error)
+
+	GetObject(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error)
+
+	CopyObject(
+		ctx context.Context,
+		params *s3.CopyObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.CopyObjectOutput, error)
+
+	PutObject(
+		ctx context.Context,
+		params *s3.PutObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.PutObjectOutput, error)
+}
+
+// Presigner is implemented by clients able to generate presigned URLs.
+type Presigner interface {
+	PresignGetObject(
+		ctx context.Context,
+		bucketName, objectKey string,
+		ttl time.Duration,
+	) (string, error)
+}
+
+// Client is an S3 client which can also presign requests.
+type Client struct {
+	*s3.Client
+	presign *s3.PresignClient
 }
 
-func NewS3Client(ctx context.Context, bucketRegion string) (*s3.Client, error) {
-	cfg, err := config.Load()
+// PresignGetObject returns a URL granting read access to an object for the
+// given duration.
+func (c *Client) PresignGetObject(
+	ctx context.Context,
+	bucketName, objectKey string,
+	ttl time.Duration,
+) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+
+	req, err := c.presign.PresignGetObject(ctx, input, s3.WithPresignExpires(ttl))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
+		return "", err
 	}
+	return req.URL, nil
+}
 
+// NewS3Client creates a client for the R2 account and credentials of cfg.
+func NewS3Client(ctx context.Context, cfg *config.AppConfig) (*Client, error) {
 	r2Resolver := aws.EndpointResolverWithOptionsFunc(
 		func(service, region string, _ ...interface{}) (aws.Endpoint, error) {
 			return aws.Endpoint{
@@ -47,13 +99,14 @@ func NewS3Client(ctx context.Context, bucketRegion string) (*s3.Client, error) {
 		awsconfig.WithCredentialsProvider(
 			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
 		),
-		awsconfig.WithRegion(bucketRegion),
+		awsconfig.WithRegion(cfg.BucketRegion),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return s3.NewFromConfig(awsCfg), nil
+	client := s3.NewFromConfig(awsCfg)
+	return &Client{Client: client, presign: s3.NewPresignClient(client)}, nil
 }
 
 func ListObjects(
@@ -86,3 +139,91 @@ func GetObjectMetadata(
 
 	return client.HeadObject(ctx, input)
 }
+
+func GetObject(
+	ctx context.Context,
+	client S3Client,
+	bucketName, objectKey string,
+) (*s3.GetObjectOutput, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+
+	return client.GetObject(ctx, input)
+}
+
+// CreateObject uploads a new object, failing if the key is already taken.
+func CreateObject(
+	ctx context.Context,
+	client S3Client,
+	bucketName, objectKey, contentType string,
+	body []byte,
+	metadata map[string]string,
+) error {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(objectKey),
+		Body:          bytes.NewReader(body),
+		ContentLength: aws.Int64(int64(len(body))),
+		ContentType:   aws.String(contentType),
+		IfNoneMatch:   aws.String("*"),
+		Metadata:      metadata,
+	}
+
+	_, err := client.PutObject(ctx, input)
+	return err
+}
+
+// ReplaceObject overwrites an object, failing if it no longer has the given
+// ETag.
+func ReplaceObject(
+	ctx context.Context,
+	client S3Client,
+	bucketName, objectKey, contentType string,
+	body []byte,
+	etag *string,
+) error {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(objectKey),
+		Body:          bytes.NewReader(body),
+		ContentLength: aws.Int64(int64(len(body))),
+		ContentType:   aws.String(contentType),
+		IfMatch:       etag,
+	}
+
+	_, err := client.PutObject(ctx, input)
+	return err
+}
+
+// ReplaceObjectMetadata rewrites the user metadata of an object by copying
+// it onto itself. The copy only happens if the object still has the given
+// ETag, and keeps its content type and cache control.
+func ReplaceObjectMetadata(
+	ctx context.Context,
+	client S3Client,
+	bucketName, objectKey string,
+	head *s3.HeadObjectOutput,
+	metadata map[string]string,
+) error {
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(bucketName),
+		Key:               aws.String(objectKey),
+		CopySource:        aws.String(url.PathEscape(bucketName) + "/" + escapeKey(objectKey)),
+		CopySourceIfMatch: head.ETag,
+		MetadataDirective: types.MetadataDirectiveReplace,
+		Metadata:          metadata,
+		ContentType:       head.ContentType,
+		CacheControl:      head.CacheControl,
+	}
+
+	_, err := client.CopyObject(ctx, input)
+	return err
+}
+
+// escapeKey URL-encodes every segment of an object key.
+func escapeKey(key string) string {
+	u := url.URL{Path: key}
+	return u.EscapedPath()
+}
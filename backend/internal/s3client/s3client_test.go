@@ -2,10 +2,14 @@ package s3client
 
 import (
 	"context"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type MockS3Client struct {
@@ -19,6 +23,21 @@ type MockS3Client struct {
 		params *s3.HeadObjectInput,
 		optFns ...func(*s3.Options),
 	) (*s3.HeadObjectOutput, error)
+	GetObjectFunc func(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error)
+	CopyObjectFunc func(
+		ctx context.Context,
+		params *s3.CopyObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.CopyObjectOutput, error)
+	PutObjectFunc func(
+		ctx context.Context,
+		params *s3.PutObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.PutObjectOutput, error)
 }
 
 func (m *MockS3Client) ListObjectsV2(
@@ -43,6 +62,39 @@ func (m *MockS3Client) HeadObject(
 	return m.HeadObjectFunc(ctx, params, optFns...)
 }
 
+func (m *MockS3Client) GetObject(
+	ctx context.Context,
+	params *s3.GetObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.GetObjectOutput, error) {
+	if m.GetObjectFunc == nil {
+		panic("GetObjectFunc not set on MockS3Client")
+	}
+	return m.GetObjectFunc(ctx, params, optFns...)
+}
+
+func (m *MockS3Client) CopyObject(
+	ctx context.Context,
+	params *s3.CopyObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.CopyObjectOutput, error) {
+	if m.CopyObjectFunc == nil {
+		panic("CopyObjectFunc not set on MockS3Client")
+	}
+	return m.CopyObjectFunc(ctx, params, optFns...)
+}
+
+func (m *MockS3Client) PutObject(
+	ctx context.Context,
+	params *s3.PutObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.PutObjectOutput, error) {
+	if m.PutObjectFunc == nil {
+		panic("PutObjectFunc not set on MockS3Client")
+	}
+	return m.PutObjectFunc(ctx, params, optFns...)
+}
+
 func TestListObjects(t *testing.T) {
 	mockClient := &MockS3Client{
 		ListObjectsV2Func: func(
@@ -105,3 +157,140 @@ func TestGetObjectMetadata(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestGetObject(t *testing.T) {
+	mockClient := &MockS3Client{
+		GetObjectFunc: func(
+			ctx context.Context,
+			params *s3.GetObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.GetObjectOutput, error) {
+			if got, want := aws.ToString(params.Bucket), "test-bucket"; got != want {
+				t.Errorf("Bucket = %q, want %q", got, want)
+			}
+			if got, want := aws.ToString(params.Key), "test-key"; got != want {
+				t.Errorf("Key = %q, want %q", got, want)
+			}
+			return &s3.GetObjectOutput{}, nil
+		},
+	}
+
+	_, err := GetObject(
+		context.Background(),
+		mockClient,
+		"test-bucket",
+		"test-key",
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPresignGetObject(t *testing.T) {
+	s3c := s3.New(s3.Options{
+		Region:       "auto",
+		BaseEndpoint: aws.String("https://account.r2.cloudflarestorage.com"),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	})
+	client := &Client{Client: s3c, presign: s3.NewPresignClient(s3c)}
+
+	got, err := client.PresignGetObject(context.Background(), "test-bucket", "test-key", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("invalid URL %q: %v", got, err)
+	}
+	if u.Path != "/test-bucket/test-key" {
+		t.Errorf("Path = %q, want %q", u.Path, "/test-bucket/test-key")
+	}
+	if got, want := u.Query().Get("X-Amz-Expires"), "900"; got != want {
+		t.Errorf("X-Amz-Expires = %q, want %q", got, want)
+	}
+}
+
+func TestReplaceObjectMetadata(t *testing.T) {
+	mockClient := &MockS3Client{
+		CopyObjectFunc: func(
+			ctx context.Context,
+			params *s3.CopyObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.CopyObjectOutput, error) {
+			if got, want := aws.ToString(params.Key), "2025/11/08/WEBP/image 1.webp"; got != want {
+				t.Errorf("Key = %q, want %q", got, want)
+			}
+			if got, want := aws.ToString(params.CopySource), "test-bucket/2025/11/08/WEBP/image%201.webp"; got != want {
+				t.Errorf("CopySource = %q, want %q", got, want)
+			}
+			if params.MetadataDirective != types.MetadataDirectiveReplace {
+				t.Errorf("MetadataDirective = %q, want REPLACE", params.MetadataDirective)
+			}
+			if got := aws.ToString(params.CopySourceIfMatch); got != `"abc"` {
+				t.Errorf("CopySourceIfMatch = %q, want %q", got, `"abc"`)
+			}
+			if got := aws.ToString(params.ContentType); got != "image/webp" {
+				t.Errorf("ContentType = %q, want %q", got, "image/webp")
+			}
+			if got := params.Metadata["rating"]; got != "4" {
+				t.Errorf("Metadata[rating] = %q, want %q", got, "4")
+			}
+			return &s3.CopyObjectOutput{}, nil
+		},
+	}
+
+	head := &s3.HeadObjectOutput{ETag: aws.String(`"abc"`), ContentType: aws.String("image/webp")}
+	err := ReplaceObjectMetadata(
+		context.Background(),
+		mockClient,
+		"test-bucket",
+		"2025/11/08/WEBP/image 1.webp",
+		head,
+		map[string]string{"rating": "4"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCreateObject(t *testing.T) {
+	mockClient := &MockS3Client{
+		PutObjectFunc: func(
+			ctx context.Context,
+			params *s3.PutObjectInput,
+			optFns ...func(*s3.Options),
+		) (*s3.PutObjectOutput, error) {
+			if got, want := aws.ToString(params.Key), "2025/11/08/WEBP/image1.webp"; got != want {
+				t.Errorf("Key = %q, want %q", got, want)
+			}
+			if got := aws.ToString(params.IfNoneMatch); got != "*" {
+				t.Errorf("IfNoneMatch = %q, want %q", got, "*")
+			}
+			if got := aws.ToInt64(params.ContentLength); got != 4 {
+				t.Errorf("ContentLength = %d, want 4", got)
+			}
+			if got := aws.ToString(params.ContentType); got != "image/webp" {
+				t.Errorf("ContentType = %q, want %q", got, "image/webp")
+			}
+			if got := params.Metadata["id"]; got != "1" {
+				t.Errorf("Metadata[id] = %q, want %q", got, "1")
+			}
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+
+	err := CreateObject(
+		context.Background(),
+		mockClient,
+		"test-bucket",
+		"2025/11/08/WEBP/image1.webp",
+		"image/webp",
+		[]byte("webp"),
+		map[string]string{"id": "1"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package thumbnail
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/url"
+	"strconv"
+
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// largest width or height a rendition can be requested at
+	MaxSize = 2048
+
+	ContentType = "image/jpeg"
+	quality     = 80
+)
+
+// Options gives the box a rendition fits in, keeping the aspect ratio of the
+// image. A zero width or height leaves that dimension unconstrained.
+type Options struct {
+	Width  int
+	Height int
+}
+
+// ParseOptions reads the w query parameter. Renditions are only requested by
+// width, so other parameters are ignored.
+func ParseOptions(q url.Values) (Options, error) {
+	s := q.Get("w")
+	if s == "" {
+		return Options{}, errors.New("w is required")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > MaxSize {
+		return Options{}, fmt.Errorf("invalid w: must be between 1 and %d", MaxSize)
+	}
+	return Options{Width: n}, nil
+}
+
+// String returns a canonical form of the options, usable as a cache key.
+func (o Options) String() string {
+	return fmt.Sprintf("w=%d&h=%d", o.Width, o.Height)
+}
+
+// scaleToFit returns the size of a w x h rectangle shrunk to fit in the
+// maxW x maxH box, keeping its aspect ratio. Images are never enlarged.
+func scaleToFit(w, h, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 && float64(maxW)/float64(w) < scale {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && float64(maxH)/float64(h) < scale {
+		scale = float64(maxH) / float64(h)
+	}
+	return max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+}
+
+// Resize returns a copy of src shrunk to fit in the box of the options.
+func Resize(src image.Image, o Options) image.Image {
+	b := src.Bounds()
+	w, h := scaleToFit(b.Dx(), b.Dy(), o.Width, o.Height)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	return dst
+}
+
+// Render decodes an image from r, resizes it and writes it to w as JPEG.
+func Render(w io.Writer, r io.Reader, o Options) error {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+	return jpeg.Encode(w, Resize(src, o), &jpeg.Options{Quality: quality})
+}
@@ -0,0 +1,108 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		expected  Options
+		expectErr bool
+	}{
+		{
+			name:     "width only",
+			query:    "w=320",
+			expected: Options{Width: 320},
+		},
+		{
+			name:     "height ignored",
+			query:    "w=320&h=320&fit=cover",
+			expected: Options{Width: 320},
+		},
+		{
+			name:      "no width",
+			query:     "h=320",
+			expectErr: true,
+		},
+		{
+			name:      "zero width",
+			query:     "w=0",
+			expectErr: true,
+		},
+		{
+			name:      "too large",
+			query:     "w=4096",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			got, err := ParseOptions(q)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseOptions() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && got != tt.expected {
+				t.Errorf("ParseOptions() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected image.Point
+	}{
+		{"contain width", Options{Width: 100}, image.Pt(100, 50)},
+		{"contain box", Options{Width: 100, Height: 100}, image.Pt(100, 50)},
+		{"contain height", Options{Height: 100}, image.Pt(200, 100)},
+		{"no upscale", Options{Width: 800}, image.Pt(400, 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Resize(src, tt.opts).Bounds().Size(); got != tt.expected {
+				t.Errorf("Resize() size = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 32; y++ {
+			src.Set(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+	var in bytes.Buffer
+	if err := png.Encode(&in, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Render(&out, &in, Options{Width: 16}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	img, err := jpeg.Decode(&out)
+	if err != nil {
+		t.Fatalf("output is not a JPEG: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(16, 8) {
+		t.Errorf("output size = %v, want 16x8", got)
+	}
+}
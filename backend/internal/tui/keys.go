@@ -0,0 +1,100 @@
+package tui
+
+import "unicode/utf8"
+
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyTab
+	keyCtrlC
+)
+
+// key is a key press read from a terminal in raw mode.
+type key struct {
+	code keyCode
+	// the character typed, for keyRune
+	r rune
+}
+
+// escape sequences of the special keys, as sent by xterm-like terminals
+var escapeKeys = map[string]keyCode{
+	"\x1b[A":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1b[C":  keyRight,
+	"\x1b[D":  keyLeft,
+	"\x1bOA":  keyUp,
+	"\x1bOB":  keyDown,
+	"\x1bOC":  keyRight,
+	"\x1bOD":  keyLeft,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+	"\x1b[H":  keyHome,
+	"\x1b[F":  keyEnd,
+	"\x1b[1~": keyHome,
+	"\x1b[4~": keyEnd,
+}
+
+// parseKeys splits the bytes of one terminal read into key presses.
+// Unknown escape sequences are dropped.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch b[0] {
+		case 0x1b:
+			if len(b) == 1 {
+				return append(keys, key{code: keyEscape})
+			}
+			matched := false
+			for seq, code := range escapeKeys {
+				if len(b) >= len(seq) && string(b[:len(seq)]) == seq {
+					keys = append(keys, key{code: code})
+					b = b[len(seq):]
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				// skip an unknown CSI sequence up to its final byte
+				i := 1
+				if b[1] == '[' || b[1] == 'O' {
+					i = 2
+					for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+						i++
+					}
+					i++
+				}
+				b = b[min(i, len(b)):]
+			}
+			continue
+		case '\r', '\n':
+			keys = append(keys, key{code: keyEnter})
+		case 0x7f, 0x08:
+			keys = append(keys, key{code: keyBackspace})
+		case '\t':
+			keys = append(keys, key{code: keyTab})
+		case 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r >= 0x20 {
+				keys = append(keys, key{code: keyRune, r: r})
+			}
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
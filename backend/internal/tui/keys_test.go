@@ -0,0 +1,30 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []key
+	}{
+		{name: "runes", input: "qé", expected: []key{{code: keyRune, r: 'q'}, {code: keyRune, r: 'é'}}},
+		{name: "arrows", input: "\x1b[A\x1b[B\x1bOC", expected: []key{{code: keyUp}, {code: keyDown}, {code: keyRight}}},
+		{name: "pages", input: "\x1b[5~\x1b[6~", expected: []key{{code: keyPageUp}, {code: keyPageDown}}},
+		{name: "lone escape", input: "\x1b", expected: []key{{code: keyEscape}}},
+		{name: "unknown sequence", input: "\x1b[15~j", expected: []key{{code: keyRune, r: 'j'}}},
+		{name: "controls", input: "\r\t\x7f\x03", expected: []key{{code: keyEnter}, {code: keyTab}, {code: keyBackspace}, {code: keyCtrlC}}},
+		{name: "other controls", input: "\x01", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseKeys([]byte(tt.input)); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseKeys(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
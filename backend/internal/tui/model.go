@@ -0,0 +1,521 @@
+package tui
+
+import (
+	"beers/backend/internal/api"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Source loads the journal, from the bucket in the beers command.
+type Source interface {
+	// months holding check-ins, newest first
+	Months(ctx context.Context) ([]time.Time, error)
+	// check-ins of a month, newest first
+	Month(ctx context.Context, month time.Time) ([]api.Image, error)
+	// every check-in, for searches
+	All(ctx context.Context) ([]api.Image, error)
+}
+
+type pane int
+
+const (
+	monthsPane pane = iota
+	checkinsPane
+)
+
+const (
+	monthFormat = "2006-01"
+	// lines of the detail pane, separator included
+	detailHeight = 9
+)
+
+// model holds the state of the browser. Keys update it and view renders it
+// to a string, so both can be tested without a terminal.
+type model struct {
+	ctx context.Context
+	src Source
+	// called before slow loads, to tell the user to wait
+	busy func(msg string)
+
+	months      []time.Time
+	month       int
+	monthOffset int
+	loaded      map[time.Time][]api.Image
+	// every check-in, loaded by the first search
+	all []api.Image
+
+	// rows of the table: the check-ins of the month or the search results
+	rows   []api.Image
+	cursor int
+	offset int
+
+	focus     pane
+	detail    bool
+	searching bool
+	query     string
+	status    string
+	quit      bool
+}
+
+func newModel(ctx context.Context, src Source) *model {
+	return &model{ctx: ctx, src: src, loaded: map[time.Time][]api.Image{}, focus: checkinsPane}
+}
+
+func (m *model) setBusy(msg string) {
+	if m.busy != nil {
+		m.busy(msg)
+	}
+}
+
+// load lists the months and opens the most recent one.
+func (m *model) load() {
+	m.setBusy("Listing months…")
+	months, err := m.src.Months(m.ctx)
+	if err != nil {
+		m.status = "Error listing months: " + err.Error()
+		return
+	}
+	m.months = months
+	m.selectMonth(0)
+}
+
+func (m *model) selectMonth(i int) {
+	if len(m.months) == 0 {
+		m.rows = nil
+		return
+	}
+	m.month = clamp(i, 0, len(m.months)-1)
+	month := m.months[m.month]
+
+	images, ok := m.loaded[month]
+	if !ok {
+		m.setBusy("Loading " + month.Format(monthFormat) + "…")
+		var err error
+		if images, err = m.src.Month(m.ctx, month); err != nil {
+			m.status = "Error loading " + month.Format(monthFormat) + ": " + err.Error()
+			return
+		}
+		m.loaded[month] = images
+	}
+	m.rows = images
+	m.cursor, m.offset = 0, 0
+	m.status = ""
+}
+
+// matches reports whether a check-in mentions every word of the query.
+func matches(img api.Image, query string) bool {
+	md := img.Metadata
+	text := strings.ToLower(strings.Join([]string{
+		md.Beer, md.Brewery, md.Style, md.Venue, md.City, md.State, md.Country,
+		md.Comment, md.Date, strings.Join(md.TaggedFriends, " "),
+	}, "\x00"))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *model) search() {
+	if m.all == nil {
+		m.setBusy("Loading every check-in…")
+		all, err := m.src.All(m.ctx)
+		if err != nil {
+			m.status = "Error loading check-ins: " + err.Error()
+			return
+		}
+		sortNewestFirst(all)
+		m.all = all
+	}
+
+	rows := []api.Image{}
+	for _, img := range m.all {
+		if matches(img, m.query) {
+			rows = append(rows, img)
+		}
+	}
+	m.rows = rows
+	m.cursor, m.offset = 0, 0
+}
+
+// sortNewestFirst orders check-ins by date, which sorts as text. Check-ins
+// without a date go last.
+func sortNewestFirst(images []api.Image) {
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].Metadata.Date > images[j].Metadata.Date
+	})
+}
+
+func (m *model) clearSearch() {
+	m.searching = false
+	m.query = ""
+	m.selectMonth(m.month)
+}
+
+func (m *model) moveCursor(delta int) {
+	if m.focus == monthsPane {
+		if i := clamp(m.month+delta, 0, len(m.months)-1); i != m.month && m.query == "" {
+			m.selectMonth(i)
+		}
+		return
+	}
+	m.cursor = clamp(m.cursor+delta, 0, len(m.rows)-1)
+}
+
+// update applies a key press.
+func (m *model) update(k key) {
+	if k.code == keyCtrlC {
+		m.quit = true
+		return
+	}
+
+	if m.searching {
+		switch k.code {
+		case keyRune:
+			m.query += string(k.r)
+			m.search()
+		case keyBackspace:
+			if _, size := utf8.DecodeLastRuneInString(m.query); size > 0 {
+				m.query = m.query[:len(m.query)-size]
+				m.search()
+			}
+		case keyEnter:
+			m.searching = false
+			m.focus = checkinsPane
+			if m.query == "" {
+				m.clearSearch()
+			}
+		case keyEscape:
+			m.clearSearch()
+		case keyUp, keyDown:
+			m.searching = false
+			m.focus = checkinsPane
+			m.update(k)
+		}
+		return
+	}
+
+	page := 10
+	switch k.code {
+	case keyUp:
+		m.moveCursor(-1)
+	case keyDown:
+		m.moveCursor(1)
+	case keyPageUp:
+		m.moveCursor(-page)
+	case keyPageDown:
+		m.moveCursor(page)
+	case keyHome:
+		m.moveCursor(-len(m.rows) - len(m.months))
+	case keyEnd:
+		m.moveCursor(len(m.rows) + len(m.months))
+	case keyLeft:
+		m.focus = monthsPane
+	case keyRight:
+		m.focus = checkinsPane
+	case keyTab:
+		m.focus = 1 - m.focus
+	case keyEnter:
+		if m.focus == monthsPane {
+			m.focus = checkinsPane
+		} else {
+			m.detail = !m.detail
+		}
+	case keyEscape:
+		if m.detail {
+			m.detail = false
+		} else if m.query != "" {
+			m.clearSearch()
+		}
+	case keyRune:
+		switch k.r {
+		case 'q':
+			m.quit = true
+		case 'k':
+			m.moveCursor(-1)
+		case 'j':
+			m.moveCursor(1)
+		case 'h':
+			m.focus = monthsPane
+		case 'l':
+			m.focus = checkinsPane
+		case 'g':
+			m.moveCursor(-len(m.rows) - len(m.months))
+		case 'G':
+			m.moveCursor(len(m.rows) + len(m.months))
+		case '/':
+			m.searching = true
+			m.focus = checkinsPane
+			m.search()
+		}
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
+
+// fit truncates or pads s to exactly width columns.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 {
+			return ' '
+		}
+		return r
+	}, s)
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		runes := []rune(s)
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// wrap splits text into lines of at most width columns.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+const (
+	reverse = "\x1b[7m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	reset   = "\x1b[0m"
+)
+
+// table columns, the flexible ones share the space left by the others
+type column struct {
+	title string
+	width int
+	flex  int
+	value func(md api.CheckinMetadata) string
+}
+
+var columns = []column{
+	{title: "Date", width: 10, value: func(md api.CheckinMetadata) string { return firstN(md.Date, 10) }},
+	{title: "Beer", flex: 4, value: func(md api.CheckinMetadata) string { return md.Beer }},
+	{title: "Brewery", flex: 3, value: func(md api.CheckinMetadata) string { return md.Brewery }},
+	{title: "Rating", width: 6, value: func(md api.CheckinMetadata) string { return md.Rating }},
+	{title: "Venue", flex: 3, value: func(md api.CheckinMetadata) string { return md.Venue }},
+}
+
+func firstN(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// columnWidths spreads width over the columns, one space between each.
+func columnWidths(width int) []int {
+	widths := make([]int, len(columns))
+	free, flex := width-(len(columns)-1), 0
+	for i, c := range columns {
+		widths[i] = c.width
+		free -= c.width
+		flex += c.flex
+	}
+	if free < 0 {
+		free = 0
+	}
+	for i, c := range columns {
+		if c.flex > 0 {
+			widths[i] = free * c.flex / flex
+		}
+	}
+	return widths
+}
+
+func tableRow(md api.CheckinMetadata, widths []int) string {
+	cells := make([]string, len(columns))
+	for i, c := range columns {
+		cells[i] = fit(c.value(md), widths[i])
+	}
+	return strings.Join(cells, " ")
+}
+
+// detailLines describes the selected check-in.
+func detailLines(img api.Image, width int) []string {
+	md := img.Metadata
+	var lines []string
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%-9s %s", label, value))
+		}
+	}
+
+	title := md.Beer
+	if md.Brewery != "" {
+		title += " by " + md.Brewery
+	}
+	lines = append(lines, bold+fit(title, width)+reset)
+	style := md.Style
+	if md.ABV != "" {
+		style += " · " + md.ABV + "%"
+	}
+	add("Style", style)
+	add("Rating", md.Rating)
+	add("Date", md.Date)
+	place := strings.Join(nonEmpty(md.Venue, md.City, md.State, md.Country), ", ")
+	add("Where", place)
+	add("Serving", md.ServingType)
+	add("With", strings.Join(md.TaggedFriends, ", "))
+	for i, line := range wrap(md.Comment, width-10) {
+		if line == "" {
+			continue
+		}
+		if i == 0 {
+			add("Comment", line)
+		} else {
+			lines = append(lines, strings.Repeat(" ", 10)+line)
+		}
+	}
+	add("Photo", img.Key)
+	return lines
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// view renders the screen, exactly height lines of width columns.
+func (m *model) view(width, height int) string {
+	const monthsWidth = 9
+	lines := make([]string, 0, height)
+
+	// header
+	title := "beers"
+	switch {
+	case m.query != "" || m.searching:
+		title += fmt.Sprintf(" · search %q · %d check-ins", m.query, len(m.rows))
+	case len(m.months) > 0:
+		title += fmt.Sprintf(" · %s · %d check-ins", m.months[m.month].Format(monthFormat), len(m.rows))
+	}
+	lines = append(lines, reverse+fit(" "+title, width)+reset)
+
+	bodyHeight := height - 2
+	var detail []string
+	if m.detail && m.cursor < len(m.rows) && bodyHeight > detailHeight+3 {
+		detail = detailLines(m.rows[m.cursor], width)
+		if len(detail) > detailHeight-1 {
+			detail = detail[:detailHeight-1]
+		}
+		bodyHeight -= detailHeight
+	}
+
+	// keep the cursors on screen, the table has a header line
+	visible := max(bodyHeight-1, 1)
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+visible {
+		m.offset = m.cursor - visible + 1
+	}
+	if m.month < m.monthOffset {
+		m.monthOffset = m.month
+	} else if m.month >= m.monthOffset+bodyHeight {
+		m.monthOffset = m.month - bodyHeight + 1
+	}
+
+	tableWidth := width - monthsWidth - 1
+	widths := columnWidths(tableWidth)
+	for i := 0; i < bodyHeight; i++ {
+		var left string
+		if mi := m.monthOffset + i; mi < len(m.months) {
+			cell := fit(" "+m.months[mi].Format(monthFormat), monthsWidth)
+			switch {
+			case mi == m.month && m.focus == monthsPane:
+				cell = reverse + cell + reset
+			case mi == m.month:
+				cell = bold + cell + reset
+			}
+			left = cell
+		} else {
+			left = strings.Repeat(" ", monthsWidth)
+		}
+
+		var right string
+		switch ri := m.offset + i - 1; {
+		case i == 0:
+			titles := api.CheckinMetadata{Date: "Date", Beer: "Beer", Brewery: "Brewery", Rating: "Rating", Venue: "Venue"}
+			right = bold + tableRow(titles, widths) + reset
+		case ri < len(m.rows):
+			right = tableRow(m.rows[ri].Metadata, widths)
+			if ri == m.cursor {
+				if m.focus == checkinsPane {
+					right = reverse + right + reset
+				} else {
+					right = bold + right + reset
+				}
+			}
+		case i == 1 && len(m.rows) == 0:
+			right = dim + fit("No check-ins", tableWidth) + reset
+		default:
+			right = strings.Repeat(" ", max(tableWidth, 0))
+		}
+		lines = append(lines, left+"│"+right)
+	}
+
+	if detail != nil {
+		lines = append(lines, strings.Repeat("─", width))
+		for _, line := range detail {
+			if !strings.HasPrefix(line, bold) {
+				line = fit(line, width)
+			}
+			lines = append(lines, line)
+		}
+		for len(lines) < height-1 {
+			lines = append(lines, "")
+		}
+	}
+
+	// footer
+	footer := "↑↓ move  ←→ months/check-ins  enter details  / search  q quit"
+	switch {
+	case m.searching:
+		footer = "/" + m.query + "▏  enter done  esc cancel"
+	case m.status != "":
+		footer = m.status
+	}
+	lines = append(lines, fit(footer, width))
+
+	for len(lines) > height {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\r\n")
+}
@@ -0,0 +1,235 @@
+package tui
+
+import (
+	"beers/backend/internal/api"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+type fakeSource struct {
+	months  map[time.Time][]api.Image
+	loads   int
+	failAll bool
+}
+
+func (s *fakeSource) Months(ctx context.Context) ([]time.Time, error) {
+	var months []time.Time
+	for m := range s.months {
+		months = append(months, m)
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].After(months[j]) })
+	return months, nil
+}
+
+func (s *fakeSource) Month(ctx context.Context, month time.Time) ([]api.Image, error) {
+	s.loads++
+	return s.months[month], nil
+}
+
+func (s *fakeSource) All(ctx context.Context) ([]api.Image, error) {
+	if s.failAll {
+		return nil, errors.New("bucket unreachable")
+	}
+	var all []api.Image
+	for _, images := range s.months {
+		all = append(all, images...)
+	}
+	return all, nil
+}
+
+func checkin(date, beer, brewery, venue string) api.Image {
+	return api.Image{
+		Key:      date[:4] + "/" + date[5:7] + "/" + date[8:10] + "/WEBP/" + beer + ".webp",
+		Metadata: api.CheckinMetadata{Date: date, Beer: beer, Brewery: brewery, Venue: venue, Rating: "4"},
+	}
+}
+
+func newFakeSource() *fakeSource {
+	nov := time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC)
+	oct := time.Date(2025, time.October, 1, 0, 0, 0, 0, time.UTC)
+	return &fakeSource{months: map[time.Time][]api.Image{
+		nov: {
+			checkin("2025-11-08 18:30:00", "Gueuze", "Cantillon", "Moeder Lambic"),
+			checkin("2025-11-02 20:00:00", "Orval", "Orval", "Délirium Café"),
+		},
+		oct: {
+			checkin("2025-10-12 17:00:00", "Kriek", "Cantillon", "In de Verzekering tegen de Grote Dorst"),
+		},
+	}}
+}
+
+func press(m *model, keys ...key) {
+	for _, k := range keys {
+		m.update(k)
+	}
+}
+
+func typed(s string) []key {
+	var keys []key
+	for _, r := range s {
+		keys = append(keys, key{code: keyRune, r: r})
+	}
+	return keys
+}
+
+func TestModelNavigation(t *testing.T) {
+	src := newFakeSource()
+	m := newModel(context.Background(), src)
+	m.load()
+
+	if len(m.months) != 2 || len(m.rows) != 2 || m.rows[0].Metadata.Beer != "Gueuze" {
+		t.Fatalf("expected November to open first, got %d months and rows %+v", len(m.months), m.rows)
+	}
+
+	press(m, key{code: keyDown}, key{code: keyDown})
+	if m.cursor != 1 {
+		t.Errorf("cursor = %d, want 1 at the last row", m.cursor)
+	}
+
+	press(m, key{code: keyLeft}, key{code: keyRune, r: 'j'})
+	if m.month != 1 || len(m.rows) != 1 || m.cursor != 0 {
+		t.Errorf("expected October with the cursor reset, got month %d, %d rows, cursor %d", m.month, len(m.rows), m.cursor)
+	}
+
+	press(m, key{code: keyUp}, key{code: keyDown})
+	if src.loads != 2 {
+		t.Errorf("loaded months %d times, want 2 with the cache", src.loads)
+	}
+
+	press(m, key{code: keyEnter}, key{code: keyEnter})
+	if m.focus != checkinsPane || !m.detail {
+		t.Errorf("expected the detail pane of the check-ins, got focus %d detail %v", m.focus, m.detail)
+	}
+	press(m, key{code: keyEscape})
+	if m.detail {
+		t.Errorf("expected escape to close the detail pane")
+	}
+
+	press(m, key{code: keyRune, r: 'q'})
+	if !m.quit {
+		t.Errorf("expected q to quit")
+	}
+}
+
+func TestModelSearch(t *testing.T) {
+	m := newModel(context.Background(), newFakeSource())
+	m.load()
+
+	press(m, typed("/cantillon")...)
+	if !m.searching || len(m.rows) != 2 {
+		t.Fatalf("expected 2 Cantillon check-ins, got %+v", m.rows)
+	}
+	if m.rows[0].Metadata.Beer != "Gueuze" || m.rows[1].Metadata.Beer != "Kriek" {
+		t.Errorf("expected results newest first, got %s, %s", m.rows[0].Metadata.Beer, m.rows[1].Metadata.Beer)
+	}
+
+	press(m, typed(" kriek")...)
+	if len(m.rows) != 1 {
+		t.Errorf("expected every word to match, got %d rows", len(m.rows))
+	}
+	press(m, key{code: keyBackspace}, key{code: keyBackspace}, key{code: keyBackspace},
+		key{code: keyBackspace}, key{code: keyBackspace}, key{code: keyBackspace})
+	if m.query != "cantillon" || len(m.rows) != 2 {
+		t.Errorf("query = %q with %d rows after backspaces", m.query, len(m.rows))
+	}
+
+	press(m, key{code: keyEnter}, key{code: keyRune, r: 'q'})
+	if m.searching || !m.quit {
+		t.Errorf("expected keys to act again once the search is entered")
+	}
+	m.quit = false
+
+	press(m, key{code: keyEscape})
+	if m.query != "" || len(m.rows) != 2 || m.rows[0].Metadata.Beer != "Gueuze" {
+		t.Errorf("expected escape to return to November, got query %q rows %+v", m.query, m.rows)
+	}
+
+	press(m, typed("/délirium")...)
+	if len(m.rows) != 1 || m.rows[0].Metadata.Beer != "Orval" {
+		t.Errorf("expected the search to match venues, got %+v", m.rows)
+	}
+}
+
+func TestModelSearchError(t *testing.T) {
+	src := newFakeSource()
+	src.failAll = true
+	m := newModel(context.Background(), src)
+	m.load()
+
+	press(m, key{code: keyRune, r: '/'})
+	if !strings.Contains(m.status, "bucket unreachable") {
+		t.Errorf("status = %q, want the loading error", m.status)
+	}
+}
+
+func TestView(t *testing.T) {
+	m := newModel(context.Background(), newFakeSource())
+	m.load()
+
+	for _, size := range []struct{ width, height int }{{80, 24}, {120, 40}, {40, 12}} {
+		screen := m.view(size.width, size.height)
+		lines := strings.Split(screen, "\r\n")
+		if len(lines) != size.height {
+			t.Errorf("%dx%d: got %d lines", size.width, size.height, len(lines))
+		}
+		for i, line := range lines {
+			plain := stripEscapes(line)
+			if n := utf8.RuneCountInString(plain); n > size.width {
+				t.Errorf("%dx%d: line %d is %d columns wide: %q", size.width, size.height, i, n, plain)
+			}
+		}
+	}
+
+	screen := stripEscapes(m.view(100, 24))
+	for _, want := range []string{"2025-11", "2025-10", "Gueuze", "Cantillon", "Moeder Lambic", "2 check-ins"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("expected the screen to show %q:\n%s", want, screen)
+		}
+	}
+
+	press(m, key{code: keyEnter})
+	screen = stripEscapes(m.view(100, 24))
+	if !strings.Contains(screen, "Gueuze by Cantillon") || !strings.Contains(screen, "2025/11/08/WEBP/Gueuze.webp") {
+		t.Errorf("expected the detail pane:\n%s", screen)
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{input: "Orval", width: 7, expected: "Orval  "},
+		{input: "Cantillon", width: 5, expected: "Cant…"},
+		{input: "Délirium", width: 8, expected: "Délirium"},
+		{input: "a\nb", width: 3, expected: "a b"},
+		{input: "any", width: 0, expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := fit(tt.input, tt.width); got != tt.expected {
+			t.Errorf("fit(%q, %d) = %q, want %q", tt.input, tt.width, got, tt.expected)
+		}
+	}
+}
+
+// stripEscapes removes the SGR sequences of a rendered line.
+func stripEscapes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x1b {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+// Package tui is an interactive terminal browser over the check-ins of the
+// journal: a list of months, a table of their check-ins, a detail pane and a
+// search over every check-in.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+)
+
+// Run browses the journal until the user quits or ctx is done. in must be a
+// terminal, which is switched to raw mode and restored on return.
+func Run(ctx context.Context, src Source, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("not a terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("raw mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	fmt.Fprint(out, enterAltScreen)
+	defer fmt.Fprint(out, leaveAltScreen)
+
+	m := newModel(ctx, src)
+	draw := func() {
+		width, height, err := term.GetSize(fd)
+		if err != nil || width < 20 || height < 5 {
+			width, height = 80, 24
+		}
+		fmt.Fprint(out, clearScreen+m.view(width, height))
+	}
+	m.busy = func(msg string) {
+		m.status = msg
+		draw()
+		m.status = ""
+	}
+	m.load()
+
+	// keys are read in the background so a cancelled context ends the loop
+	keys := make(chan []key)
+	readErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			keys <- parseKeys(buf[:n])
+		}
+	}()
+
+	for !m.quit {
+		draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			return fmt.Errorf("read terminal: %w", err)
+		case pressed := <-keys:
+			for _, k := range pressed {
+				m.update(k)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Package untappd reads the data export Untappd offers its supporters, as
+// CSV or JSON.
+package untappd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Checkin is one check-in of an export. Values are kept as exported, empty
+// when the export has none.
+type Checkin struct {
+	ID             string
+	Beer           string
+	Brewery        string
+	BreweryCountry string
+	Style          string
+	ABV            string
+	Comment        string
+	Rating         string
+	Venue          string
+	City           string
+	State          string
+	Country        string
+	Lat            string
+	Lng            string
+	// UTC, formatted as 2006-01-02 15:04:05
+	CreatedAt     string
+	ServingType   string
+	TaggedFriends string // comma separated
+}
+
+// newCheckin reads a row of the export, keyed by column name.
+func newCheckin(row map[string]string) Checkin {
+	get := func(name string) string { return strings.TrimSpace(row[name]) }
+	return Checkin{
+		ID:             get("checkin_id"),
+		Beer:           get("beer_name"),
+		Brewery:        get("brewery_name"),
+		BreweryCountry: get("brewery_country"),
+		Style:          get("beer_type"),
+		ABV:            get("beer_abv"),
+		Comment:        get("comment"),
+		Rating:         get("rating_score"),
+		Venue:          get("venue_name"),
+		City:           get("venue_city"),
+		State:          get("venue_state"),
+		Country:        get("venue_country"),
+		Lat:            get("venue_lat"),
+		Lng:            get("venue_lng"),
+		CreatedAt:      get("created_at"),
+		ServingType:    get("serving_type"),
+		TaggedFriends:  get("tagged_friends"),
+	}
+}
+
+// Read parses an export, telling JSON from CSV by its first character.
+// Rows without a check-in ID are an error.
+func Read(r io.Reader) ([]Checkin, error) {
+	br := bufio.NewReader(r)
+	// skip a byte order mark and leading blanks
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			return nil, errors.New("empty export")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c == '\ufeff' || c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		br.UnreadRune()
+		break
+	}
+
+	var (
+		rows []map[string]string
+		err  error
+	)
+	if first, _ := br.Peek(1); first[0] == '[' {
+		rows, err = readJSON(br)
+	} else {
+		rows, err = readCSV(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checkins := make([]Checkin, 0, len(rows))
+	for i, row := range rows {
+		c := newCheckin(row)
+		if c.ID == "" {
+			return nil, fmt.Errorf("check-in %d has no checkin_id", i+1)
+		}
+		checkins = append(checkins, c)
+	}
+	return checkins, nil
+}
+
+// ReadFile parses the export stored at path.
+func ReadFile(path string) ([]Checkin, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[strings.TrimSpace(name)] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readJSON(r io.Reader) ([]map[string]string, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var raw []map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("read JSON: %w", err)
+	}
+
+	rows := make([]map[string]string, 0, len(raw))
+	for _, obj := range raw {
+		row := make(map[string]string, len(obj))
+		for name, v := range obj {
+			switch v := v.(type) {
+			case nil:
+			case string:
+				row[name] = v
+			case json.Number:
+				row[name] = v.String()
+			case []any:
+				// lists, such as tagged friends, are joined like in CSV
+				items := make([]string, 0, len(v))
+				for _, item := range v {
+					if item != nil {
+						items = append(items, fmt.Sprint(item))
+					}
+				}
+				row[name] = strings.Join(items, ", ")
+			default:
+				b, _ := json.Marshal(v)
+				row[name] = string(b)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,70 @@
+package untappd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	csvExport := "\ufeffbeer_name,brewery_name,beer_type,beer_abv,comment,venue_name,venue_city,venue_state,venue_country,venue_lat,venue_lng,rating_score,created_at,checkin_id,serving_type,tagged_friends\n" +
+		"Gueuze 100% Lambic,Brasserie Cantillon,Lambic - Gueuze,5,\"Sour, dry\",Moeder Lambic,Bruxelles,Brussels,Belgium,50.8467,4.3499,4.5,2025-11-08 18:30:00,1234,Bottle,\"alice, bob\"\n" +
+		"Saison Dupont,Brasserie Dupont,Saison,6.5,,,,,,,,,2019-05-18 12:00:00,42,,\n"
+
+	jsonExport := `[
+  {"beer_name": "Gueuze 100% Lambic", "brewery_name": "Brasserie Cantillon", "beer_type": "Lambic - Gueuze",
+   "beer_abv": 5, "comment": "Sour, dry", "venue_name": "Moeder Lambic", "venue_city": "Bruxelles",
+   "venue_state": "Brussels", "venue_country": "Belgium", "venue_lat": 50.8467, "venue_lng": 4.3499,
+   "rating_score": 4.5, "created_at": "2025-11-08 18:30:00", "checkin_id": 1234, "serving_type": "Bottle",
+   "tagged_friends": ["alice", "bob"]},
+  {"beer_name": "Saison Dupont", "brewery_name": "Brasserie Dupont", "beer_type": "Saison", "beer_abv": 6.5,
+   "comment": null, "venue_name": null, "rating_score": null, "created_at": "2019-05-18 12:00:00", "checkin_id": "42"}
+]`
+
+	want := []Checkin{
+		{
+			ID: "1234", Beer: "Gueuze 100% Lambic", Brewery: "Brasserie Cantillon", Style: "Lambic - Gueuze",
+			ABV: "5", Comment: "Sour, dry", Rating: "4.5", Venue: "Moeder Lambic", City: "Bruxelles",
+			State: "Brussels", Country: "Belgium", Lat: "50.8467", Lng: "4.3499",
+			CreatedAt: "2025-11-08 18:30:00", ServingType: "Bottle", TaggedFriends: "alice, bob",
+		},
+		{
+			ID: "42", Beer: "Saison Dupont", Brewery: "Brasserie Dupont", Style: "Saison", ABV: "6.5",
+			CreatedAt: "2019-05-18 12:00:00",
+		},
+	}
+
+	for name, export := range map[string]string{"csv": csvExport, "json": "\n" + jsonExport} {
+		t.Run(name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(export))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("Read() returned %d check-ins, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("check-in %d = %+v, want %+v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":         "",
+		"invalid JSON":  `[{"checkin_id": 1`,
+		"missing ID":    "beer_name,created_at\nSaison,2019-05-18 12:00:00\n",
+		"invalid CSV":   "beer_name,checkin_id\n\"unterminated,1\n",
+		"JSON not list": `[1, 2]`,
+	}
+
+	for name, export := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Read(strings.NewReader(export)); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
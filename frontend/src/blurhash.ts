@@ -0,0 +1,87 @@
+// Decodes the blurhash placeholders computed by the image index, see
+// https://github.com/woltapp/blurhash/blob/master/Algorithm.md
+
+const DIGITS = '0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~';
+
+// size of the decoded placeholder, it is stretched over the card
+const SIZE = 32;
+
+const decode83 = (s: string) => {
+  let value = 0;
+  for (const c of s) {
+    value = value * 83 + DIGITS.indexOf(c);
+  }
+  return value;
+};
+
+const sRGBToLinear = (value: number) => {
+  const v = value / 255;
+  return v <= 0.04045 ? v / 12.92 : Math.pow((v + 0.055) / 1.055, 2.4);
+};
+
+const linearToSRGB = (value: number) => {
+  const v = Math.max(0, Math.min(1, value));
+  return v <= 0.0031308 ? Math.round(v * 12.92 * 255 + 0.5) : Math.round((1.055 * Math.pow(v, 1 / 2.4) - 0.055) * 255 + 0.5);
+};
+
+const signPow = (value: number, exp: number) => Math.sign(value) * Math.pow(Math.abs(value), exp);
+
+const decodeDC = (value: number) => [sRGBToLinear(value >> 16), sRGBToLinear((value >> 8) & 255), sRGBToLinear(value & 255)];
+
+const decodeAC = (value: number, maximum: number) => [
+  signPow((Math.floor(value / (19 * 19)) - 9) / 9, 2) * maximum,
+  signPow(((Math.floor(value / 19) % 19) - 9) / 9, 2) * maximum,
+  signPow(((value % 19) - 9) / 9, 2) * maximum,
+];
+
+const decode = (hash: string, width: number, height: number): Uint8ClampedArray | undefined => {
+  if (hash.length < 6) return undefined;
+  const sizeFlag = decode83(hash[0]);
+  const numY = Math.floor(sizeFlag / 9) + 1;
+  const numX = (sizeFlag % 9) + 1;
+  if (hash.length !== 4 + 2 * numX * numY) return undefined;
+
+  const maximum = (decode83(hash[1]) + 1) / 166;
+  const colors = [decodeDC(decode83(hash.substring(2, 6)))];
+  for (let i = 1; i < numX * numY; i++) {
+    colors.push(decodeAC(decode83(hash.substring(4 + i * 2, 6 + i * 2)), maximum));
+  }
+
+  const pixels = new Uint8ClampedArray(width * height * 4);
+  for (let y = 0; y < height; y++) {
+    for (let x = 0; x < width; x++) {
+      let r = 0;
+      let g = 0;
+      let b = 0;
+      for (let j = 0; j < numY; j++) {
+        for (let i = 0; i < numX; i++) {
+          const basis = Math.cos((Math.PI * x * i) / width) * Math.cos((Math.PI * y * j) / height);
+          const color = colors[i + j * numX];
+          r += color[0] * basis;
+          g += color[1] * basis;
+          b += color[2] * basis;
+        }
+      }
+      const p = 4 * (x + y * width);
+      pixels[p] = linearToSRGB(r);
+      pixels[p + 1] = linearToSRGB(g);
+      pixels[p + 2] = linearToSRGB(b);
+      pixels[p + 3] = 255;
+    }
+  }
+  return pixels;
+};
+
+// blurhashURL renders a blurhash as a data URL, or returns undefined when
+// it is invalid.
+export const blurhashURL = (hash: string): string | undefined => {
+  const pixels = decode(hash, SIZE, SIZE);
+  const canvas = document.createElement('canvas');
+  const ctx = canvas.getContext('2d');
+  if (!pixels || !ctx) return undefined;
+
+  canvas.width = SIZE;
+  canvas.height = SIZE;
+  ctx.putImageData(new ImageData(pixels, SIZE, SIZE), 0, 0);
+  return canvas.toDataURL();
+};
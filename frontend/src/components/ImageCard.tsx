@@ -1,9 +1,14 @@
-import { useState, useEffect, h, Fragment } from 'preact/hooks';
+import { useState, useEffect, useMemo, h, Fragment } from 'preact/hooks';
 import { Image as ImageType } from '../types';
+import { blurhashURL } from '../blurhash';
 import './ImageCard.css';
 
+// matches the grid: 4 columns on wide screens, 2 otherwise
+const THUMBNAIL_SIZES = '(min-width: 768px) 25vw, 50vw';
+
 export const ImageCard = ({ image, onClick }: { image: ImageType, onClick: (image: ImageType) => void }) => {
   const [isLoaded, setIsLoaded] = useState(false);
+  const placeholder = useMemo(() => (image.blurhash ? blurhashURL(image.blurhash) : undefined), [image.blurhash]);
 
   useEffect(() => {
     let cancelled = false;
@@ -15,20 +20,34 @@ export const ImageCard = ({ image, onClick }: { image: ImageType, onClick: (imag
     img.onerror = () => {
       if (!cancelled) setIsLoaded(true);
     };
+    img.sizes = THUMBNAIL_SIZES;
+    img.srcset = image.srcset;
     img.src = image.url;
 
     return () => {
       cancelled = true;
       img.onload = null;
       img.onerror = null;
+      img.srcset = '';
       img.src = '';
     };
-  }, [image.url]);
+  }, [image.url, image.srcset]);
 
   return (
     <div class="image-card" onClick={() => onClick(image)}>
       <div class="image-container">
-        {isLoaded ? <img src={image.url} alt={image.key} /> : <div class="image-placeholder" />}
+        {isLoaded ? (
+          <img
+            src={image.url}
+            srcset={image.srcset}
+            sizes={THUMBNAIL_SIZES}
+            width={image.width}
+            height={image.height}
+            alt={image.key}
+          />
+        ) : (
+          <div class="image-placeholder" style={placeholder ? { backgroundImage: `url(${placeholder})` } : undefined} />
+        )}
       </div>
     </div>
   );
@@ -25,6 +25,13 @@ export const ImageModal = ({ image, isLoading, onClose, onNext, onPrevious, show
     image.metadata.country,
   ].filter(Boolean); // filter out empty strings
 
+  // variants come most preferred first, browsers pick the first source they
+  // support, so only the ones preferred over the primary are offered
+  const primaryIndex = image.variants.findIndex((variant) => variant.url === image.url);
+  const preferredVariants = image.variants
+    .slice(0, Math.max(primaryIndex, 0))
+    .filter((variant) => variant.content_type.startsWith('image/'));
+
   const handleKeyDown = (e: KeyboardEvent) => {
     if (e.key === 'ArrowRight') {
       onNext();
@@ -85,12 +92,20 @@ export const ImageModal = ({ image, isLoading, onClose, onNext, onPrevious, show
       )}
       <div class="modal-content" onClick={(e) => e.stopPropagation()}>
         <button class="close-button" onClick={onClose}>&times;</button>
-        <img src={image.url} alt={image.key} />
+        <picture>
+          {preferredVariants.map((variant) => <source key={variant.key} srcSet={variant.url} type={variant.content_type} />)}
+          <img src={image.url} width={image.width} height={image.height} alt={image.key} />
+        </picture>
         <div class="image-metadata">
           <div class="metadata-body">
             <div class="metadata-section">
               <h2 class="beer-name">{image.metadata.beer}</h2>
               <p class="beer-style">{image.metadata.style} - {image.metadata.abv}% ABV</p>
+              {(image.metadata.serving_type || image.metadata.price) && (
+                <p class="beer-style">
+                  {[image.metadata.serving_type, image.metadata.price].filter(Boolean).join(' - ')}
+                </p>
+              )}
               <p class="rating-display">
                 <StarRating rating={image.metadata.rating} />
                 <span>({image.metadata.rating}/5)</span>
@@ -106,6 +121,12 @@ export const ImageModal = ({ image, isLoading, onClose, onNext, onPrevious, show
                 <blockquote class="comment">{image.metadata.comment}</blockquote>
               </div>
             )}
+
+            {image.metadata.tagged_friends && image.metadata.tagged_friends.length > 0 && (
+              <div class="metadata-section">
+                <p class="tagged-friends">With {image.metadata.tagged_friends.join(', ')}</p>
+              </div>
+            )}
           </div>
 
           <div class="metadata-footer">
@@ -7,14 +7,16 @@ export const useImages = () => {
   const [hasMore, setHasMore] = useState(true);
   const [error, setError] = useState<Error | null>(null);
   const [lastKey, setLastKey] = useState<string>('');
+  // static builds link their pages instead of taking a lastKey
+  const [nextUrl, setNextUrl] = useState<string>('');
 
-  const stateRef = useRef({ isLoading, hasMore, lastKey });
-  stateRef.current = { isLoading, hasMore, lastKey };
+  const stateRef = useRef({ isLoading, hasMore, lastKey, nextUrl });
+  stateRef.current = { isLoading, hasMore, lastKey, nextUrl };
 
   const abortControllerRef = useRef<AbortController | null>(null);
 
   const loadImages = useCallback(async () => {
-    const { isLoading, hasMore, lastKey } = stateRef.current;
+    const { isLoading, hasMore, lastKey, nextUrl } = stateRef.current;
     if (isLoading || !hasMore) return;
 
     abortControllerRef.current?.abort();
@@ -24,9 +26,9 @@ export const useImages = () => {
     setError(null);
 
     try {
-      const url = lastKey
-        ? `/api/images?lastKey=${encodeURIComponent(lastKey)}`
-        : '/api/images';
+      const url =
+        nextUrl ||
+        (lastKey ? `/api/images?lastKey=${encodeURIComponent(lastKey)}` : '/api/images');
 
       const response = await fetch(url, {
         signal: abortControllerRef.current.signal,
@@ -43,6 +45,7 @@ export const useImages = () => {
         return [...prev, ...newImages];
       });
       setHasMore(data.has_more);
+      setNextUrl(data.next ?? '');
 
       if (data.images.length > 0) {
         setLastKey(data.images[data.images.length - 1].key);
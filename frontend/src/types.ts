@@ -13,12 +13,30 @@ export type CheckinMetadata = {
   date: string;
   style: string;
   abv: string;
+  serving_type?: string;
+  price?: string;
+  tagged_friends?: string[];
+  photos?: string[];
+};
+
+export type Variant = {
+  url: string;
+  key: string;
+  content_type: string;
 };
 
 export type Image = {
   url: string;
+  content_type: string;
   last_modified: string;
   key: string;
+  srcset: string;
+  variants: Variant[];
+  photos?: string[];
+  width?: number;
+  height?: number;
+  blurhash?: string;
+  colors?: string[];
   etag: string;
   size: number;
   storage_class: string;
@@ -28,4 +46,5 @@ export type Image = {
 export type ImageResponse = {
   images: Image[];
   has_more: boolean;
+  next?: string;
 };
@@ -23,6 +23,10 @@ export default defineConfig(({ mode }) => ({
         target: 'http://localhost:8080',
         changeOrigin: true,
       },
+      '/img': {
+        target: 'http://localhost:8080',
+        changeOrigin: true,
+      },
     },
   },
 }))
\ No newline at end of file